@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/sriov-tests/pkg/util/cleanup"
+	"github.com/openshift/sriov-tests/pkg/util/namespaces"
+)
+
+func newCleanupCommand(opts *globalOptions) *cobra.Command {
+	var testNamespace string
+	var runID string
+	var deleteNamespace bool
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Remove the pods, networks and policies left by the suites",
+		Long: `Remove the pods, networks and policies left by the suites.
+
+Only the objects labelled with ` + cleanup.RunIDLabel + ` are removed,
+the ones of the given run or of any run when --run-id is not set.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clients, err := opts.clients()
+			if err != nil {
+				return err
+			}
+			registry := cleanup.ForRun(clients, runID, opts.operatorNamespace, testNamespace)
+			registry.Timeout = timeout
+			err = registry.Clean()
+			if err != nil {
+				return err
+			}
+
+			if deleteNamespace {
+				err = clients.Namespaces().Delete(testNamespace, &metav1.DeleteOptions{})
+				if err != nil {
+					return fmt.Errorf("Failed to delete namespace %s %v", testNamespace, err)
+				}
+				err = namespaces.WaitForDeletion(clients, testNamespace, timeout)
+				if err != nil {
+					return fmt.Errorf("Failed to wait for namespace %s deletion %v", testNamespace, err)
+				}
+			}
+			return nil
+		},
+	}
+
+	defaultTestNamespace := os.Getenv("TEST_NAMESPACE")
+	if defaultTestNamespace == "" {
+		defaultTestNamespace = namespaces.Test
+	}
+	cmd.Flags().StringVar(&testNamespace, "test-namespace", defaultTestNamespace, "the namespace the test pods and networks are created in")
+	cmd.Flags().StringVar(&runID, "run-id", "", "remove only the objects of this run")
+	cmd.Flags().BoolVar(&deleteNamespace, "delete-namespace", false, "delete the test namespace too")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "how long to wait for the nodes to settle")
+	return cmd
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/sriov-tests/pkg/util/cluster"
+)
+
+func newDiscoverCommand(opts *globalOptions) *cobra.Command {
+	var pairs bool
+	cmd := &cobra.Command{
+		Use:   "discover",
+		Short: "List the nodes and the SR-IOV devices the suites can run against",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if pairs {
+				return printPairs(cmd, opts)
+			}
+			clients, err := opts.clients()
+			if err != nil {
+				return err
+			}
+			sriovInfos, err := cluster.DiscoverSriov(clients, opts.operatorNamespace)
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "NODE\tINTERFACE\tPCI ADDRESS\tVENDOR\tDEVICE\tDRIVER\tNIC\tNUMVFS\tTOTALVFS")
+			for _, node := range sriovInfos.Nodes {
+				state := sriovInfos.States[node]
+				for _, itf := range state.Status.Interfaces {
+					nic := "unsupported"
+					if entry, ok := sriovInfos.Catalog.LookupInterface(itf); ok {
+						nic = entry.Name
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\n",
+						node, itf.Name, itf.PciAddress, itf.Vendor, itf.DeviceID, itf.Driver, nic, itf.NumVfs, itf.TotalVfs)
+				}
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVar(&pairs, "pairs", false, "list the pairs of PFs of the same model on different nodes instead")
+	return cmd
+}
+
+// printPairs prints the cross node PF pairs, with the physical networks they share
+// according to the SRIOV_CONNECTIVITY map.
+func printPairs(cmd *cobra.Command, opts *globalOptions) error {
+	clients, err := opts.clients()
+	if err != nil {
+		return err
+	}
+	topology, err := cluster.DiscoverTopology(clients, opts.operatorNamespace)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PF A\tPF B\tVENDOR\tDEVICE\tSPEED\tNETWORKS")
+	for _, p := range topology.CrossNodePairs() {
+		networks := "<none>"
+		if p.Connected() {
+			networks = strings.Join(p.Networks, ",")
+		}
+		model := p.A.Model()
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", p.A, p.B, model.Vendor, model.DeviceID, model.LinkSpeed, networks)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/sriov-tests/pkg/util/lint"
+)
+
+// lintInput holds the objects the policies are checked against.
+type lintInput struct {
+	policies []sriovv1.SriovNetworkNodePolicy
+	nodes    []corev1.Node
+	states   []sriovv1.SriovNetworkNodeState
+}
+
+func newLintCommand(opts *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lint [FILE...]",
+		Short: "Check SriovNetworkNodePolicies for conflicts",
+		Long: `Check SriovNetworkNodePolicies for overlapping vf ranges, numVfs mismatches,
+resources used with different device types and vfs beyond the PF capabilities.
+
+When files are given, policies, nodes and node states are read from them.
+Otherwise they are fetched from the cluster.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var input *lintInput
+			var err error
+			if len(args) > 0 {
+				input, err = lintInputFromFiles(args)
+			} else {
+				input, err = lintInputFromCluster(opts)
+			}
+			if err != nil {
+				return err
+			}
+
+			issues := lint.Policies(input.policies, input.nodes, input.states)
+			for _, i := range issues {
+				fmt.Fprintln(cmd.OutOrStdout(), i.String())
+			}
+			if len(issues) > 0 {
+				return fmt.Errorf("found %d issues in %d policies", len(issues), len(input.policies))
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+func lintInputFromCluster(opts *globalOptions) (*lintInput, error) {
+	clients, err := opts.clients()
+	if err != nil {
+		return nil, err
+	}
+	operatorNamespace := opts.operatorNamespace
+
+	policies := sriovv1.SriovNetworkNodePolicyList{}
+	err = clients.List(context.Background(), &policies, runtimeclient.InNamespace(operatorNamespace))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list policies %v", err)
+	}
+	nodes, err := clients.Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list nodes %v", err)
+	}
+	states, err := clients.SriovNetworkNodeStates(operatorNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list node states %v", err)
+	}
+
+	return &lintInput{
+		policies: policies.Items,
+		nodes:    nodes.Items,
+		states:   states.Items,
+	}, nil
+}
+
+func lintInputFromFiles(paths []string) (*lintInput, error) {
+	res := &lintInput{}
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		err = res.decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode %s %v", path, err)
+		}
+	}
+	return res, nil
+}
+
+// decode reads all the yaml or json documents from the reader, keeping
+// the policies, nodes and node states found, including the ones in lists.
+func (l *lintInput) decode(r io.Reader) error {
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		raw := runtime.RawExtension{}
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(raw.Raw) == 0 || string(raw.Raw) == "null" {
+			continue
+		}
+		if err := l.add(raw.Raw); err != nil {
+			return err
+		}
+	}
+}
+
+func (l *lintInput) add(data []byte) error {
+	meta := struct {
+		Kind  string            `json:"kind"`
+		Items []json.RawMessage `json:"items"`
+	}{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return err
+	}
+
+	switch {
+	case meta.Kind == "SriovNetworkNodePolicy":
+		p := sriovv1.SriovNetworkNodePolicy{}
+		if err := json.Unmarshal(data, &p); err != nil {
+			return err
+		}
+		l.policies = append(l.policies, p)
+	case meta.Kind == "SriovNetworkNodeState":
+		s := sriovv1.SriovNetworkNodeState{}
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		l.states = append(l.states, s)
+	case meta.Kind == "Node":
+		n := corev1.Node{}
+		if err := json.Unmarshal(data, &n); err != nil {
+			return err
+		}
+		l.nodes = append(l.nodes, n)
+	case strings.HasSuffix(meta.Kind, "List"):
+		for _, item := range meta.Items {
+			if err := l.add(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
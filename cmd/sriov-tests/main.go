@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	"github.com/spf13/cobra"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+)
+
+const defaultOperatorNamespace = "openshift-sriov-network-operator"
+
+// globalOptions are the options shared by all the commands.
+type globalOptions struct {
+	kubeconfig        string
+	context           string
+	operatorNamespace string
+}
+
+// clientTimeout bounds the time spent checking the cluster serves the needed apis.
+const clientTimeout = 30 * time.Second
+
+func (o *globalOptions) clients() (*testclient.ClientSet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clientTimeout)
+	defer cancel()
+	return testclient.NewWithOptions(ctx, testclient.Options{
+		Kubeconfig:            o.kubeconfig,
+		Context:               o.context,
+		UserAgent:             "sriov-tests",
+		AddToScheme:           sriovv1.AddToScheme,
+		AllowMissingOpenShift: true,
+	})
+}
+
+func main() {
+	opts := &globalOptions{}
+	root := &cobra.Command{
+		Use:          "sriov-tests",
+		Short:        "Tools to validate the SR-IOV network operator",
+		SilenceUsage: true,
+	}
+
+	operatorNamespace := os.Getenv("OPERATOR_NAMESPACE")
+	if operatorNamespace == "" {
+		operatorNamespace = defaultOperatorNamespace
+	}
+	root.PersistentFlags().StringVar(&opts.kubeconfig, "kubeconfig", os.Getenv("KUBECONFIG"), "path to the kubeconfig file")
+	root.PersistentFlags().StringVar(&opts.context, "context", "", "the kubeconfig context to use instead of the current one")
+	root.PersistentFlags().StringVar(&opts.operatorNamespace, "operator-namespace", operatorNamespace, "the namespace the operator runs in")
+
+	root.AddCommand(
+		newSuiteCommand(opts, conformanceSuite),
+		newSuiteCommand(opts, operatorSuite),
+		newSuiteCommand(opts, e2eSuite),
+		newDiscoverCommand(opts),
+		newCleanupCommand(opts),
+		newLintCommand(opts),
+		newSnapshotCommand(opts),
+	)
+
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/sriov-tests/pkg/util/snapshot"
+)
+
+func newSnapshotCommand(opts *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Save or restore the SR-IOV configuration of the cluster",
+	}
+
+	var nodeLabels []string
+	save := &cobra.Command{
+		Use:   "save FILE",
+		Short: "Write the SR-IOV configuration of the cluster to a yaml file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clients, err := opts.clients()
+			if err != nil {
+				return err
+			}
+			s, err := snapshot.Take(clients, opts.operatorNamespace, nodeLabels...)
+			if err != nil {
+				return err
+			}
+			return s.WriteFile(args[0])
+		},
+	}
+	save.Flags().StringSliceVar(&nodeLabels, "node-label", []string{"sriovenabled"}, "node labels to be saved")
+
+	restore := &cobra.Command{
+		Use:   "restore FILE",
+		Short: "Bring the cluster back to the SR-IOV configuration saved in the file,\nin the operator namespace the snapshot was taken in",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := snapshot.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			clients, err := opts.clients()
+			if err != nil {
+				return err
+			}
+			return s.Restore(clients)
+		},
+	}
+
+	cmd.AddCommand(save, restore)
+	return cmd
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdlatest "k8s.io/client-go/tools/clientcmd/api/latest"
+	clientcmdv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/sriov-tests/pkg/util/namespaces"
+)
+
+// suite describes a ginkgo suite the cli can run.
+type suite struct {
+	name    string
+	pkg     string
+	short   string
+	timeout time.Duration
+	// framework is true for the suites based on the operator-sdk test framework,
+	// which need the framework flags and take TEST_NAMESPACE as the operator namespace.
+	framework bool
+}
+
+var (
+	conformanceSuite = suite{
+		name:    "conformance",
+		pkg:     "./conformance",
+		short:   "Run the conformance suite against a cluster with SR-IOV hardware",
+		timeout: 3 * time.Hour,
+	}
+	operatorSuite = suite{
+		name:      "operator",
+		pkg:       "./tests/operator",
+		short:     "Run the operator suite",
+		timeout:   time.Hour,
+		framework: true,
+	}
+	e2eSuite = suite{
+		name:      "e2e",
+		pkg:       "./tests/e2e",
+		short:     "Run the e2e suite",
+		timeout:   2 * time.Hour,
+		framework: true,
+	}
+)
+
+// suiteOptions are the options of a suite run.
+type suiteOptions struct {
+	root          string
+	testNamespace string
+	junitPath     string
+	focus         string
+	skip          string
+	timeout       time.Duration
+	verbose       bool
+	envtest       bool
+	simulator     bool
+}
+
+func newSuiteCommand(opts *globalOptions, s suite) *cobra.Command {
+	suiteOpts := &suiteOptions{}
+	cmd := &cobra.Command{
+		Use:   s.name,
+		Short: s.short,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSuite(opts, suiteOpts, s)
+		},
+	}
+
+	testNamespace := os.Getenv("TEST_NAMESPACE")
+	if testNamespace == "" {
+		testNamespace = namespaces.Test
+	}
+	cmd.Flags().StringVar(&suiteOpts.root, "root", ".", "path to the sriov-tests repository")
+	cmd.Flags().StringVar(&suiteOpts.junitPath, "junit", "", "path of the junit report, no report is written when empty")
+	cmd.Flags().StringVar(&suiteOpts.focus, "focus", "", "run only the specs matching this regular expression")
+	cmd.Flags().StringVar(&suiteOpts.skip, "skip", "", "skip the specs matching this regular expression")
+	cmd.Flags().DurationVar(&suiteOpts.timeout, "timeout", s.timeout, "fail the suite if it runs longer than this")
+	cmd.Flags().BoolVarP(&suiteOpts.verbose, "verbose", "v", false, "print the specs as they run")
+	if !s.framework {
+		cmd.Flags().StringVar(&suiteOpts.testNamespace, "test-namespace", testNamespace, "the namespace the test pods and networks are created in")
+	}
+	if s.name == operatorSuite.name {
+		cmd.Flags().BoolVar(&suiteOpts.envtest, "envtest", false, "run against a local apiserver instead of a cluster, skipping the specs that need the operator")
+	}
+	if s.name == e2eSuite.name {
+		cmd.Flags().BoolVar(&suiteOpts.simulator, "simulator", false, "simulate the config daemon on the worker nodes")
+	}
+	return cmd
+}
+
+// runSuite runs the suite through go test, so the ginkgo cli is not needed.
+func runSuite(opts *globalOptions, suiteOpts *suiteOptions, s suite) error {
+	root, err := filepath.Abs(suiteOpts.root)
+	if err != nil {
+		return err
+	}
+
+	kubeconfig := opts.kubeconfig
+	if opts.context != "" {
+		if suiteOpts.envtest {
+			return fmt.Errorf("--context cannot be used with --envtest")
+		}
+		// the suites, and the framework ones in particular, use the current context
+		kubeconfig, err = kubeconfigForContext(opts.kubeconfig, opts.context)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(kubeconfig)
+	}
+
+	args := []string{"test", s.pkg, "-count=1", "-timeout", suiteOpts.timeout.String()}
+	if suiteOpts.verbose {
+		args = append(args, "-v")
+	}
+	args = append(args, "-args")
+	if suiteOpts.focus != "" {
+		args = append(args, "-ginkgo.focus="+suiteOpts.focus)
+	}
+	if suiteOpts.skip != "" {
+		args = append(args, "-ginkgo.skip="+suiteOpts.skip)
+	}
+	if suiteOpts.verbose {
+		args = append(args, "-ginkgo.v", "-ginkgo.progress")
+	}
+	if suiteOpts.junitPath != "" {
+		junitPath, err := filepath.Abs(suiteOpts.junitPath)
+		if err != nil {
+			return err
+		}
+		args = append(args, "-junit="+junitPath)
+	}
+
+	env := append(os.Environ(),
+		"GOFLAGS=-mod=vendor",
+		"OPERATOR_NAMESPACE="+opts.operatorNamespace,
+	)
+	if s.framework {
+		manifest := filepath.Join(root, "scripts", "dummy.yaml")
+		args = append(args,
+			"-root="+root,
+			"-globalMan="+manifest,
+			"-namespacedMan="+manifest,
+			"-singleNamespace=true",
+		)
+		if !suiteOpts.envtest && kubeconfig != "" {
+			args = append(args, "-kubeconfig="+kubeconfig)
+		}
+		env = append(env, "TEST_NAMESPACE="+opts.operatorNamespace)
+	} else {
+		env = append(env, "TEST_NAMESPACE="+suiteOpts.testNamespace)
+	}
+	if kubeconfig != "" && !suiteOpts.envtest {
+		env = append(env, "KUBECONFIG="+kubeconfig)
+	}
+	if suiteOpts.envtest {
+		env = append(env, "USE_ENVTEST=true")
+	}
+	if suiteOpts.simulator {
+		env = append(env, "SRIOV_SIMULATOR=true")
+	}
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = root
+	cmd.Env = env
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("%s suite failed: %v", s.name, err)
+	}
+	return nil
+}
+
+// kubeconfigForContext writes a copy of the kubeconfig, or of the default one when
+// empty, with the given context as the current one, and returns its path.
+func kubeconfigForContext(kubeconfig, context string) (string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	}
+	config, err := rules.Load()
+	if err != nil {
+		return "", fmt.Errorf("Failed to load kubeconfig %s %v", kubeconfig, err)
+	}
+	if _, ok := config.Contexts[context]; !ok {
+		return "", fmt.Errorf("Context %s not found in the kubeconfig", context)
+	}
+	config.CurrentContext = context
+
+	// encoded through sigs.k8s.io/yaml, as the codec of clientcmd.Write fails on
+	// the maps of a loaded config with recent go releases
+	v1Config := &clientcmdv1.Config{}
+	if err := clientcmdlatest.Scheme.Convert(config, v1Config, nil); err != nil {
+		return "", fmt.Errorf("Failed to convert kubeconfig %s %v", kubeconfig, err)
+	}
+	v1Config.APIVersion = clientcmdv1.SchemeGroupVersion.Version
+	v1Config.Kind = "Config"
+	data, err := yaml.Marshal(v1Config)
+	if err != nil {
+		return "", fmt.Errorf("Failed to encode kubeconfig %s %v", kubeconfig, err)
+	}
+
+	f, err := ioutil.TempFile("", "sriov-tests-kubeconfig")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("Failed to write kubeconfig %s %v", f.Name(), err)
+	}
+	return f.Name(), nil
+}
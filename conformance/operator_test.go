@@ -9,13 +9,18 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gstruct"
+	"github.com/onsi/gomega/types"
 
 	netattdefv1 "github.com/openshift/sriov-network-operator/pkg/apis/k8s/v1"
 	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
 	"github.com/openshift/sriov-tests/pkg/util/cluster"
 	"github.com/openshift/sriov-tests/pkg/util/execute"
 	"github.com/openshift/sriov-tests/pkg/util/namespaces"
+	"github.com/openshift/sriov-tests/pkg/util/netinspect"
+	"github.com/openshift/sriov-tests/pkg/util/nics"
 	"github.com/openshift/sriov-tests/pkg/util/pod"
+	"github.com/openshift/sriov-tests/pkg/util/snapshot"
+	"github.com/openshift/sriov-tests/pkg/util/traffic"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/pointer"
@@ -33,18 +38,28 @@ var _ = Describe("operator", func() {
 	})
 
 	BeforeEach(func() {
-		err := namespaces.Clean(operatorNamespace, namespaces.Test, clients)
+		err := registry.Clean()
+		Expect(err).ToNot(HaveOccurred())
+		err = cluster.WaitForSriovStable(operatorNamespace, clients, 3*time.Minute, GinkgoWriter)
 		Expect(err).ToNot(HaveOccurred())
-		Eventually(func() bool {
-			res, err := cluster.SriovStable(operatorNamespace, clients)
-			Expect(err).ToNot(HaveOccurred())
-			return res
-		}, 3*time.Minute, 1*time.Second).Should(Equal(true))
 	})
 
 	var _ = Describe("Configuration", func() {
 
 		Context("SR-IOV network config daemon can be set by nodeselector", func() {
+			var state *snapshot.Snapshot
+
+			BeforeEach(func() {
+				var err error
+				state, err = snapshot.Take(clients, operatorNamespace, "sriovenabled")
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				err := state.Restore(clients)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
 			// 26186
 			It("Should schedule the config daemon on selected nodes", func() {
 
@@ -83,13 +98,7 @@ var _ = Describe("operator", func() {
 				}, 1*time.Minute, 1*time.Second).Should(Equal(true))
 
 				By("Restoring the node selector for daemons")
-				err = clients.Get(context.TODO(), runtimeclient.ObjectKey{
-					Name:      "default",
-					Namespace: operatorNamespace,
-				}, &cfg)
-				Expect(err).ToNot(HaveOccurred())
-				cfg.Spec.ConfigDaemonNodeSelector = map[string]string{}
-				err = clients.Update(context.TODO(), &cfg)
+				err = state.Restore(clients)
 				Expect(err).ToNot(HaveOccurred())
 
 				By("Checking that a daemon is scheduled on each worker node")
@@ -103,9 +112,12 @@ var _ = Describe("operator", func() {
 		Context("PF Partitioning", func() {
 			// 27633
 			It("Should be possible to partition the pf's vfs", func() {
-				node := sriovInfos.Nodes[0]
-				intf, err := sriovInfos.FindOneSriovDevice(node)
-				Expect(err).ToNot(HaveOccurred())
+				selection, err := sriovInfos.FindNodeWithDevices(1, cluster.WithMinTotalVfs(5))
+				if err != nil {
+					Skip(err.Error())
+				}
+				node := selection.Node
+				intf := &selection.Candidates[0]
 
 				firstConfig := &sriovv1.SriovNetworkNodePolicy{
 					ObjectMeta: metav1.ObjectMeta{
@@ -127,7 +139,7 @@ var _ = Describe("operator", func() {
 					},
 				}
 
-				err = clients.Create(context.Background(), firstConfig)
+				err = registry.Create(firstConfig)
 				Expect(err).ToNot(HaveOccurred())
 
 				Eventually(func() sriovv1.Interfaces {
@@ -170,7 +182,7 @@ var _ = Describe("operator", func() {
 					},
 				}
 
-				err = clients.Create(context.Background(), secondConfig)
+				err = registry.Create(secondConfig)
 				Expect(err).ToNot(HaveOccurred())
 
 				Eventually(func() sriovv1.Interfaces {
@@ -237,7 +249,7 @@ var _ = Describe("operator", func() {
 					},
 				}
 
-				err = clients.Create(context.Background(), firstConfig)
+				err = registry.Create(firstConfig)
 				Expect(err).ToNot(HaveOccurred())
 
 				Eventually(func() sriovv1.Interfaces {
@@ -272,24 +284,25 @@ var _ = Describe("operator", func() {
 					},
 				}
 
-				err = clients.Create(context.Background(), secondConfig)
+				err = registry.Create(secondConfig)
 				Expect(err).To(HaveOccurred())
 			})
 		})
 
 		Context("VF flags", func() {
-			debugPod := &corev1.Pod{}
+			debugger := &pod.Debugger{}
 			intf := &sriovv1.InterfaceExt{}
+			node := ""
 			numVfs := 5
 
-			validationFunction := func(networks []string, containsFunc func(line string) bool) {
+			validationFunction := func(networks []string, vfMatcher types.GomegaMatcher) {
 				// Validate all the virtual functions are in the host namespace
-				_, err := findPodVFInHost(intf.Name, numVfs, debugPod)
+				_, err := findPodVFInHost(intf.Name, debugger)
 				Expect(err).To(HaveOccurred())
 				Expect(err.Error()).To(Equal("failed to find the vf number that was moved into the pod"))
 
-				podObj := pod.DefineWithNetworks(networks)
-				err = clients.Create(context.Background(), podObj)
+				podObj := pod.Define(pod.WithNetworkNames(networks...), pod.OnNode(node))
+				err = registry.Create(podObj)
 				Expect(err).ToNot(HaveOccurred())
 				Eventually(func() corev1.PodPhase {
 					podObj, err = clients.Pods(namespaces.Test).Get(podObj.Name, metav1.GetOptions{})
@@ -298,49 +311,50 @@ var _ = Describe("operator", func() {
 				}, 3*time.Minute, time.Second).Should(Equal(corev1.PodRunning))
 
 				Expect(err).ToNot(HaveOccurred())
-				stdout, stderr, err := pod.ExecCommand(clients, podObj, "ip", "addr", "show", "dev", "net1")
-				Expect(err).ToNot(HaveOccurred())
-				Expect(stderr).To(Equal(""))
-				vfID, err := findPodVFInHost(intf.Name, numVfs, debugPod)
+
+				netStatus, err := pod.FindNetworkStatus(podObj, networks[0])
 				Expect(err).ToNot(HaveOccurred())
-				stdout, stderr, err = pod.ExecCommand(clients, debugPod, "ip", "link", "show")
+				Expect(netStatus.PciAddress()).ToNot(BeEmpty(), "no device-info reported for %s", networks[0])
+				nodeState, err := clients.SriovNetworkNodeStates(operatorNamespace).Get(podObj.Spec.NodeName, metav1.GetOptions{})
 				Expect(err).ToNot(HaveOccurred())
-				Expect(stderr).To(Equal(""))
-
-				found := false
-				for _, line := range strings.Split(stdout, "\n") {
-					if strings.Contains(line, fmt.Sprintf("vf %d ", vfID)) && containsFunc(line) {
-						found = true
-						break
+				var statusPF *sriovv1.InterfaceExt
+				for i := range nodeState.Status.Interfaces {
+					if nodeState.Status.Interfaces[i].Name == intf.Name {
+						statusPF = &nodeState.Status.Interfaces[i]
 					}
 				}
+				Expect(statusPF).ToNot(BeNil(), "pf %s not found in the state of %s", intf.Name, podObj.Spec.NodeName)
+				Expect(podObj).To(pod.HaveSriovAttachmentFromPF(networks[0], statusPF))
+
+				net1, err := netinspect.Addresses(clients, podObj, "net1")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(net1.IPs("inet")).ToNot(BeEmpty())
+				vfID, err := findPodVFInHost(intf.Name, debugger)
+				Expect(err).ToNot(HaveOccurred())
+				pf, err := hostLink(debugger, intf.Name)
+				Expect(err).ToNot(HaveOccurred())
+				vf, err := pf.VF(vfID)
+				Expect(err).ToNot(HaveOccurred())
 
 				err = clients.Pods(namespaces.Test).Delete(podObj.Name, &metav1.DeleteOptions{
 					GracePeriodSeconds: pointer.Int64Ptr(0)})
 				Expect(err).ToNot(HaveOccurred())
 
-				Expect(found).To(BeTrue())
+				Expect(vf).To(PointTo(vfMatcher))
 			}
 
-			validateNetworkFields := func(sriovNetwork *sriovv1.SriovNetwork, validationString string) {
+			validateNetworkFields := func(sriovNetwork *sriovv1.SriovNetwork, vfFields Fields) {
 				netAttDef := &netattdefv1.NetworkAttachmentDefinition{}
 				Eventually(func() error {
 					return clients.Get(context.Background(), runtimeclient.ObjectKey{Name: sriovNetwork.Name, Namespace: namespaces.Test}, netAttDef)
 				}, 10*time.Second, 1*time.Second).ShouldNot(HaveOccurred())
 
-				checkFunc := func(line string) bool {
-					if strings.Contains(line, validationString) {
-						return true
-					}
-					return false
-				}
-
-				validationFunction([]string{sriovNetwork.Name}, checkFunc)
+				validationFunction([]string{sriovNetwork.Name}, MatchFields(IgnoreExtras, vfFields))
 			}
 
 			BeforeEach(func() {
 				var err error
-				node := sriovInfos.Nodes[0]
+				node = sriovInfos.Nodes[0]
 				intf, err = sriovInfos.FindOneSriovDevice(node)
 				Expect(err).ToNot(HaveOccurred())
 
@@ -364,7 +378,7 @@ var _ = Describe("operator", func() {
 					},
 				}
 
-				err = clients.Create(context.Background(), config)
+				err = registry.Create(config)
 				Expect(err).ToNot(HaveOccurred())
 
 				Eventually(func() sriovv1.Interfaces {
@@ -378,20 +392,12 @@ var _ = Describe("operator", func() {
 						"NumVfs": Equal(numVfs),
 					})))
 
-				Eventually(func() bool {
-					res, err := cluster.SriovStable(operatorNamespace, clients)
-					Expect(err).ToNot(HaveOccurred())
-					return res
-				}, 7*time.Minute, 1*time.Second).Should(BeTrue())
+				err = cluster.WaitForSriovStable(operatorNamespace, clients, 7*time.Minute, GinkgoWriter)
+				Expect(err).ToNot(HaveOccurred())
 
-				debugPod = pod.DefineWithHostNetwork()
-				err = clients.Create(context.Background(), debugPod)
+				debugger, err = pod.DebugOnNode(clients, node)
 				Expect(err).ToNot(HaveOccurred())
-				Eventually(func() corev1.PodPhase {
-					debugPod, err = clients.Pods(namespaces.Test).Get(debugPod.Name, metav1.GetOptions{})
-					Expect(err).ToNot(HaveOccurred())
-					return debugPod.Status.Phase
-				}, 3*time.Minute, time.Second).Should(Equal(corev1.PodRunning))
+				Expect(debugger.NumVfs(intf.Name)).To(Equal(numVfs))
 			})
 
 			// 25959
@@ -412,8 +418,8 @@ var _ = Describe("operator", func() {
 				By("configuring spoofChk on")
 				copyObj := sriovNetwork.DeepCopy()
 				copyObj.Spec.SpoofChk = "on"
-				spoofChkStatusValidation := "spoof checking on"
-				err := clients.Create(context.Background(), copyObj)
+				spoofChkStatusValidation := Fields{"SpoofChk": BeTrue()}
+				err := registry.Create(copyObj)
 				Expect(err).ToNot(HaveOccurred())
 
 				validateNetworkFields(copyObj, spoofChkStatusValidation)
@@ -425,8 +431,8 @@ var _ = Describe("operator", func() {
 				By("configuring spoofChk off")
 				copyObj = sriovNetwork.DeepCopy()
 				copyObj.Spec.SpoofChk = "off"
-				spoofChkStatusValidation = "spoof checking off"
-				err = clients.Create(context.Background(), copyObj)
+				spoofChkStatusValidation = Fields{"SpoofChk": BeFalse()}
+				err = registry.Create(copyObj)
 				Expect(err).ToNot(HaveOccurred())
 
 				validateNetworkFields(copyObj, spoofChkStatusValidation)
@@ -450,8 +456,8 @@ var _ = Describe("operator", func() {
 				By("configuring trust on")
 				copyObj := sriovNetwork.DeepCopy()
 				copyObj.Spec.Trust = "on"
-				trustChkStatusValidation := "trust on"
-				err := clients.Create(context.Background(), copyObj)
+				trustChkStatusValidation := Fields{"Trust": BeTrue()}
+				err := registry.Create(copyObj)
 				Expect(err).ToNot(HaveOccurred())
 
 				validateNetworkFields(copyObj, trustChkStatusValidation)
@@ -463,8 +469,8 @@ var _ = Describe("operator", func() {
 				By("configuring trust off")
 				copyObj = sriovNetwork.DeepCopy()
 				copyObj.Spec.Trust = "off"
-				trustChkStatusValidation = "trust off"
-				err = clients.Create(context.Background(), copyObj)
+				trustChkStatusValidation = Fields{"Trust": BeFalse()}
+				err = registry.Create(copyObj)
 				Expect(err).ToNot(HaveOccurred())
 
 				validateNetworkFields(copyObj, trustChkStatusValidation)
@@ -488,8 +494,8 @@ var _ = Describe("operator", func() {
 				By("configuring link-state as enabled")
 				enabledLinkNetwork := sriovNetwork.DeepCopy()
 				enabledLinkNetwork.Spec.LinkState = "enable"
-				linkStateChkStatusValidation := "link-state enable"
-				err := clients.Create(context.Background(), enabledLinkNetwork)
+				linkStateChkStatusValidation := Fields{"LinkState": Equal("enable")}
+				err := registry.Create(enabledLinkNetwork)
 				Expect(err).ToNot(HaveOccurred())
 
 				validateNetworkFields(enabledLinkNetwork, linkStateChkStatusValidation)
@@ -501,8 +507,8 @@ var _ = Describe("operator", func() {
 				By("configuring link-state as disable")
 				disabledLinkNetwork := sriovNetwork.DeepCopy()
 				disabledLinkNetwork.Spec.LinkState = "disable"
-				linkStateChkStatusValidation = "link-state disable"
-				err = clients.Create(context.Background(), disabledLinkNetwork)
+				linkStateChkStatusValidation = Fields{"LinkState": Equal("disable")}
+				err = registry.Create(disabledLinkNetwork)
 				Expect(err).ToNot(HaveOccurred())
 
 				validateNetworkFields(disabledLinkNetwork, linkStateChkStatusValidation)
@@ -514,8 +520,8 @@ var _ = Describe("operator", func() {
 				By("configuring link-state as auto")
 				autoLinkNetwork := sriovNetwork.DeepCopy()
 				autoLinkNetwork.Spec.LinkState = "auto"
-				linkStateChkStatusValidation = "link-state auto"
-				err = clients.Create(context.Background(), autoLinkNetwork)
+				linkStateChkStatusValidation = Fields{"LinkState": Equal("auto")}
+				err = registry.Create(autoLinkNetwork)
 				Expect(err).ToNot(HaveOccurred())
 
 				validateNetworkFields(autoLinkNetwork, linkStateChkStatusValidation)
@@ -528,12 +534,8 @@ var _ = Describe("operator", func() {
 					intf, err := sriovInfos.FindOneSriovDevice(node)
 					Expect(err).ToNot(HaveOccurred())
 
-					if intf.Driver != "mlx5_core" {
-						// There is an issue with the intel cards both driver i40 and ixgbe
-						// BZ 1772847
-						// BZ 1772815
-						// BZ 1236146
-						Skip("Skip rate limit test no mellanox driver found")
+					if supported, reason := sriovInfos.Catalog.Supports(*intf, nics.RateLimit); !supported {
+						Skip("Skip rate limit test: " + reason)
 					}
 
 					var maxTxRate = 100
@@ -551,7 +553,7 @@ var _ = Describe("operator", func() {
 							MinTxRate:        &minTxRate,
 							NetworkNamespace: namespaces.Test,
 						}}
-					err = clients.Create(context.Background(), sriovNetwork)
+					err = registry.Create(sriovNetwork)
 					Expect(err).ToNot(HaveOccurred())
 
 					netAttDef := &netattdefv1.NetworkAttachmentDefinition{}
@@ -559,15 +561,10 @@ var _ = Describe("operator", func() {
 						return clients.Get(context.Background(), runtimeclient.ObjectKey{Name: "ratenetwork", Namespace: namespaces.Test}, netAttDef)
 					}, 10*time.Second, 1*time.Second).ShouldNot(HaveOccurred())
 
-					checkFunc := func(line string) bool {
-						if strings.Contains(line, "max_tx_rate 100Mbps") &&
-							strings.Contains(line, "min_tx_rate 40Mbps") {
-							return true
-						}
-						return false
-					}
-
-					validationFunction([]string{"ratenetwork"}, checkFunc)
+					validationFunction([]string{"ratenetwork"}, MatchFields(IgnoreExtras, Fields{
+						"MaxTxRate": Equal(maxTxRate),
+						"MinTxRate": Equal(minTxRate),
+					}))
 				})
 			})
 
@@ -587,7 +584,7 @@ var _ = Describe("operator", func() {
 							VlanQoS:          2,
 							NetworkNamespace: namespaces.Test,
 						}}
-					err := clients.Create(context.Background(), sriovNetwork)
+					err := registry.Create(sriovNetwork)
 					Expect(err).ToNot(HaveOccurred())
 
 					netAttDef := &netattdefv1.NetworkAttachmentDefinition{}
@@ -595,15 +592,150 @@ var _ = Describe("operator", func() {
 						return clients.Get(context.Background(), runtimeclient.ObjectKey{Name: "quosnetwork", Namespace: namespaces.Test}, netAttDef)
 					}, 10*time.Second, 1*time.Second).ShouldNot(HaveOccurred())
 
-					checkFunc := func(line string) bool {
-						if strings.Contains(line, "vlan 1") &&
-							strings.Contains(line, "qos 2") {
-							return true
-						}
-						return false
+					validationFunction([]string{"quosnetwork"}, MatchFields(IgnoreExtras, Fields{
+						"Vlan": Equal(1),
+						"Qos":  Equal(2),
+					}))
+				})
+			})
+
+			Describe("runtime config", func() {
+				It("Should configure the requested interfaces, macs and ips", func() {
+					sriovNetwork := &sriovv1.SriovNetwork{ObjectMeta: metav1.ObjectMeta{Name: "runtimeconfignetwork", Namespace: operatorNamespace},
+						Spec: sriovv1.SriovNetworkSpec{
+							ResourceName:     "testresource",
+							IPAM:             `{"type":"static"}`,
+							Capabilities:     `{"mac": true, "ips": true}`,
+							NetworkNamespace: namespaces.Test,
+						}}
+					err := registry.Create(sriovNetwork)
+					Expect(err).ToNot(HaveOccurred())
+
+					netAttDef := &netattdefv1.NetworkAttachmentDefinition{}
+					Eventually(func() error {
+						return clients.Get(context.Background(), runtimeclient.ObjectKey{Name: sriovNetwork.Name, Namespace: namespaces.Test}, netAttDef)
+					}, 10*time.Second, 1*time.Second).ShouldNot(HaveOccurred())
+
+					// the same network is attached twice
+					elements := []pod.NetworkSelectionElement{
+						{Name: sriovNetwork.Name, InterfaceRequest: "sriov1", MacRequest: "20:04:0f:f1:88:01", IPRequest: []string{"10.10.10.2/24"}},
+						{Name: sriovNetwork.Name, InterfaceRequest: "sriov2", MacRequest: "20:04:0f:f1:88:02", IPRequest: []string{"10.10.10.3/24"}},
 					}
+					Expect(pod.CheckCapabilities(sriovNetwork, elements...)).To(Succeed())
 
-					validationFunction([]string{"quosnetwork"}, checkFunc)
+					podObj := pod.Define(pod.WithNetworks(elements...), pod.OnNode(node))
+					err = registry.Create(podObj)
+					Expect(err).ToNot(HaveOccurred())
+					Eventually(func() corev1.PodPhase {
+						podObj, err = clients.Pods(namespaces.Test).Get(podObj.Name, metav1.GetOptions{})
+						Expect(err).ToNot(HaveOccurred())
+						return podObj.Status.Phase
+					}, 3*time.Minute, time.Second).Should(Equal(corev1.PodRunning))
+
+					Expect(podObj).To(pod.HaveRequestedAttachments())
+					for _, e := range elements {
+						link, err := netinspect.Addresses(clients, podObj, e.InterfaceRequest)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(link.Address).To(Equal(e.MacRequest))
+						Expect(link.IPs("inet")).To(ContainElement(strings.Split(e.IPRequest[0], "/")[0]))
+					}
+				})
+			})
+
+			Describe("traffic", func() {
+				hostLocal := func(rangeStart, rangeEnd string) string {
+					return fmt.Sprintf(`{"type":"host-local","subnet":"10.10.10.0/24","rangeStart":"%s","rangeEnd":"%s"}`, rangeStart, rangeEnd)
+				}
+
+				createNetwork := func(sriovNetwork *sriovv1.SriovNetwork) {
+					err := registry.Create(sriovNetwork)
+					Expect(err).ToNot(HaveOccurred())
+					netAttDef := &netattdefv1.NetworkAttachmentDefinition{}
+					Eventually(func() error {
+						return clients.Get(context.Background(), runtimeclient.ObjectKey{Name: sriovNetwork.Name, Namespace: namespaces.Test}, netAttDef)
+					}, 10*time.Second, 1*time.Second).ShouldNot(HaveOccurred())
+				}
+
+				startEndpoint := func(network string) *traffic.Endpoint {
+					endpoint, err := traffic.StartEndpoint(clients, registry, pod.NetworkSelectionElement{Name: network}, pod.OnNode(node))
+					Expect(err).ToNot(HaveOccurred())
+					return endpoint
+				}
+
+				It("Should pass traffic between pods attached to the same network", func() {
+					createNetwork(&sriovv1.SriovNetwork{ObjectMeta: metav1.ObjectMeta{Name: "trafficnetwork", Namespace: operatorNamespace},
+						Spec: sriovv1.SriovNetworkSpec{
+							ResourceName:     "testresource",
+							IPAM:             hostLocal("10.10.10.171", "10.10.10.181"),
+							NetworkNamespace: namespaces.Test,
+						}})
+
+					client := startEndpoint("trafficnetwork")
+					server := startEndpoint("trafficnetwork")
+
+					res, err := traffic.Ping(clients, client, server.IP, 5)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(res.Received).To(Equal(res.Transmitted))
+					Expect(res.Loss).To(BeZero())
+				})
+
+				It("Should isolate pods attached to networks with different vlans", func() {
+					for i, vlan := range []int{100, 200} {
+						createNetwork(&sriovv1.SriovNetwork{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("vlan%dnetwork", vlan), Namespace: operatorNamespace},
+							Spec: sriovv1.SriovNetworkSpec{
+								ResourceName: "testresource",
+								// the same subnet, so only the vlan keeps the pods apart
+								IPAM:             hostLocal(fmt.Sprintf("10.10.10.%d", 171+i*20), fmt.Sprintf("10.10.10.%d", 181+i*20)),
+								Vlan:             vlan,
+								NetworkNamespace: namespaces.Test,
+							}})
+					}
+
+					client := startEndpoint("vlan100network")
+					sameVlan := startEndpoint("vlan100network")
+					otherVlan := startEndpoint("vlan200network")
+
+					Expect(traffic.Reachable(clients, client, sameVlan.IP)).To(BeTrue())
+					Expect(traffic.Reachable(clients, client, otherVlan.IP)).To(BeFalse())
+				})
+
+				// forgedMACReachable tells if a pod of the network still reaches another one
+				// once it forged its mac. The spec is skipped when the vf rejects the mac.
+				forgedMACReachable := func(network, mac string) bool {
+					client := startEndpoint(network)
+					server := startEndpoint(network)
+					Expect(traffic.Reachable(clients, client, server.IP)).To(BeTrue())
+
+					err := traffic.ForgeMAC(clients, client, mac)
+					if traffic.IsMACRejected(err) {
+						Skip(err.Error())
+					}
+					Expect(err).ToNot(HaveOccurred())
+					// otherwise the server keeps replying to the former mac
+					Expect(traffic.FlushNeighbors(clients, server)).To(Succeed())
+
+					reachable, err := traffic.Reachable(clients, client, server.IP)
+					Expect(err).ToNot(HaveOccurred())
+					return reachable
+				}
+
+				It("Should drop the frames with a forged mac when spoofChk is on", func() {
+					for i, spoofChk := range []string{"off", "on"} {
+						createNetwork(&sriovv1.SriovNetwork{ObjectMeta: metav1.ObjectMeta{Name: "spoof" + spoofChk + "trafficnetwork", Namespace: operatorNamespace},
+							Spec: sriovv1.SriovNetworkSpec{
+								ResourceName: "testresource",
+								// the pods of both networks share the segment
+								IPAM:             hostLocal(fmt.Sprintf("10.10.10.%d", 171+i*20), fmt.Sprintf("10.10.10.%d", 181+i*20)),
+								SpoofChk:         spoofChk,
+								NetworkNamespace: namespaces.Test,
+							}})
+					}
+
+					By("checking the forged mac passes with spoofChk off")
+					Expect(forgedMACReachable("spoofofftrafficnetwork", "20:04:0f:f1:88:fe")).To(BeTrue())
+
+					By("checking the forged mac is dropped with spoofChk on")
+					Expect(forgedMACReachable("spoofontrafficnetwork", "20:04:0f:f1:88:ff")).To(BeFalse())
 				})
 			})
 		})
@@ -634,14 +766,11 @@ var _ = Describe("operator", func() {
 					},
 				}
 
-				err = clients.Create(context.Background(), nodePolicy)
+				err = registry.Create(nodePolicy)
 				Expect(err).ToNot(HaveOccurred())
 
-				Eventually(func() bool {
-					stable, err := cluster.SriovStable(operatorNamespace, clients)
-					Expect(err).ToNot(HaveOccurred())
-					return stable
-				}, 5*time.Minute, 1*time.Second).Should(Equal(true))
+				err = cluster.WaitForSriovStable(operatorNamespace, clients, 5*time.Minute, GinkgoWriter)
+				Expect(err).ToNot(HaveOccurred())
 
 				Eventually(func() int64 {
 					testedNode, err := clients.Nodes().Get(node, metav1.GetOptions{})
@@ -661,10 +790,12 @@ var _ = Describe("operator", func() {
 						IPAM:             `{"type":"host-local","subnet":"10.10.10.0/24","rangeStart":"10.10.10.171","rangeEnd":"10.10.10.181","routes":[{"dst":"0.0.0.0/0"}],"gateway":"10.10.10.1"}`,
 						NetworkNamespace: namespaces.Test,
 					}}
-				err = clients.Create(context.Background(), sriovNetwork)
+				err = registry.Create(sriovNetwork)
 				Expect(err).ToNot(HaveOccurred())
 
 				podDefinition := pod.DefineWithNetworks([]string{sriovNetwork.Name})
+				err = registry.Track(podDefinition)
+				Expect(err).ToNot(HaveOccurred())
 				created, err := clients.Pods(namespaces.Test).Create(podDefinition)
 				Expect(err).ToNot(HaveOccurred())
 
@@ -704,55 +835,48 @@ var _ = Describe("operator", func() {
 	})
 })
 
-// findPodVFInHost goes over the virtual functions related to the physical function that was provided on the host
-// and return the virtual function id if founds or error if not.
-// return error also if more than one virtual functions is missing in the host network namespace
-func findPodVFInHost(pfName string, numVfs int, podObj *corev1.Pod) (int, error) {
-	found := false
-	vfID := 0
-	for idx := 0; idx < numVfs; idx++ {
-		stdout, _, err := pod.ExecCommand(clients, podObj, "ip", "link", "show", fmt.Sprintf("%sv%d", pfName, idx))
-		if err != nil && strings.Contains(stdout, "does not exist") {
-			// Validate that only one virtual function was moved
-			if found {
-				return vfID, fmt.Errorf("found more that one virtual function was moved from the host network namespace")
-			}
-
-			found = true
-			vfID = idx
+// findPodVFInHost goes over the virtual functions of the physical function, as seen from the host sysfs,
+// and returns the id of the one without a netdevice in the host network namespace.
+// It returns an error if none or more than one virtual function was moved.
+func findPodVFInHost(pfName string, debugger *pod.Debugger) (int, error) {
+	vfs, err := debugger.VFs(pfName)
+	if err != nil {
+		return 0, err
+	}
+	moved := []int{}
+	for _, vf := range vfs {
+		if vf.Netdev == "" {
+			moved = append(moved, vf.ID)
 		}
 	}
-
-	if found {
-		return vfID, nil
+	switch len(moved) {
+	case 0:
+		return 0, fmt.Errorf("failed to find the vf number that was moved into the pod")
+	case 1:
+		return moved[0], nil
 	}
+	return moved[0], fmt.Errorf("found more that one virtual function was moved from the host network namespace")
+}
 
-	return vfID, fmt.Errorf("failed to find the vf number that was moved into the pod")
+// hostLink returns the link of the node with the given name, read with the ip
+// of the host as the one of the debug image may not report the vf details.
+func hostLink(debugger *pod.Debugger, name string) (*netinspect.Link, error) {
+	out, err := debugger.Run("ip", "-d", "-j", "link", "show", name)
+	if err != nil {
+		return nil, err
+	}
+	links, err := netinspect.ParseLinks(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(links) != 1 {
+		return nil, fmt.Errorf("Expected one link named %s on %s, found %d", name, debugger.Node, len(links))
+	}
+	return &links[0], nil
 }
 
 func daemonsScheduledOnNodes(selector string) bool {
-	nn, err := clients.Nodes().List(metav1.ListOptions{
-		LabelSelector: selector,
-	})
-	nodes := nn.Items
-
-	daemons, err := clients.Pods(operatorNamespace).List(metav1.ListOptions{LabelSelector: "app=sriov-network-config-daemon"})
+	scheduled, err := cluster.DaemonsScheduledOnNodes(clients, operatorNamespace, selector)
 	Expect(err).ToNot(HaveOccurred())
-	for _, d := range daemons.Items {
-		foundNode := false
-		for i, n := range nodes {
-			if d.Spec.NodeName == n.Name {
-				foundNode = true
-				// Removing the element from the list as we want to make sure
-				// the daemons are running on different nodes
-				nodes = append(nodes[:i], nodes[i+1:]...)
-				break
-			}
-		}
-		if !foundNode {
-			return false
-		}
-	}
-	return true
-
+	return scheduled
 }
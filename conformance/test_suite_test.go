@@ -1,8 +1,11 @@
 package conformance
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -11,25 +14,39 @@ import (
 	. "github.com/onsi/gomega"
 
 	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	"github.com/openshift/sriov-tests/pkg/util/artifacts"
+	"github.com/openshift/sriov-tests/pkg/util/cassette"
+	"github.com/openshift/sriov-tests/pkg/util/cleanup"
 	testclient "github.com/openshift/sriov-tests/pkg/util/client"
 	"github.com/openshift/sriov-tests/pkg/util/namespaces"
+	"github.com/openshift/sriov-tests/pkg/util/pod"
+	"github.com/openshift/sriov-tests/pkg/util/snapshot"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 )
 
 var (
 	junitPath         *string
+	snapshotPath      *string
 	operatorNamespace string
 	clients           *testclient.ClientSet
+	registry          *cleanup.Registry
+	initialState      *snapshot.Snapshot
+	recorder          *cassette.Recorder
+	collector         *artifacts.Collector
+	specStart         time.Time
 )
 
 func init() {
 	junitPath = flag.String("junit", "junit.xml", "the path for the junit format report")
+	snapshotPath = flag.String("snapshot", "", "the path the SR-IOV configuration found before the run is written to")
 	operatorNamespace = os.Getenv("OPERATOR_NAMESPACE")
 	if operatorNamespace == "" {
 		operatorNamespace = "openshift-sriov-network-operator"
 	}
+	if testNamespace := os.Getenv("TEST_NAMESPACE"); testNamespace != "" {
+		namespaces.Test = testNamespace
+	}
 }
 
 func TestTest(t *testing.T) {
@@ -43,9 +60,17 @@ func TestTest(t *testing.T) {
 }
 
 var _ = BeforeSuite(func() {
-	clients = testclient.New("", func(scheme *runtime.Scheme) {
-		sriovv1.AddToScheme(scheme)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var err error
+	// SRIOV_CASSETTE_MODE records the api calls of each spec, or replays them without a cluster
+	recorder, err = cassette.FromEnv()
+	Expect(err).ToNot(HaveOccurred())
+	clients, err = recorder.Clients(ctx, testclient.Options{
+		AddToScheme:           sriovv1.AddToScheme,
+		AllowMissingOpenShift: true,
 	})
+	Expect(err).ToNot(HaveOccurred())
 
 	// create test namespace
 	ns := &corev1.Namespace{
@@ -53,12 +78,75 @@ var _ = BeforeSuite(func() {
 			Name: namespaces.Test,
 		},
 	}
-	_, err := clients.Namespaces().Create(ns)
+	_, err = clients.Namespaces().Create(ns)
+	Expect(err).ToNot(HaveOccurred())
+
+	registry = cleanup.New(clients, operatorNamespace, namespaces.Test)
+	// the artifacts of the failed specs are written next to the junit report
+	collector = artifacts.New(clients, filepath.Join(filepath.Dir(*junitPath), "artifacts"), operatorNamespace, namespaces.Test)
+
+	initialState, err = snapshot.Take(clients, operatorNamespace, "sriovenabled")
 	Expect(err).ToNot(HaveOccurred())
+	if *snapshotPath != "" {
+		err = initialState.WriteFile(*snapshotPath)
+		Expect(err).ToNot(HaveOccurred())
+	}
 })
 
+// AfterSuite runs on interrupt too, so the configuration is restored
+// even when the run is stopped midway. Every step is attempted, a failing
+// one doesn't prevent the others from running.
 var _ = AfterSuite(func() {
+	// deferred, so the suite cassette records the cleanup and is written
+	// even when it fails
+	defer func() {
+		Expect(recorder.Close()).To(Succeed())
+	}()
+
+	failures := []string{}
+	step := func(name string, err error) {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	step("cleaning up the debug pods", pod.CleanupDebuggers(clients))
+	step("cleaning up the test objects", registry.Clean())
+	if initialState != nil {
+		step("restoring the initial state", initialState.Restore(clients))
+	}
 	err := clients.Namespaces().Delete(namespaces.Test, &metav1.DeleteOptions{})
+	step("deleting the test namespace", err)
+	if err == nil {
+		step("waiting for the test namespace deletion", namespaces.WaitForDeletion(clients, namespaces.Test, 5*time.Minute))
+	}
+	Expect(failures).To(BeEmpty())
+})
+
+var _ = BeforeEach(func() {
+	specStart = time.Now()
+	err := recorder.Start(CurrentGinkgoTestDescription().FullTestText)
+	Expect(err).ToNot(HaveOccurred())
+})
+
+// JustAfterEach runs before the AfterEach blocks of the nested containers, so
+// the artifacts show the failing state, not the one they restore.
+var _ = JustAfterEach(func() {
+	// a replayed run has no cluster to collect from
+	description := CurrentGinkgoTestDescription()
+	if !description.Failed || (recorder != nil && recorder.Mode == cassette.Replay) {
+		return
+	}
+	path, err := collector.Collect(description.FullTestText, specStart)
+	if err != nil {
+		fmt.Fprintln(GinkgoWriter, err)
+	}
+	if path != "" {
+		fmt.Fprintf(GinkgoWriter, "Artifacts of the failed spec written to %s\n", path)
+	}
+})
+
+var _ = AfterEach(func() {
+	err := recorder.Stop()
 	Expect(err).ToNot(HaveOccurred())
-	err = namespaces.WaitForDeletion(clients, namespaces.Test, 5*time.Minute)
 })
@@ -0,0 +1,440 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+)
+
+const (
+	// ResourcePrefix is the prefix the device plugin uses when advertising the resources.
+	ResourcePrefix = "openshift.io/"
+	// SriovCapableLabel is the label node feature discovery sets on nodes with sriov capable nics.
+	SriovCapableLabel = "feature.node.kubernetes.io/network-sriov.capable"
+
+	syncStatusInProgress = "InProgress"
+	syncStatusSucceeded  = "Succeeded"
+	syncStatusFailed     = "Failed"
+)
+
+// PF describes a fake physical function exposed by a simulated node.
+type PF struct {
+	Name       string
+	PciAddress string
+	Vendor     string
+	DeviceID   string
+	Driver     string
+	// VFDriver is the driver the vfs bound to the kernel get. When empty
+	// it's derived from the driver of the PF.
+	VFDriver  string
+	Mac       string
+	Mtu       int
+	LinkSpeed string
+	TotalVfs  int
+}
+
+// DefaultPFs returns two ports of a ConnectX-4 Lx nic, which is a device
+// the tests know how to deal with.
+func DefaultPFs() []PF {
+	return []PF{
+		{
+			Name:       "ens1f0",
+			PciAddress: "0000:3b:00.0",
+			Vendor:     "15b3",
+			DeviceID:   "1015",
+			Driver:     "mlx5_core",
+			Mac:        "0c:42:a1:00:00:00",
+			Mtu:        1500,
+			LinkSpeed:  "25000 Mb/s",
+			TotalVfs:   8,
+		},
+		{
+			Name:       "ens1f1",
+			PciAddress: "0000:3b:00.1",
+			Vendor:     "15b3",
+			DeviceID:   "1015",
+			Driver:     "mlx5_core",
+			Mac:        "0c:42:a1:00:00:01",
+			Mtu:        1500,
+			LinkSpeed:  "25000 Mb/s",
+			TotalVfs:   8,
+		},
+	}
+}
+
+// Simulator acts as the sriov-network-config-daemon on a set of nodes
+// backed by fake PFs, so the operator can be exercised without sriov hardware.
+type Simulator struct {
+	clients   *testclient.ClientSet
+	namespace string
+	nodes     map[string][]PF
+	// SyncDelay is how long the simulated configuration takes.
+	SyncDelay time.Duration
+
+	mu sync.Mutex
+	// applied records the last spec applied to each node.
+	applied map[string]sriovv1.SriovNetworkNodeStateSpec
+}
+
+// New returns a simulator handling the node states of the given nodes, keyed by node name.
+func New(clients *testclient.ClientSet, operatorNamespace string, nodes map[string][]PF) *Simulator {
+	return &Simulator{
+		clients:   clients,
+		namespace: operatorNamespace,
+		nodes:     nodes,
+		SyncDelay: time.Second,
+		applied:   make(map[string]sriovv1.SriovNetworkNodeStateSpec),
+	}
+}
+
+// Run labels the simulated nodes as sriov capable and syncs their node states
+// until the context is done.
+func (s *Simulator) Run(ctx context.Context) error {
+	for node := range s.nodes {
+		err := s.labelNode(node)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		err := s.watch(ctx)
+		if err != nil {
+			glog.Errorf("simulator: watch failed, retrying: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (s *Simulator) watch(ctx context.Context) error {
+	states, err := s.clients.SriovNetworkNodeStates(s.namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("Failed to list node states %v", err)
+	}
+	for i := range states.Items {
+		s.sync(&states.Items[i])
+	}
+
+	w, err := s.clients.SriovNetworkNodeStates(s.namespace).Watch(metav1.ListOptions{
+		ResourceVersion: states.ResourceVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to watch node states %v", err)
+	}
+	defer w.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return nil
+			}
+			if event.Type != watch.Added && event.Type != watch.Modified {
+				continue
+			}
+			state, ok := event.Object.(*sriovv1.SriovNetworkNodeState)
+			if !ok {
+				continue
+			}
+			s.sync(state)
+		}
+	}
+}
+
+func (s *Simulator) sync(state *sriovv1.SriovNetworkNodeState) {
+	if _, ok := s.nodes[state.Name]; !ok {
+		return
+	}
+	err := s.Sync(state.Name)
+	if err != nil {
+		glog.Errorf("simulator: failed to sync node %s: %v", state.Name, err)
+	}
+}
+
+// Sync applies the spec of the node state of the given node to its fake PFs,
+// reporting the result in the node state status and in the node capacity.
+func (s *Simulator) Sync(nodeName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pfs, ok := s.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("Node %s is not simulated", nodeName)
+	}
+	state, err := s.clients.SriovNetworkNodeStates(s.namespace).Get(nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	applied, found := s.applied[nodeName]
+	if found && len(state.Status.Interfaces) > 0 && reflect.DeepEqual(applied, state.Spec) {
+		return nil
+	}
+
+	// The daemon reports the interfaces first, so the operator
+	// can render the spec against them.
+	if len(state.Status.Interfaces) == 0 {
+		state.Status.Interfaces = interfacesStatus(pfs, nil)
+		state.Status.SyncStatus = syncStatusSucceeded
+		state, err = s.updateStatus(state)
+		if err != nil {
+			return err
+		}
+	}
+
+	state.Status.SyncStatus = syncStatusInProgress
+	state.Status.LastSyncError = ""
+	state, err = s.updateStatus(state)
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(s.SyncDelay)
+
+	err = validateSpec(pfs, state.Spec.Interfaces)
+	if err != nil {
+		state.Status.SyncStatus = syncStatusFailed
+		state.Status.LastSyncError = err.Error()
+		_, updateErr := s.updateStatus(state)
+		if updateErr != nil {
+			return updateErr
+		}
+		s.applied[nodeName] = *state.Spec.DeepCopy()
+		return nil
+	}
+
+	err = s.updateCapacity(nodeName, state.Spec.Interfaces)
+	if err != nil {
+		return err
+	}
+
+	state.Status.Interfaces = interfacesStatus(pfs, state.Spec.Interfaces)
+	state.Status.SyncStatus = syncStatusSucceeded
+	state.Status.LastSyncError = ""
+	_, err = s.updateStatus(state)
+	if err != nil {
+		return err
+	}
+	s.applied[nodeName] = *state.Spec.DeepCopy()
+	return nil
+}
+
+// updateStatus updates the status of the node state, falling back to a plain
+// update when the crd does not enable the status subresource.
+func (s *Simulator) updateStatus(state *sriovv1.SriovNetworkNodeState) (*sriovv1.SriovNetworkNodeState, error) {
+	res, err := s.clients.SriovNetworkNodeStates(s.namespace).UpdateStatus(state)
+	if errors.IsNotFound(err) {
+		res, err = s.clients.SriovNetworkNodeStates(s.namespace).Update(state)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to update the status of node state %s %v", state.Name, err)
+	}
+	return res, nil
+}
+
+func (s *Simulator) labelNode(nodeName string) error {
+	patch := fmt.Sprintf(`{"metadata":{"labels":{%q:"true"}}}`, SriovCapableLabel)
+	_, err := s.clients.Nodes().Patch(nodeName, types.StrategicMergePatchType, []byte(patch))
+	if err != nil {
+		return fmt.Errorf("Failed to label node %s %v", nodeName, err)
+	}
+	return nil
+}
+
+// updateCapacity advertises the resources of the vf groups in the node status,
+// as the device plugin would do. Resources no longer configured are set to zero.
+func (s *Simulator) updateCapacity(nodeName string, interfaces sriovv1.Interfaces) error {
+	node, err := s.clients.Nodes().Get(nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	resources := map[string]string{}
+	for name := range node.Status.Capacity {
+		if strings.HasPrefix(string(name), ResourcePrefix) {
+			resources[string(name)] = "0"
+		}
+	}
+	for name, count := range resourceCount(interfaces) {
+		resources[ResourcePrefix+name] = strconv.Itoa(count)
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{
+			"capacity":    resources,
+			"allocatable": resources,
+		},
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = s.clients.Nodes().PatchStatus(nodeName, data)
+	if err != nil {
+		return fmt.Errorf("Failed to patch the capacity of node %s %v", nodeName, err)
+	}
+	return nil
+}
+
+func validateSpec(pfs []PF, interfaces sriovv1.Interfaces) error {
+	for _, iface := range interfaces {
+		pf := findPF(pfs, iface.PciAddress)
+		if pf == nil {
+			return fmt.Errorf("pf %s not found", iface.PciAddress)
+		}
+		if iface.NumVfs > pf.TotalVfs {
+			return fmt.Errorf("pf %s supports %d vfs, %d requested", pf.Name, pf.TotalVfs, iface.NumVfs)
+		}
+		for _, group := range iface.VfGroups {
+			if group.DeviceType != "" && group.DeviceType != "netdevice" && group.DeviceType != "vfio-pci" {
+				return fmt.Errorf("unsupported device type %s for pf %s", group.DeviceType, pf.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// resourceCount returns the number of vfs of each resource.
+func resourceCount(interfaces sriovv1.Interfaces) map[string]int {
+	res := map[string]int{}
+	for _, iface := range interfaces {
+		for _, group := range iface.VfGroups {
+			if _, ok := res[group.ResourceName]; !ok {
+				res[group.ResourceName] = 0
+			}
+			for i := 0; i < iface.NumVfs; i++ {
+				if sriovv1.IndexInRange(i, group.VfRange) {
+					res[group.ResourceName]++
+				}
+			}
+		}
+	}
+	return res
+}
+
+// interfacesStatus returns the status of the fake PFs after applying the given spec.
+func interfacesStatus(pfs []PF, interfaces sriovv1.Interfaces) sriovv1.InterfaceExts {
+	res := sriovv1.InterfaceExts{}
+	for _, pf := range pfs {
+		status := sriovv1.InterfaceExt{
+			InterfaceProperty: sriovv1.InterfaceProperty{
+				Name:       pf.Name,
+				Mac:        pf.Mac,
+				Driver:     pf.Driver,
+				PciAddress: pf.PciAddress,
+				Vendor:     pf.Vendor,
+				DeviceID:   pf.DeviceID,
+				Mtu:        pf.Mtu,
+			},
+			LinkSpeed: pf.LinkSpeed,
+			TotalVfs:  pf.TotalVfs,
+		}
+
+		for _, iface := range interfaces {
+			if iface.PciAddress != pf.PciAddress {
+				continue
+			}
+			if iface.Mtu > 0 {
+				status.Mtu = iface.Mtu
+			}
+			status.NumVfs = iface.NumVfs
+			for i := 0; i < iface.NumVfs; i++ {
+				status.VFs = append(status.VFs, virtualFunction(pf, iface, i, status.Mtu))
+			}
+		}
+		res = append(res, status)
+	}
+	return res
+}
+
+func virtualFunction(pf PF, iface sriovv1.Interface, id, mtu int) sriovv1.VirtualFunction {
+	deviceType := "netdevice"
+	for _, group := range iface.VfGroups {
+		if sriovv1.IndexInRange(id, group.VfRange) && group.DeviceType != "" {
+			deviceType = group.DeviceType
+		}
+	}
+
+	vf := sriovv1.VirtualFunction{
+		InterfaceProperty: sriovv1.InterfaceProperty{
+			PciAddress: vfPciAddress(pf.PciAddress, id),
+			Vendor:     pf.Vendor,
+			DeviceID:   sriovv1.SriovPfVfMap[pf.DeviceID],
+		},
+		VfID: id,
+	}
+	if deviceType == "vfio-pci" {
+		vf.Driver = "vfio-pci"
+		return vf
+	}
+	vf.Name = fmt.Sprintf("%sv%d", pf.Name, id)
+	vf.Driver = vfDriver(pf)
+	vf.Mtu = mtu
+	return vf
+}
+
+// vfPciAddress generates a stable pci address for the vf on the buses following
+// the pf one. Each pf function gets 64 vfs worth of addresses, so the vfs of the
+// ports of a nic do not collide.
+func vfPciAddress(pfAddress string, id int) string {
+	fields := strings.Split(pfAddress, ":")
+	if len(fields) != 3 {
+		return fmt.Sprintf("%s-vf%d", pfAddress, id)
+	}
+	bus, err := strconv.ParseInt(fields[1], 16, 32)
+	if err != nil {
+		return fmt.Sprintf("%s-vf%d", pfAddress, id)
+	}
+	var device, function int64
+	if _, err := fmt.Sscanf(fields[2], "%x.%d", &device, &function); err != nil {
+		return fmt.Sprintf("%s-vf%d", pfAddress, id)
+	}
+	// 32 devices of 8 functions per bus
+	index := (device*8+function)*64 + int64(id)
+	return fmt.Sprintf("%s:%02x:%02x.%d", fields[0], bus+1+index/256, index%256/8, index%8)
+}
+
+func vfDriver(pf PF) string {
+	if pf.VFDriver != "" {
+		return pf.VFDriver
+	}
+	switch pf.Driver {
+	case "i40e":
+		return "iavf"
+	case "ixgbe":
+		return "ixgbevf"
+	}
+	return pf.Driver
+}
+
+func findPF(pfs []PF, pciAddress string) *PF {
+	for i := range pfs {
+		if pfs[i].PciAddress == pciAddress {
+			return &pfs[i]
+		}
+	}
+	return nil
+}
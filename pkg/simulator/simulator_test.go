@@ -0,0 +1,197 @@
+package simulator
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+)
+
+var _ = Describe("Simulator", func() {
+	pfs := DefaultPFs()
+	spec := sriovv1.Interfaces{{
+		Name:       "ens1f0",
+		PciAddress: "0000:3b:00.0",
+		NumVfs:     5,
+		Mtu:        9000,
+		VfGroups: []sriovv1.VfGroup{
+			{ResourceName: "res1", DeviceType: "netdevice", VfRange: "2-4"},
+			{ResourceName: "res2", DeviceType: "vfio-pci", VfRange: "0-1"},
+		},
+	}}
+
+	It("should report the vfs matching the spec", func() {
+		status := interfacesStatus(pfs, spec)
+		Expect(status).To(HaveLen(2))
+
+		configured := status[0]
+		Expect(configured.NumVfs).To(Equal(5))
+		Expect(configured.Mtu).To(Equal(9000))
+		Expect(configured.TotalVfs).To(Equal(8))
+		Expect(configured.VFs).To(HaveLen(5))
+
+		Expect(configured.VFs[0].Driver).To(Equal("vfio-pci"))
+		Expect(configured.VFs[0].Name).To(BeEmpty())
+		Expect(configured.VFs[0].PciAddress).To(Equal("0000:3c:00.0"))
+		Expect(configured.VFs[0].DeviceID).To(Equal("1016"))
+
+		Expect(configured.VFs[3].VfID).To(Equal(3))
+		Expect(configured.VFs[3].Name).To(Equal("ens1f0v3"))
+		Expect(configured.VFs[3].Driver).To(Equal("mlx5_core"))
+		Expect(configured.VFs[3].Mtu).To(Equal(9000))
+
+		Expect(status[1].NumVfs).To(Equal(0))
+		Expect(status[1].VFs).To(BeEmpty())
+	})
+
+	It("should give distinct pci addresses to the vfs of each pf", func() {
+		Expect(vfPciAddress("0000:3b:00.0", 9)).To(Equal("0000:3c:01.1"))
+		Expect(vfPciAddress("0000:3b:00.1", 0)).To(Equal("0000:3c:08.0"))
+		Expect(vfPciAddress("0000:3b:00.1", 9)).To(Equal("0000:3c:09.1"))
+		Expect(vfPciAddress("0000:3b:04.0", 0)).To(Equal("0000:44:00.0"))
+	})
+
+	It("should count the vfs of each resource", func() {
+		Expect(resourceCount(spec)).To(Equal(map[string]int{"res1": 3, "res2": 2}))
+	})
+
+	It("should reject more vfs than the pf supports", func() {
+		tooMany := spec.DeepCopy()
+		tooMany[0].NumVfs = 16
+		Expect(validateSpec(pfs, tooMany)).To(MatchError("pf ens1f0 supports 8 vfs, 16 requested"))
+	})
+
+	It("should reject unknown pfs", func() {
+		unknown := spec.DeepCopy()
+		unknown[0].PciAddress = "0000:5e:00.0"
+		Expect(validateSpec(pfs, unknown)).To(MatchError("pf 0000:5e:00.0 not found"))
+	})
+
+	It("should derive the vf driver from the pf one", func() {
+		Expect(vfDriver(PF{Driver: "i40e"})).To(Equal("iavf"))
+		Expect(vfDriver(PF{Driver: "ixgbe"})).To(Equal("ixgbevf"))
+		Expect(vfDriver(PF{Driver: "mlx5_core"})).To(Equal("mlx5_core"))
+		Expect(vfDriver(PF{Driver: "ice", VFDriver: "iavf"})).To(Equal("iavf"))
+	})
+})
+
+var _ = Describe("Run", func() {
+	const namespace = "sriov"
+
+	var (
+		clients *testclient.ClientSet
+		stop    func()
+		cancel  context.CancelFunc
+		done    chan error
+		w       watch.Interface
+	)
+
+	// syncStatuses returns the sync statuses of the next sync, from InProgress
+	// to its outcome.
+	syncStatuses := func() []string {
+		statuses := []string{}
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case ev := <-w.ResultChan():
+				if ev.Type != watch.Modified {
+					continue
+				}
+				status := ev.Object.(*sriovv1.SriovNetworkNodeState).Status.SyncStatus
+				if status != syncStatusInProgress && len(statuses) == 0 {
+					continue
+				}
+				statuses = append(statuses, status)
+				if status != syncStatusInProgress {
+					return statuses
+				}
+			case <-timeout:
+				Fail("the sync did not end")
+			}
+		}
+	}
+
+	nodeState := func() *sriovv1.SriovNetworkNodeState {
+		state, err := clients.SriovNetworkNodeStates(namespace).Get("worker-0", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		return state
+	}
+
+	BeforeEach(func() {
+		var err error
+		clients, stop, err = testclient.NewFake(
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}},
+			&sriovv1.SriovNetworkNodeState{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: namespace}},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		w, err = clients.SriovNetworkNodeStates(namespace).Watch(metav1.ListOptions{ResourceVersion: nodeState().ResourceVersion})
+		Expect(err).ToNot(HaveOccurred())
+
+		sim := New(clients, namespace, map[string][]PF{"worker-0": DefaultPFs()})
+		sim.SyncDelay = 10 * time.Millisecond
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		done = make(chan error, 1)
+		go func() {
+			done <- sim.Run(ctx)
+		}()
+		// the pfs are reported, then the empty spec is applied
+		Expect(syncStatuses()).To(Equal([]string{syncStatusInProgress, syncStatusSucceeded}))
+	})
+
+	AfterEach(func() {
+		w.Stop()
+		cancel()
+		Eventually(done, 5*time.Second).Should(Receive(BeNil()))
+		stop()
+	})
+
+	applySpec := func(interfaces sriovv1.Interfaces) []string {
+		state := nodeState()
+		state.Spec.Interfaces = interfaces
+		_, err := clients.SriovNetworkNodeStates(namespace).Update(state)
+		Expect(err).ToNot(HaveOccurred())
+		return syncStatuses()
+	}
+
+	It("labels the nodes and reports their pfs", func() {
+		node, err := clients.Nodes().Get("worker-0", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(node.Labels).To(HaveKeyWithValue(SriovCapableLabel, "true"))
+		Expect(nodeState().Status.Interfaces).To(HaveLen(2))
+	})
+
+	It("applies the spec and advertises the resources", func() {
+		statuses := applySpec(sriovv1.Interfaces{{
+			Name:       "ens1f0",
+			PciAddress: "0000:3b:00.0",
+			NumVfs:     4,
+			VfGroups:   []sriovv1.VfGroup{{ResourceName: "res1", DeviceType: "netdevice", VfRange: "0-3"}},
+		}})
+		Expect(statuses).To(Equal([]string{syncStatusInProgress, syncStatusSucceeded}))
+		Expect(nodeState().Status.Interfaces[0].NumVfs).To(Equal(4))
+
+		node, err := clients.Nodes().Get("worker-0", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		capacity := node.Status.Capacity[corev1.ResourceName(ResourcePrefix+"res1")]
+		Expect(capacity.Value()).To(BeEquivalentTo(4))
+	})
+
+	It("fails the specs the pfs cannot apply", func() {
+		statuses := applySpec(sriovv1.Interfaces{{
+			Name:       "ens1f0",
+			PciAddress: "0000:3b:00.0",
+			NumVfs:     16,
+		}})
+		Expect(statuses).To(Equal([]string{syncStatusInProgress, syncStatusFailed}))
+		Expect(nodeState().Status.LastSyncError).To(Equal("pf ens1f0 supports 8 vfs, 16 requested"))
+	})
+})
@@ -0,0 +1,294 @@
+// Package artifacts dumps the state of the SR-IOV operator when a spec fails,
+// so the failure can be triaged without access to the cluster: the operator
+// custom resources, the device plugin config, the nodes resources, the logs
+// of the operator pods, the events and the links of the tested nodes.
+package artifacts
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	netattdefv1 "github.com/openshift/sriov-network-operator/pkg/apis/k8s/v1"
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+	"github.com/openshift/sriov-tests/pkg/util/deviceplugin"
+	"github.com/openshift/sriov-tests/pkg/util/pod"
+)
+
+const hostnameLabel = "kubernetes.io/hostname"
+
+var unsafeChars = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// Collector writes the artifacts of the failed specs, one directory per spec.
+type Collector struct {
+	Dir               string
+	OperatorNamespace string
+	// Namespaces are the namespaces the events and the tested pods are taken from,
+	// on top of the operator one.
+	Namespaces []string
+
+	cs *testclient.ClientSet
+	// ipLink returns the links of the node, through a debug pod by default.
+	ipLink func(cs *testclient.ClientSet, node string) (string, error)
+}
+
+// New returns a collector writing under dir.
+func New(cs *testclient.ClientSet, dir, operatorNamespace string, namespaces ...string) *Collector {
+	return &Collector{
+		Dir:               dir,
+		OperatorNamespace: operatorNamespace,
+		Namespaces:        namespaces,
+		cs:                cs,
+		ipLink:            debugIPLink,
+	}
+}
+
+// Path returns the directory the artifacts of the spec are written to.
+func (c *Collector) Path(spec string) string {
+	name := strings.Trim(unsafeChars.ReplaceAllString(strings.ToLower(spec), "-"), "-")
+	if len(name) > 120 {
+		name = name[:120]
+	}
+	return filepath.Join(c.Dir, name)
+}
+
+// Collect dumps the artifacts of the spec, the logs and events being limited to
+// the ones since the spec started. Every artifact is attempted: the ones that
+// could not be collected are listed in errors.txt and returned as one error.
+func (c *Collector) Collect(spec string, since time.Time) (string, error) {
+	dir := c.Path(spec)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("Failed to create the artifacts directory %s %v", dir, err)
+	}
+
+	failures := []string{}
+	step := func(name string, collect func() error) {
+		if err := collect(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	write := func(name string, data []byte) error {
+		return ioutil.WriteFile(filepath.Join(dir, name), data, 0644)
+	}
+	writeYAML := func(name string, obj interface{}) error {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("Failed to encode %s %v", name, err)
+		}
+		return write(name, data)
+	}
+	list := func(name string, list runtime.Object) func() error {
+		return func() error {
+			if err := c.cs.List(context.Background(), list, runtimeclient.InNamespace(c.OperatorNamespace)); err != nil {
+				return err
+			}
+			return writeYAML(name, list)
+		}
+	}
+
+	step("node states", list("nodestates.yaml", &sriovv1.SriovNetworkNodeStateList{}))
+	step("policies", list("policies.yaml", &sriovv1.SriovNetworkNodePolicyList{}))
+	step("networks", list("networks.yaml", &sriovv1.SriovNetworkList{}))
+	step("operator config", list("operatorconfig.yaml", &sriovv1.SriovOperatorConfigList{}))
+	step("network attachment definitions", func() error {
+		res := []netattdefv1.NetworkAttachmentDefinition{}
+		for _, ns := range c.allNamespaces() {
+			netAttDefs := &netattdefv1.NetworkAttachmentDefinitionList{}
+			if err := c.cs.List(context.Background(), netAttDefs, runtimeclient.InNamespace(ns)); err != nil {
+				return err
+			}
+			res = append(res, netAttDefs.Items...)
+		}
+		return writeYAML("networkattachmentdefinitions.yaml", res)
+	})
+	step("device plugin config", func() error {
+		cm, err := c.cs.ConfigMaps(c.OperatorNamespace).Get(deviceplugin.ConfigMapName, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return write("deviceplugin.txt", []byte("the device plugin config map is missing\n"))
+		}
+		if err != nil {
+			return err
+		}
+		return writeYAML("deviceplugin.yaml", cm)
+	})
+	step("nodes", func() error {
+		nodes, err := c.cs.Nodes().List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		return writeYAML("nodes.yaml", nodeResources(nodes.Items))
+	})
+	step("events", func() error {
+		res := []string{}
+		for _, ns := range c.allNamespaces() {
+			events, err := c.cs.Events(ns).List(metav1.ListOptions{})
+			if err != nil {
+				return err
+			}
+			res = append(res, formatEvents(events.Items, since)...)
+		}
+		return write("events.txt", []byte(strings.Join(res, "")))
+	})
+	step("logs", func() error {
+		return c.collectLogs(filepath.Join(dir, "logs"), since)
+	})
+	step("nodes links", func() error {
+		nodes, err := c.testedNodes()
+		if err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			out, err := c.ipLink(c.cs, node)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("links of %s: %v", node, err))
+				continue
+			}
+			if err := write("iplink-"+node+".txt", []byte(out)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if len(failures) == 0 {
+		return dir, nil
+	}
+	if err := write("errors.txt", []byte(strings.Join(failures, "\n")+"\n")); err != nil {
+		failures = append(failures, err.Error())
+	}
+	return dir, fmt.Errorf("Failed to collect some artifacts of %s: %s", spec, strings.Join(failures, "; "))
+}
+
+// collectLogs writes the logs of the containers of the operator namespace pods:
+// the operator, the config daemons, the device plugins and the injector.
+func (c *Collector) collectLogs(dir string, since time.Time) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	pods, err := c.cs.Pods(c.OperatorNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	failures := []string{}
+	sinceTime := metav1.NewTime(since)
+	for _, p := range pods.Items {
+		for _, container := range p.Spec.Containers {
+			data, err := c.cs.Pods(c.OperatorNamespace).GetLogs(p.Name, &corev1.PodLogOptions{
+				Container: container.Name,
+				SinceTime: &sinceTime,
+			}).DoRaw()
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s/%s %v", p.Name, container.Name, err))
+				continue
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, p.Name+"_"+container.Name+".log"), data, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("Failed to get the logs of %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// testedNodes returns the nodes the spec ran pods on or pinned policies to.
+func (c *Collector) testedNodes() ([]string, error) {
+	nodes := map[string]bool{}
+	for _, ns := range c.Namespaces {
+		pods, err := c.cs.Pods(ns).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range pods.Items {
+			if p.Spec.NodeName != "" {
+				nodes[p.Spec.NodeName] = true
+			}
+		}
+	}
+	policies := &sriovv1.SriovNetworkNodePolicyList{}
+	if err := c.cs.List(context.Background(), policies, runtimeclient.InNamespace(c.OperatorNamespace)); err != nil {
+		return nil, err
+	}
+	for _, p := range policies.Items {
+		if node, ok := p.Spec.NodeSelector[hostnameLabel]; ok {
+			nodes[node] = true
+		}
+	}
+
+	res := []string{}
+	for node := range nodes {
+		res = append(res, node)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func (c *Collector) allNamespaces() []string {
+	return append([]string{c.OperatorNamespace}, c.Namespaces...)
+}
+
+// NodeResources are the sriov relevant parts of a node.
+type NodeResources struct {
+	Name        string              `json:"name"`
+	Capacity    corev1.ResourceList `json:"capacity"`
+	Allocatable corev1.ResourceList `json:"allocatable"`
+}
+
+func nodeResources(nodes []corev1.Node) []NodeResources {
+	res := []NodeResources{}
+	for _, n := range nodes {
+		res = append(res, NodeResources{Name: n.Name, Capacity: n.Status.Capacity, Allocatable: n.Status.Allocatable})
+	}
+	return res
+}
+
+// formatEvents returns one line per event seen since the given time, oldest first.
+func formatEvents(events []corev1.Event, since time.Time) []string {
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+	res := []string{}
+	for _, e := range events {
+		last := eventTime(e)
+		if last.Before(since) {
+			continue
+		}
+		res = append(res, fmt.Sprintf("%s %s %s %s %s/%s: %s\n",
+			last.UTC().Format(time.RFC3339), e.Type, e.Reason,
+			e.InvolvedObject.Kind, e.InvolvedObject.Namespace, e.InvolvedObject.Name, strings.TrimSpace(e.Message)))
+	}
+	return res
+}
+
+func eventTime(e corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.FirstTimestamp.Time
+}
+
+func debugIPLink(cs *testclient.ClientSet, node string) (string, error) {
+	debugger, err := pod.DebugOnNode(cs, node)
+	if err != nil {
+		return "", err
+	}
+	return debugger.Run("ip", "-d", "link", "show")
+}
@@ -0,0 +1,131 @@
+package artifacts
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	netattdefv1 "github.com/openshift/sriov-network-operator/pkg/apis/k8s/v1"
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+	"github.com/openshift/sriov-tests/pkg/util/deviceplugin"
+	"github.com/openshift/sriov-tests/pkg/util/pod"
+)
+
+const (
+	operatorNamespace = "sriov-operator"
+	testNamespace     = "sriov-test"
+)
+
+var _ = Describe("Collector", func() {
+	var (
+		dir   string
+		start time.Time
+	)
+
+	read := func(path ...string) string {
+		data, err := ioutil.ReadFile(filepath.Join(path...))
+		Expect(err).ToNot(HaveOccurred())
+		return string(data)
+	}
+
+	event := func(name, reason string, at time.Time) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: testNamespace, Name: "testpod"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         reason,
+			Message:        "failed to allocate the vf",
+			LastTimestamp:  metav1.NewTime(at),
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "artifacts")
+		Expect(err).ToNot(HaveOccurred())
+		start = time.Now().Truncate(time.Second)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("dumps the sriov state of the failed spec", func() {
+		testPod := pod.Define(pod.WithName("testpod"), pod.WithNamespace(testNamespace), pod.WithNodeName("worker-1"))
+		cs, stop, err := testclient.NewFake(
+			&sriovv1.SriovNetworkNodeState{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: operatorNamespace},
+				Status: sriovv1.SriovNetworkNodeStateStatus{SyncStatus: "InProgress"}},
+			&sriovv1.SriovNetworkNodePolicy{ObjectMeta: metav1.ObjectMeta{Name: "policy1", Namespace: operatorNamespace},
+				Spec: sriovv1.SriovNetworkNodePolicySpec{ResourceName: "resource1", NodeSelector: map[string]string{hostnameLabel: "worker-0"}}},
+			&sriovv1.SriovNetwork{ObjectMeta: metav1.ObjectMeta{Name: "network1", Namespace: operatorNamespace},
+				Spec: sriovv1.SriovNetworkSpec{ResourceName: "resource1", NetworkNamespace: testNamespace}},
+			&netattdefv1.NetworkAttachmentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "network1", Namespace: testNamespace},
+				Spec: netattdefv1.NetworkAttachmentDefinitionSpec{Config: `{"type":"sriov"}`}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: deviceplugin.ConfigMapName, Namespace: operatorNamespace},
+				Data: map[string]string{deviceplugin.ClusterKey: `{"resourceList":[]}`}},
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"},
+				Status: corev1.NodeStatus{
+					Capacity:    corev1.ResourceList{"openshift.io/resource1": resource.MustParse("5")},
+					Allocatable: corev1.ResourceList{"openshift.io/resource1": resource.MustParse("4")},
+				}},
+			event("old", "Scheduled", start.Add(-time.Hour)),
+			event("new", "FailedCreatePodSandBox", start.Add(time.Second)),
+			testPod,
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer stop()
+
+		c := New(cs, dir, operatorNamespace, testNamespace)
+		c.ipLink = func(_ *testclient.ClientSet, node string) (string, error) {
+			if node == "worker-1" {
+				return "", fmt.Errorf("the debug pod is not running")
+			}
+			return "4: ens1f0: <BROADCAST,MULTICAST,UP,LOWER_UP>\n", nil
+		}
+
+		path, err := c.Collect("[sriov] operator VF flags Should configure the spoofChk", start)
+		Expect(err).To(MatchError(ContainSubstring("links of worker-1: the debug pod is not running")))
+		Expect(path).To(Equal(filepath.Join(dir, "sriov-operator-vf-flags-should-configure-the-spoofchk")))
+
+		Expect(read(path, "nodestates.yaml")).To(ContainSubstring("syncStatus: InProgress"))
+		Expect(read(path, "policies.yaml")).To(ContainSubstring("name: policy1"))
+		Expect(read(path, "networks.yaml")).To(ContainSubstring("name: network1"))
+		Expect(read(path, "networkattachmentdefinitions.yaml")).To(ContainSubstring(`config: '{"type":"sriov"}'`))
+		Expect(read(path, "operatorconfig.yaml")).To(ContainSubstring("items: []"))
+		Expect(read(path, "deviceplugin.yaml")).To(ContainSubstring("resourceList"))
+		Expect(read(path, "nodes.yaml")).To(Equal(`- allocatable:
+    openshift.io/resource1: "4"
+  capacity:
+    openshift.io/resource1: "5"
+  name: worker-0
+`))
+		Expect(read(path, "events.txt")).To(Equal(fmt.Sprintf("%s Warning FailedCreatePodSandBox Pod sriov-test/testpod: failed to allocate the vf\n",
+			start.Add(time.Second).UTC().Format(time.RFC3339))))
+		Expect(read(path, "iplink-worker-0.txt")).To(ContainSubstring("ens1f0"))
+		Expect(filepath.Join(path, "iplink-worker-1.txt")).ToNot(BeAnExistingFile())
+		Expect(read(path, "errors.txt")).To(Equal("links of worker-1: the debug pod is not running\n"))
+	})
+
+	It("records the missing device plugin config and the failing logs", func() {
+		operator := pod.Define(pod.WithName("sriov-network-operator-1"), pod.WithNamespace(operatorNamespace))
+		cs, stop, err := testclient.NewFake(operator)
+		Expect(err).ToNot(HaveOccurred())
+		defer stop()
+
+		c := New(cs, dir, operatorNamespace, testNamespace)
+		path, err := c.Collect("spec", start)
+		Expect(err).To(HaveOccurred())
+		Expect(read(path, "deviceplugin.txt")).To(ContainSubstring("missing"))
+		Expect(read(path, "errors.txt")).To(ContainSubstring("logs: Failed to get the logs of sriov-network-operator-1/"))
+	})
+})
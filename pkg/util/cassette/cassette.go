@@ -0,0 +1,390 @@
+// Package cassette records the requests made to the api server and their
+// responses, and serves them back later without a cluster.
+// Watch streams are recorded up to when they are closed. Of the exec calls only
+// the upgrade handshake is recorded, so they fail when replayed.
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/openshift/sriov-tests/pkg/util/client"
+	"k8s.io/client-go/rest"
+)
+
+// Environment variables configuring the recorder built by FromEnv.
+const (
+	ModeEnv = "SRIOV_CASSETTE_MODE"
+	DirEnv  = "SRIOV_CASSETTE_DIR"
+)
+
+// Mode tells if the interactions are recorded or replayed.
+type Mode string
+
+// The supported modes.
+const (
+	Record Mode = "record"
+	Replay Mode = "replay"
+)
+
+// suiteCassette is the cassette used outside of the specs.
+const suiteCassette = "suite"
+
+// Request is a recorded request.
+type Request struct {
+	Method  string      `json:"method"`
+	URI     string      `json:"uri"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Response is a recorded response. The body of a streamed response, i.e. a
+// watch, holds what was read before the stream was closed.
+type Response struct {
+	StatusCode int         `json:"statusCode,omitempty"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// Interaction is a request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+	replayed bool
+}
+
+// Cassette holds the interactions of a spec.
+type Cassette struct {
+	Name         string         `json:"name"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Recorder records or replays the interactions of the clients whose config it wraps.
+// The interactions go to the cassette of the running spec, set by Start, or to the
+// suite cassette. All the methods can be called on a nil recorder, doing nothing.
+type Recorder struct {
+	Mode Mode
+	Dir  string
+
+	lock    sync.Mutex
+	suite   *Cassette
+	current *Cassette
+}
+
+// New returns a recorder reading and writing the cassettes in dir.
+// In replay mode the suite cassette is loaded right away.
+func New(mode Mode, dir string) (*Recorder, error) {
+	r := &Recorder{Mode: mode, Dir: dir}
+	switch mode {
+	case Record:
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("Failed to create cassette dir %s %v", dir, err)
+		}
+		r.suite = &Cassette{Name: suiteCassette}
+	case Replay:
+		c, err := r.load(suiteCassette)
+		if err != nil {
+			return nil, err
+		}
+		r.suite = c
+	default:
+		return nil, fmt.Errorf("Unknown cassette mode %q", mode)
+	}
+	return r, nil
+}
+
+// FromEnv returns the recorder configured by SRIOV_CASSETTE_MODE and
+// SRIOV_CASSETTE_DIR, or nil when no mode is set.
+func FromEnv() (*Recorder, error) {
+	mode := os.Getenv(ModeEnv)
+	if mode == "" {
+		return nil, nil
+	}
+	dir := os.Getenv(DirEnv)
+	if dir == "" {
+		dir = "cassettes"
+	}
+	return New(Mode(mode), dir)
+}
+
+// ReplayConfig returns a config for the clients replaying the cassettes, as no
+// cluster is needed.
+func ReplayConfig() *rest.Config {
+	return &rest.Config{Host: "http://replay.invalid"}
+}
+
+// Wrap makes the clients built from the config go through the recorder.
+func (r *Recorder) Wrap(config *rest.Config) {
+	if r == nil {
+		return
+	}
+	previous := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if previous != nil {
+			rt = previous(rt)
+		}
+		return r.WrapTransport(rt)
+	}
+}
+
+// WrapTransport returns a round tripper recording the requests sent through
+// next, or replaying them without calling next.
+func (r *Recorder) WrapTransport(next http.RoundTripper) http.RoundTripper {
+	if r == nil {
+		return next
+	}
+	return &transport{recorder: r, next: next}
+}
+
+// Start switches to the cassette of the given spec. In replay mode it is
+// loaded from the cassette dir.
+func (r *Recorder) Start(spec string) error {
+	if r == nil {
+		return nil
+	}
+	c := &Cassette{Name: spec}
+	if r.Mode == Replay {
+		var err error
+		c, err = r.load(spec)
+		if err != nil {
+			return err
+		}
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.current = c
+	return nil
+}
+
+// Stop writes the cassette of the current spec, when recording, and switches
+// back to the suite cassette.
+func (r *Recorder) Stop() error {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	c := r.current
+	r.current = nil
+	r.lock.Unlock()
+	if c == nil || r.Mode != Record {
+		return nil
+	}
+	return r.write(c)
+}
+
+// Close writes the suite cassette, when recording.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	if err := r.Stop(); err != nil {
+		return err
+	}
+	if r.Mode != Record {
+		return nil
+	}
+	return r.write(r.suite)
+}
+
+func (r *Recorder) cassette() *Cassette {
+	if r.current != nil {
+		return r.current
+	}
+	return r.suite
+}
+
+var unsafeChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Path returns the path of the cassette of the spec.
+func (r *Recorder) Path(spec string) string {
+	name := strings.Trim(unsafeChars.ReplaceAllString(strings.ToLower(spec), "-"), "-")
+	if len(name) > 120 {
+		name = name[:120]
+	}
+	return filepath.Join(r.Dir, name+".json")
+}
+
+func (r *Recorder) load(spec string) (*Cassette, error) {
+	path := r.Path(spec)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read cassette %s %v", path, err)
+	}
+	c := &Cassette{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("Failed to parse cassette %s %v", path, err)
+	}
+	return c, nil
+}
+
+func (r *Recorder) write(c *Cassette) error {
+	r.lock.Lock()
+	redacted := redactCassette(c)
+	r.lock.Unlock()
+
+	data, err := json.MarshalIndent(redacted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to encode cassette %s %v", c.Name, err)
+	}
+	path := r.Path(c.Name)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("Failed to write cassette %s %v", path, err)
+	}
+	return nil
+}
+
+type transport struct {
+	recorder *Recorder
+	next     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.recorder.Mode == Replay {
+		return t.recorder.replay(req)
+	}
+
+	interaction := &Interaction{Request: Request{
+		Method:  req.Method,
+		URI:     req.URL.RequestURI(),
+		Headers: req.Header.Clone(),
+	}}
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		interaction.Request.Body = string(body)
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	t.recorder.lock.Lock()
+	c := t.recorder.cassette()
+	c.Interactions = append(c.Interactions, interaction)
+	if err != nil {
+		interaction.Response.Error = err.Error()
+	} else {
+		interaction.Response.StatusCode = resp.StatusCode
+		interaction.Response.Headers = resp.Header.Clone()
+	}
+	t.recorder.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// the body is recorded as it is read, so the watch streams are recorded too
+	resp.Body = &recordingBody{ReadCloser: resp.Body, recorder: t.recorder, interaction: interaction}
+	return resp, nil
+}
+
+type recordingBody struct {
+	io.ReadCloser
+	recorder    *Recorder
+	interaction *Interaction
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.recorder.lock.Lock()
+		b.interaction.Response.Body += string(p[:n])
+		b.recorder.lock.Unlock()
+	}
+	return n, err
+}
+
+// replay returns the response of the first interaction not replayed yet with the
+// same method and uri. The query is ignored when nothing matches, as it may hold
+// random values such as the run id, and the last interaction is served again once
+// all are replayed, as polling loops may run a different number of times.
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	cassettes := []*Cassette{r.suite}
+	if r.current != nil {
+		cassettes = []*Cassette{r.current, r.suite}
+	}
+	uri := req.URL.RequestURI()
+	matchers := []func(i *Interaction) bool{
+		func(i *Interaction) bool { return !i.replayed && i.Request.URI == uri },
+		func(i *Interaction) bool { return !i.replayed && pathOf(i.Request.URI) == req.URL.Path },
+		func(i *Interaction) bool { return i.Request.URI == uri },
+		func(i *Interaction) bool { return pathOf(i.Request.URI) == req.URL.Path },
+	}
+	for _, matches := range matchers {
+		for _, c := range cassettes {
+			var found *Interaction
+			for _, i := range c.Interactions {
+				if i.Request.Method != req.Method || !matches(i) {
+					continue
+				}
+				found = i
+				if !i.replayed {
+					break
+				}
+			}
+			if found != nil {
+				found.replayed = true
+				return found.response(req)
+			}
+		}
+	}
+	return nil, fmt.Errorf("No recorded interaction for %s %s", req.Method, uri)
+}
+
+func (i *Interaction) response(req *http.Request) (*http.Response, error) {
+	if i.Response.Error != "" {
+		return nil, fmt.Errorf("%s", i.Response.Error)
+	}
+	return &http.Response{
+		StatusCode: i.Response.StatusCode,
+		Status:     fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     i.Response.Headers.Clone(),
+		Body:       ioutil.NopCloser(strings.NewReader(i.Response.Body)),
+		Request:    req,
+	}, nil
+}
+
+func pathOf(uri string) string {
+	if idx := strings.Index(uri, "?"); idx >= 0 {
+		return uri[:idx]
+	}
+	return uri
+}
+
+// Clients returns the clients going through the recorder. When replaying they are
+// built without a cluster, and the served api groups are not checked.
+func (r *Recorder) Clients(ctx context.Context, opts client.Options) (*client.ClientSet, error) {
+	if r == nil {
+		return client.NewWithOptions(ctx, opts)
+	}
+	if r.Mode == Replay {
+		config := ReplayConfig()
+		r.Wrap(config)
+		return client.NewForConfig(config, opts.AddToScheme)
+	}
+	previous := opts.WrapTransport
+	opts.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if previous != nil {
+			rt = previous(rt)
+		}
+		return r.WrapTransport(rt)
+	}
+	return client.NewWithOptions(ctx, opts)
+}
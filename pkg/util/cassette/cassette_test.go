@@ -0,0 +1,139 @@
+package cassette
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	"github.com/openshift/sriov-tests/pkg/util/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+)
+
+const spec = "Cassette records a spec"
+
+var _ = Describe("Recorder", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "cassettes")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	record := func() {
+		fake, stop, err := client.NewFake(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}})
+		Expect(err).ToNot(HaveOccurred())
+		defer stop()
+
+		recorder, err := New(Record, dir)
+		Expect(err).ToNot(HaveOccurred())
+		config := rest.CopyConfig(fake.Config)
+		config.BearerToken = "s3cr3t-token"
+		recorder.Wrap(config)
+		clients, err := client.NewForConfig(config, sriovv1.AddToScheme)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(recorder.Start(spec)).To(Succeed())
+		_, err = clients.Secrets("test").Create(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "creds"},
+			StringData: map[string]string{"password": "hunter2"},
+			Data:       map[string][]byte{"key": []byte("private")},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		w, err := clients.Pods("test").Watch(metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = clients.Pods("test").Create(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}})
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(w.ResultChan(), 5*time.Second).Should(Receive(WithTransform(func(e watch.Event) watch.EventType { return e.Type }, Equal(watch.Added))))
+		w.Stop()
+
+		nodes, err := clients.Nodes().List(metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(nodes.Items).To(HaveLen(1))
+		Expect(recorder.Stop()).To(Succeed())
+		Expect(recorder.Close()).To(Succeed())
+	}
+
+	It("writes a cassette per spec without the secrets", func() {
+		record()
+
+		data, err := ioutil.ReadFile(cassettePath(dir, spec))
+		Expect(err).ToNot(HaveOccurred())
+		content := string(data)
+		Expect(content).To(ContainSubstring("/api/v1/namespaces/test/secrets"))
+		Expect(content).To(ContainSubstring("/api/v1/nodes"))
+		Expect(content).To(ContainSubstring(`\"type\":\"ADDED\"`))
+		Expect(content).To(ContainSubstring(Redacted))
+		Expect(content).ToNot(ContainSubstring("s3cr3t-token"))
+		Expect(content).ToNot(ContainSubstring("hunter2"))
+		Expect(content).ToNot(ContainSubstring("cHJpdmF0ZQ=="))
+
+		_, err = os.Stat(cassettePath(dir, suiteCassette))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("replays the cassettes without a cluster", func() {
+		record()
+
+		recorder, err := New(Replay, dir)
+		Expect(err).ToNot(HaveOccurred())
+		clients, err := recorder.Clients(context.Background(), client.Options{AddToScheme: sriovv1.AddToScheme})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(recorder.Start(spec)).To(Succeed())
+
+		secret, err := clients.Secrets("test").Create(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "creds"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(secret.Data).To(HaveKeyWithValue("key", []byte(Redacted)))
+
+		w, err := clients.Pods("test").Watch(metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(w.ResultChan(), 5*time.Second).Should(Receive(WithTransform(func(e watch.Event) string { return e.Object.(*corev1.Pod).Name }, Equal("pod"))))
+		w.Stop()
+
+		// the list is served again as polling loops may run more often
+		for i := 0; i < 2; i++ {
+			nodes, err := clients.Nodes().List(metav1.ListOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(nodes.Items[0].Name).To(Equal("worker-0"))
+		}
+
+		_, err = clients.ConfigMaps("test").Get("missing", metav1.GetOptions{})
+		Expect(err).To(MatchError(ContainSubstring("No recorded interaction")))
+		Expect(recorder.Stop()).To(Succeed())
+	})
+
+	It("loads the cassettes only in replay mode", func() {
+		_, err := New(Replay, dir)
+		Expect(err).To(HaveOccurred())
+		_, err = New("rewind", dir)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("redactBody", func() {
+	It("redacts the tokens of each line of a stream", func() {
+		body := `{"type":"ADDED","object":{"kind":"ServiceAccountToken","token":"abc"}}` + "\n" + `not json` + "\n"
+		Expect(redactBody(body)).To(Equal(`{"object":{"kind":"ServiceAccountToken","token":"REDACTED"},"type":"ADDED"}` + "\n" + `not json` + "\n"))
+	})
+
+	It("keeps the bodies without secrets", func() {
+		Expect(redactBody(`{"kind":"Node","metadata":{"name":"worker-0"}}`)).To(Equal(`{"kind":"Node","metadata":{"name":"worker-0"}}`))
+	})
+})
+
+func cassettePath(dir, spec string) string {
+	return (&Recorder{Dir: dir}).Path(spec)
+}
@@ -0,0 +1,126 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Redacted replaces the secret values in the cassettes.
+const Redacted = "REDACTED"
+
+// redactedHeaders are the headers carrying credentials.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// redactedFields are the object fields carrying credentials, anywhere in a body.
+var redactedFields = map[string]bool{
+	"token":       true,
+	"bearerToken": true,
+	"password":    true,
+}
+
+// redactCassette returns a copy of the cassette without the credentials and the
+// content of the secrets.
+func redactCassette(c *Cassette) *Cassette {
+	redacted := &Cassette{Name: c.Name}
+	for _, i := range c.Interactions {
+		copied := *i
+		copied.Request.Headers = redactHeaders(i.Request.Headers)
+		copied.Request.Body = redactBody(i.Request.Body)
+		copied.Response.Headers = redactHeaders(i.Response.Headers)
+		copied.Response.Body = redactBody(i.Response.Body)
+		redacted.Interactions = append(redacted.Interactions, &copied)
+	}
+	return redacted
+}
+
+func redactHeaders(headers http.Header) http.Header {
+	if headers == nil {
+		return nil
+	}
+	redacted := headers.Clone()
+	for _, h := range redactedHeaders {
+		if _, ok := redacted[h]; ok {
+			redacted[h] = []string{Redacted}
+		}
+	}
+	return redacted
+}
+
+// redactBody redacts a json body, or each line of a watch stream. Bodies that
+// are not json are kept as they are.
+func redactBody(body string) string {
+	if body == "" {
+		return body
+	}
+	if redacted, ok := redactJSON(body); ok {
+		return redacted
+	}
+	lines := strings.SplitAfter(body, "\n")
+	for idx, line := range lines {
+		trimmed := strings.TrimRight(line, "\n")
+		if redacted, ok := redactJSON(trimmed); ok {
+			lines[idx] = redacted + line[len(trimmed):]
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+func redactJSON(data string) (string, bool) {
+	var value interface{}
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return "", false
+	}
+	redactValue(value)
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", false
+	}
+	return strings.TrimRight(buf.String(), "\n"), true
+}
+
+func redactValue(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v["kind"] == "Secret" {
+			redactSecret(v)
+		}
+		for key, field := range v {
+			if _, ok := field.(string); ok && redactedFields[key] {
+				v[key] = Redacted
+				continue
+			}
+			redactValue(field)
+		}
+	case []interface{}:
+		for _, item := range v {
+			redactValue(item)
+		}
+	}
+}
+
+// redactSecret keeps the keys of the secret, as the helpers may look them up.
+func redactSecret(secret map[string]interface{}) {
+	if data, ok := secret["data"].(map[string]interface{}); ok {
+		for key := range data {
+			data[key] = base64.StdEncoding.EncodeToString([]byte(Redacted))
+		}
+	}
+	if data, ok := secret["stringData"].(map[string]interface{}); ok {
+		for key := range data {
+			data[key] = Redacted
+		}
+	}
+	if metadata, ok := secret["metadata"].(map[string]interface{}); ok {
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/pointer"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+	"github.com/openshift/sriov-tests/pkg/util/cluster"
+)
+
+const (
+	// RunIDLabel is the label stamped on the objects created by the tests,
+	// with the id of the run as value.
+	RunIDLabel = "sriov-tests.openshift.io/run-id"
+
+	resourcePrefix = "openshift.io/"
+	defaultTimeout = 10 * time.Minute
+)
+
+// the phases objects are deleted in, so nothing is removed while in use
+const (
+	phasePods = iota
+	phaseNetworks
+	phaseOthers
+	phasePolicies
+)
+
+// Registry keeps track of the objects created by a test run, so that
+// only those are removed when cleaning up.
+type Registry struct {
+	// RunID identifies the objects created by the run.
+	RunID string
+	// OperatorNamespace is where the networks and the policies are created.
+	OperatorNamespace string
+	// Namespaces are swept for the labelled pods.
+	Namespaces []string
+	// Timeout bounds the wait for the pods deletion and for the nodes to settle.
+	Timeout time.Duration
+
+	clients *testclient.ClientSet
+	lock    sync.Mutex
+	objects []runtime.Object
+}
+
+// New returns a registry with a new run id.
+func New(clients *testclient.ClientSet, operatorNamespace string, namespaces ...string) *Registry {
+	return ForRun(clients, rand.String(10), operatorNamespace, namespaces...)
+}
+
+// ForRun returns a registry for an existing run. An empty run id matches the
+// objects created by any run.
+func ForRun(clients *testclient.ClientSet, runID, operatorNamespace string, namespaces ...string) *Registry {
+	return &Registry{
+		RunID:             runID,
+		OperatorNamespace: operatorNamespace,
+		Namespaces:        namespaces,
+		Timeout:           defaultTimeout,
+		clients:           clients,
+	}
+}
+
+// Track stamps the run id on the object and records it. It must be called
+// before the object is created.
+func (r *Registry) Track(obj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return fmt.Errorf("Failed to track object %v", err)
+	}
+	labels := accessor.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[RunIDLabel] = r.RunID
+	accessor.SetLabels(labels)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.objects = append(r.objects, obj)
+	return nil
+}
+
+// Create tracks the object and creates it.
+func (r *Registry) Create(obj runtime.Object) error {
+	err := r.Track(obj)
+	if err != nil {
+		return err
+	}
+	return r.clients.Create(context.Background(), obj)
+}
+
+// Clean deletes the objects of the run, pods first, then networks and policies.
+// It then waits for the nodes to release the resources of the deleted policies.
+// On failure the recorded objects not deleted yet are kept, so a later Clean
+// retries them.
+func (r *Registry) Clean() error {
+	r.lock.Lock()
+	objects := r.objects
+	r.objects = nil
+	r.lock.Unlock()
+
+	deleted := map[runtime.Object]bool{}
+	err := r.clean(objects, deleted)
+	if err != nil {
+		remaining := []runtime.Object{}
+		for _, obj := range objects {
+			if !deleted[obj] {
+				remaining = append(remaining, obj)
+			}
+		}
+		r.lock.Lock()
+		r.objects = append(remaining, r.objects...)
+		r.lock.Unlock()
+	}
+	return err
+}
+
+func (r *Registry) clean(objects []runtime.Object, deleted map[runtime.Object]bool) error {
+	resources := map[string]bool{}
+	for _, obj := range objects {
+		if p, ok := obj.(*sriovv1.SriovNetworkNodePolicy); ok {
+			resources[p.Spec.ResourceName] = true
+		}
+	}
+
+	err := r.deleteRecorded(objects, phasePods, deleted)
+	if err != nil {
+		return err
+	}
+	err = r.deletePods()
+	if err != nil {
+		return err
+	}
+
+	err = r.deleteRecorded(objects, phaseNetworks, deleted)
+	if err != nil {
+		return err
+	}
+	err = r.deleteNetworks()
+	if err != nil {
+		return err
+	}
+
+	err = r.deleteRecorded(objects, phaseOthers, deleted)
+	if err != nil {
+		return err
+	}
+
+	err = r.deleteRecorded(objects, phasePolicies, deleted)
+	if err != nil {
+		return err
+	}
+	policyResources, err := r.deletePolicies()
+	if err != nil {
+		return err
+	}
+	for _, res := range policyResources {
+		resources[res] = true
+	}
+
+	if len(resources) == 0 {
+		return nil
+	}
+	return r.waitForNodes(resources)
+}
+
+func (r *Registry) deleteRecorded(objects []runtime.Object, phase int, deleted map[runtime.Object]bool) error {
+	for _, obj := range objects {
+		if phaseOf(obj) != phase {
+			continue
+		}
+		err := r.delete(obj)
+		if err != nil {
+			return err
+		}
+		deleted[obj] = true
+	}
+	return nil
+}
+
+func (r *Registry) selector() string {
+	if r.RunID == "" {
+		return RunIDLabel
+	}
+	return RunIDLabel + "=" + r.RunID
+}
+
+func (r *Registry) listOptions() ([]runtimeclient.ListOption, error) {
+	selector, err := labels.Parse(r.selector())
+	if err != nil {
+		return nil, err
+	}
+	return []runtimeclient.ListOption{
+		runtimeclient.InNamespace(r.OperatorNamespace),
+		runtimeclient.MatchingLabelsSelector{Selector: selector},
+	}, nil
+}
+
+func (r *Registry) delete(obj runtime.Object) error {
+	err := r.clients.Delete(context.Background(), obj, runtimeclient.GracePeriodSeconds(0))
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("Failed to delete %v", err)
+	}
+	return nil
+}
+
+// deletePods deletes the labelled pods and waits for them to be gone, so
+// their vfs are released before the policies are removed.
+func (r *Registry) deletePods() error {
+	listOptions := metav1.ListOptions{LabelSelector: r.selector()}
+	for _, ns := range r.Namespaces {
+		err := r.clients.Pods(ns).DeleteCollection(&metav1.DeleteOptions{
+			GracePeriodSeconds: pointer.Int64Ptr(0),
+		}, listOptions)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("Failed to delete pods %v", err)
+		}
+	}
+
+	err := wait.PollImmediate(time.Second, r.Timeout, func() (bool, error) {
+		for _, ns := range r.Namespaces {
+			pods, err := r.clients.Pods(ns).List(listOptions)
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return false, err
+			}
+			if err == nil && len(pods.Items) > 0 {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to wait for pods deletion %v", err)
+	}
+	return nil
+}
+
+func (r *Registry) deleteNetworks() error {
+	opts, err := r.listOptions()
+	if err != nil {
+		return err
+	}
+	networks := sriovv1.SriovNetworkList{}
+	err = r.clients.List(context.Background(), &networks, opts...)
+	if err != nil {
+		return fmt.Errorf("Failed to list networks %v", err)
+	}
+	for i := range networks.Items {
+		err := r.delete(&networks.Items[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deletePolicies deletes the labelled policies, returning the resources they exposed.
+func (r *Registry) deletePolicies() ([]string, error) {
+	opts, err := r.listOptions()
+	if err != nil {
+		return nil, err
+	}
+	policies := sriovv1.SriovNetworkNodePolicyList{}
+	err = r.clients.List(context.Background(), &policies, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list policies %v", err)
+	}
+	res := []string{}
+	for i := range policies.Items {
+		p := &policies.Items[i]
+		if p.Name == "default" {
+			continue
+		}
+		err := r.delete(p)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, p.Spec.ResourceName)
+	}
+	return res, nil
+}
+
+// waitForNodes waits for the node states to be in sync and for the
+// resources of the deleted policies not to be advertised anymore.
+func (r *Registry) waitForNodes(resources map[string]bool) error {
+	err := cluster.WaitForSriovStable(r.OperatorNamespace, r.clients, r.Timeout, ioutil.Discard)
+	if err != nil {
+		return err
+	}
+
+	err = wait.PollImmediate(5*time.Second, r.Timeout, func() (bool, error) {
+		nodes, err := r.clients.Nodes().List(metav1.ListOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, n := range nodes.Items {
+			if advertises(n, resources) {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to wait for the nodes to settle %v", err)
+	}
+	return nil
+}
+
+func advertises(node corev1.Node, resources map[string]bool) bool {
+	for resource := range resources {
+		quantity, ok := node.Status.Allocatable[corev1.ResourceName(resourcePrefix+resource)]
+		if ok && !quantity.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+func phaseOf(obj runtime.Object) int {
+	switch obj.(type) {
+	case *corev1.Pod:
+		return phasePods
+	case *sriovv1.SriovNetwork:
+		return phaseNetworks
+	case *sriovv1.SriovNetworkNodePolicy:
+		return phasePolicies
+	}
+	return phaseOthers
+}
@@ -0,0 +1,140 @@
+package cleanup
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+)
+
+func runMeta(name, namespace, runID string) metav1.ObjectMeta {
+	meta := metav1.ObjectMeta{Name: name, Namespace: namespace}
+	if runID != "" {
+		meta.Labels = map[string]string{RunIDLabel: runID}
+	}
+	return meta
+}
+
+var _ = Describe("Registry", func() {
+	It("stamps the run id on the tracked objects", func() {
+		r := New(nil, "sriov-operator", "sriov-testing")
+		Expect(r.RunID).ToNot(BeEmpty())
+
+		policy := &sriovv1.SriovNetworkNodePolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "policy",
+				Labels: map[string]string{"app": "test"},
+			},
+		}
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}
+		Expect(r.Track(policy)).To(Succeed())
+		Expect(r.Track(pod)).To(Succeed())
+
+		Expect(policy.Labels).To(Equal(map[string]string{"app": "test", RunIDLabel: r.RunID}))
+		Expect(pod.Labels).To(Equal(map[string]string{RunIDLabel: r.RunID}))
+		Expect(r.objects).To(HaveLen(2))
+	})
+
+	It("selects the objects of the run, or of any run", func() {
+		Expect(ForRun(nil, "abc", "sriov-operator").selector()).To(Equal(RunIDLabel + "=abc"))
+		Expect(ForRun(nil, "", "sriov-operator").selector()).To(Equal(RunIDLabel))
+	})
+
+	It("deletes the pods first and the policies last", func() {
+		Expect(phaseOf(&corev1.Pod{})).To(BeNumerically("<", phaseOf(&sriovv1.SriovNetwork{})))
+		Expect(phaseOf(&sriovv1.SriovNetwork{})).To(BeNumerically("<", phaseOf(&corev1.ConfigMap{})))
+		Expect(phaseOf(&corev1.ConfigMap{})).To(BeNumerically("<", phaseOf(&sriovv1.SriovNetworkNodePolicy{})))
+	})
+
+	It("detects the nodes still advertising the resources", func() {
+		node := corev1.Node{
+			Status: corev1.NodeStatus{
+				Allocatable: corev1.ResourceList{
+					"openshift.io/testresource":  resource.MustParse("5"),
+					"openshift.io/testresource1": resource.MustParse("0"),
+				},
+			},
+		}
+		Expect(advertises(node, map[string]bool{"testresource": true})).To(BeTrue())
+		Expect(advertises(node, map[string]bool{"testresource1": true})).To(BeFalse())
+		Expect(advertises(node, map[string]bool{"other": true})).To(BeFalse())
+	})
+})
+
+var _ = Describe("Clean", func() {
+	It("removes the objects of the run only", func() {
+		clients, stop, err := testclient.NewFake(
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0"}},
+			&sriovv1.SriovNetworkNodeState{
+				ObjectMeta: runMeta("worker-0", "sriov-operator", ""),
+				Status:     sriovv1.SriovNetworkNodeStateStatus{SyncStatus: "Succeeded"},
+			},
+			&sriovv1.SriovNetworkNodePolicy{ObjectMeta: runMeta("default", "sriov-operator", "abc")},
+			&sriovv1.SriovNetworkNodePolicy{ObjectMeta: runMeta("policy", "sriov-operator", "abc"), Spec: sriovv1.SriovNetworkNodePolicySpec{ResourceName: "res"}},
+			&sriovv1.SriovNetworkNodePolicy{ObjectMeta: runMeta("other-run", "sriov-operator", "def")},
+			&sriovv1.SriovNetworkNodePolicy{ObjectMeta: runMeta("user", "sriov-operator", "")},
+			&sriovv1.SriovNetwork{ObjectMeta: runMeta("network", "sriov-operator", "abc")},
+			&corev1.Pod{ObjectMeta: runMeta("pod", "sriov-testing", "abc")},
+			&corev1.Pod{ObjectMeta: runMeta("user-pod", "sriov-testing", "")},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer stop()
+
+		r := ForRun(clients, "abc", "sriov-operator", "sriov-testing")
+		r.Timeout = 10 * time.Second
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "sriov-testing"}}
+		Expect(r.Create(cm)).To(Succeed())
+
+		Expect(r.Clean()).To(Succeed())
+
+		policies := &sriovv1.SriovNetworkNodePolicyList{}
+		Expect(clients.List(context.Background(), policies, runtimeclient.InNamespace("sriov-operator"))).To(Succeed())
+		names := []string{}
+		for _, p := range policies.Items {
+			names = append(names, p.Name)
+		}
+		Expect(names).To(ConsistOf("default", "other-run", "user"))
+
+		networks := &sriovv1.SriovNetworkList{}
+		Expect(clients.List(context.Background(), networks, runtimeclient.InNamespace("sriov-operator"))).To(Succeed())
+		Expect(networks.Items).To(BeEmpty())
+
+		pods, err := clients.Pods("sriov-testing").List(metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pods.Items).To(HaveLen(1))
+		Expect(pods.Items[0].Name).To(Equal("user-pod"))
+
+		_, err = clients.ConfigMaps("sriov-testing").Get("cm", metav1.GetOptions{})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("keeps the objects not deleted when failing", func() {
+		clients, stop, err := testclient.NewFake()
+		Expect(err).ToNot(HaveOccurred())
+		defer stop()
+
+		r := ForRun(clients, "abc", "sriov-operator", "sriov-testing")
+		r.Timeout = 10 * time.Second
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "sriov-testing"}}
+		// the kind is not registered in the scheme of the clients, so it can't be deleted
+		crd := &apiextensionsv1beta1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: "crd"}}
+		policy := &sriovv1.SriovNetworkNodePolicy{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "sriov-operator"}}
+		for _, obj := range []runtime.Object{pod, crd, policy} {
+			Expect(r.Track(obj)).To(Succeed())
+		}
+
+		Expect(r.Clean()).ToNot(Succeed())
+		Expect(r.objects).To(ConsistOf(crd, policy))
+	})
+})
@@ -1,12 +1,20 @@
 package client
 
 import (
-	netattdefv1 "github.com/openshift/sriov-network-operator/pkg/apis/k8s/v1"
+	"context"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/golang/glog"
+	configv1 "github.com/openshift/api/config/v1"
 	clientconfigv1 "github.com/openshift/client-go/config/clientset/versioned/typed/config/v1"
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
 	clientmachineconfigv1 "github.com/openshift/machine-config-operator/pkg/generated/clientset/versioned/typed/machineconfiguration.openshift.io/v1"
+	netattdefv1 "github.com/openshift/sriov-network-operator/pkg/apis/k8s/v1"
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
 	clientsriovv1 "github.com/openshift/sriov-network-operator/pkg/client/clientset/versioned/typed/sriovnetwork/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	discovery "k8s.io/client-go/discovery"
@@ -15,10 +23,21 @@ import (
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// requiredGroups are the api groups the suites can't run without.
+var requiredGroups = []string{
+	sriovv1.SchemeGroupVersion.Group,
+	netattdefv1.SchemeGroupVersion.Group,
+}
+
+// openShiftGroups are the api groups served only by OpenShift clusters.
+var openShiftGroups = []string{
+	configv1.GroupName,
+	mcfgv1.GroupName,
+}
+
 // ClientSet provides the struct to talk with relevant API
 type ClientSet struct {
 	corev1client.CoreV1Interface
@@ -32,41 +51,190 @@ type ClientSet struct {
 	runtimeclient.Client
 }
 
+// Options configures the ClientSet returned by NewWithOptions.
+type Options struct {
+	// Kubeconfig is the path of the kubeconfig file. When empty, KUBECONFIG is
+	// used and then the in cluster config.
+	Kubeconfig string
+	// Context is the kubeconfig context to use instead of the current one.
+	Context string
+	QPS     float32
+	Burst   int
+	// UserAgent defaults to the client-go one.
+	UserAgent string
+	// ImpersonateUser and ImpersonateGroups make the requests on behalf of another user.
+	ImpersonateUser   string
+	ImpersonateGroups []string
+	// AddToScheme registers extra types in the scheme of the runtime client.
+	AddToScheme func(*runtime.Scheme) error
+	// AllowMissingOpenShift lets the OpenShift only apis be absent, i.e. on vanilla kubernetes.
+	AllowMissingOpenShift bool
+	// WrapTransport wraps the transport of the clients, i.e. to record the requests.
+	WrapTransport func(http.RoundTripper) http.RoundTripper
+}
+
 // New returns a *ClientBuilder with the given kubeconfig.
+// It panics on errors, NewWithOptions should be preferred.
 func New(kubeconfig string, addToScheme func(*runtime.Scheme)) *ClientSet {
+	config, err := restConfig(Options{Kubeconfig: kubeconfig})
+	if err != nil {
+		panic(err)
+	}
+	clientSet, err := NewForConfig(config, func(s *runtime.Scheme) error {
+		addToScheme(s)
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	return clientSet
+}
+
+// NewWithOptions returns a ClientSet configured with the given options, after checking
+// through discovery that the apis needed by the suites are served.
+func NewWithOptions(ctx context.Context, opts Options) (*ClientSet, error) {
+	config, err := restConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	config.WrapTransport = opts.WrapTransport
+
+	clientSet, err := NewForConfig(config, opts.AddToScheme)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// only the check is bounded by the context, the clients get no timeout as
+	// they serve watches, exec and log streams too
+	checkConfig := rest.CopyConfig(config)
+	if deadline, ok := ctx.Deadline(); ok {
+		checkConfig.Timeout = time.Until(deadline)
+	}
+	disc, err := discovery.NewDiscoveryClientForConfig(checkConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create the discovery client %v", err)
+	}
+	err = checkGroups(disc, opts.AllowMissingOpenShift)
+	if err != nil {
+		return nil, err
+	}
+	return clientSet, nil
+}
+
+// NewForConfig returns a ClientSet talking to the api server of the given config.
+// addToScheme is optional.
+func NewForConfig(config *rest.Config, addToScheme func(*runtime.Scheme) error) (*ClientSet, error) {
+	var err error
+	clientSet := &ClientSet{Config: config}
+	if clientSet.CoreV1Interface, err = corev1client.NewForConfig(config); err != nil {
+		return nil, fmt.Errorf("Failed to create the core client %v", err)
+	}
+	if clientSet.ConfigV1Interface, err = clientconfigv1.NewForConfig(config); err != nil {
+		return nil, fmt.Errorf("Failed to create the config client %v", err)
+	}
+	if clientSet.MachineconfigurationV1Interface, err = clientmachineconfigv1.NewForConfig(config); err != nil {
+		return nil, fmt.Errorf("Failed to create the machine config client %v", err)
+	}
+	if clientSet.AppsV1Interface, err = appsv1client.NewForConfig(config); err != nil {
+		return nil, fmt.Errorf("Failed to create the apps client %v", err)
+	}
+	if clientSet.DiscoveryInterface, err = discovery.NewDiscoveryClientForConfig(config); err != nil {
+		return nil, fmt.Errorf("Failed to create the discovery client %v", err)
+	}
+	if clientSet.SriovnetworkV1Interface, err = clientsriovv1.NewForConfig(config); err != nil {
+		return nil, fmt.Errorf("Failed to create the sriov client %v", err)
+	}
+
+	crScheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(crScheme); err != nil {
+		return nil, fmt.Errorf("Failed to build the scheme %v", err)
+	}
+	if err := netattdefv1.SchemeBuilder.AddToScheme(crScheme); err != nil {
+		return nil, fmt.Errorf("Failed to build the scheme %v", err)
+	}
+	if addToScheme != nil {
+		if err := addToScheme(crScheme); err != nil {
+			return nil, fmt.Errorf("Failed to build the scheme %v", err)
+		}
+	}
+	clientSet.Client, err = runtimeclient.New(config, runtimeclient.Options{
+		Scheme: crScheme,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create the runtime client %v", err)
+	}
+	return clientSet, nil
+}
+
+func restConfig(opts Options) (*rest.Config, error) {
 	var config *rest.Config
 	var err error
 
+	kubeconfig := opts.Kubeconfig
 	if kubeconfig == "" {
 		kubeconfig = os.Getenv("KUBECONFIG")
 	}
 
 	if kubeconfig != "" {
 		glog.V(4).Infof("Loading kube client config from path %q", kubeconfig)
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+			&clientcmd.ConfigOverrides{CurrentContext: opts.Context},
+		).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load kubeconfig %s %v", kubeconfig, err)
+		}
 	} else {
 		glog.V(4).Infof("Using in-cluster kube client config")
 		config, err = rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load the in cluster config, is KUBECONFIG set? %v", err)
+		}
 	}
-	if err != nil {
-		panic(err)
+
+	if opts.QPS != 0 {
+		config.QPS = opts.QPS
+	}
+	if opts.Burst != 0 {
+		config.Burst = opts.Burst
+	}
+	if opts.UserAgent != "" {
+		config.UserAgent = opts.UserAgent
 	}
+	if opts.ImpersonateUser != "" {
+		config.Impersonate = rest.ImpersonationConfig{
+			UserName: opts.ImpersonateUser,
+			Groups:   opts.ImpersonateGroups,
+		}
+	}
+	return config, nil
+}
 
-	clientSet := &ClientSet{}
-	clientSet.CoreV1Interface = corev1client.NewForConfigOrDie(config)
-	clientSet.ConfigV1Interface = clientconfigv1.NewForConfigOrDie(config)
-	clientSet.MachineconfigurationV1Interface = clientmachineconfigv1.NewForConfigOrDie(config)
-	clientSet.AppsV1Interface = appsv1client.NewForConfigOrDie(config)
-	clientSet.DiscoveryInterface = discovery.NewDiscoveryClientForConfigOrDie(config)
-	clientSet.SriovnetworkV1Interface = clientsriovv1.NewForConfigOrDie(config)
-	clientSet.Config = config
+// checkGroups returns an error listing the required groups the server does not serve.
+func checkGroups(disc discovery.DiscoveryInterface, allowMissingOpenShift bool) error {
+	groups, err := disc.ServerGroups()
+	if err != nil {
+		return fmt.Errorf("Failed to discover the api groups %v", err)
+	}
+	served := map[string]bool{}
+	for _, g := range groups.Groups {
+		served[g.Name] = true
+	}
 
-	crScheme := runtime.NewScheme()
-	clientgoscheme.AddToScheme(crScheme)
-	netattdefv1.SchemeBuilder.AddToScheme(crScheme)
-	addToScheme(crScheme)
-	clientSet.Client, err = runtimeclient.New(config, client.Options{
-		Scheme: crScheme,
-	})
-	return clientSet
+	required := requiredGroups
+	if !allowMissingOpenShift {
+		required = append(required, openShiftGroups...)
+	}
+	missing := []string{}
+	for _, g := range required {
+		if !served[g] {
+			missing = append(missing, g)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("The cluster does not serve the api groups %s, is the sriov network operator installed?", strings.Join(missing, ", "))
+	}
+	return nil
 }
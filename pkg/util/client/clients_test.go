@@ -0,0 +1,156 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: cluster
+  cluster:
+    server: %s
+users:
+- name: admin
+  user:
+    token: admin-token
+- name: viewer
+  user:
+    token: viewer-token
+contexts:
+- name: admin
+  context:
+    cluster: cluster
+    user: admin
+- name: viewer
+  context:
+    cluster: cluster
+    user: viewer
+current-context: admin
+`
+
+// discoveryServer serves the discovery endpoints with the given groups.
+func discoveryServer(groups ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api":
+			json.NewEncoder(w).Encode(metav1.APIVersions{Versions: []string{"v1"}})
+		case "/apis":
+			list := metav1.APIGroupList{}
+			for _, g := range groups {
+				version := metav1.GroupVersionForDiscovery{GroupVersion: g + "/v1", Version: "v1"}
+				list.Groups = append(list.Groups, metav1.APIGroup{
+					Name:             g,
+					Versions:         []metav1.GroupVersionForDiscovery{version},
+					PreferredVersion: version,
+				})
+			}
+			json.NewEncoder(w).Encode(list)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+var _ = Describe("NewWithOptions", func() {
+	var (
+		dir        string
+		kubeconfig string
+		server     *httptest.Server
+	)
+
+	writeKubeconfig := func(url string) {
+		kubeconfig = filepath.Join(dir, "kubeconfig")
+		err := ioutil.WriteFile(kubeconfig, []byte(fmt.Sprintf(testKubeconfig, url)), 0600)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	start := func(groups ...string) {
+		server = discoveryServer(groups...)
+		writeKubeconfig(server.URL)
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "clients")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		if server != nil {
+			server.Close()
+		}
+		os.RemoveAll(dir)
+	})
+
+	It("applies the options to the rest config", func() {
+		// the credentials are loaded only for tls servers
+		writeKubeconfig("https://api.cluster.example.com:6443")
+		config, err := restConfig(Options{
+			Kubeconfig:        kubeconfig,
+			Context:           "viewer",
+			QPS:               50,
+			Burst:             100,
+			UserAgent:         "sriov-tests",
+			ImpersonateUser:   "system:admin",
+			ImpersonateGroups: []string{"system:masters"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config.BearerToken).To(Equal("viewer-token"))
+		Expect(config.QPS).To(BeEquivalentTo(50))
+		Expect(config.Burst).To(Equal(100))
+		Expect(config.UserAgent).To(Equal("sriov-tests"))
+		Expect(config.Impersonate.UserName).To(Equal("system:admin"))
+		Expect(config.Impersonate.Groups).To(Equal([]string{"system:masters"}))
+	})
+
+	It("fails on a missing kubeconfig", func() {
+		_, err := NewWithOptions(context.Background(), Options{Kubeconfig: filepath.Join(dir, "missing")})
+		Expect(err).To(MatchError(ContainSubstring("Failed to load kubeconfig")))
+	})
+
+	It("fails on an unknown context", func() {
+		start()
+		_, err := NewWithOptions(context.Background(), Options{Kubeconfig: kubeconfig, Context: "other"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("checks the required groups are served", func() {
+		start("sriovnetwork.openshift.io")
+		_, err := NewWithOptions(context.Background(), Options{Kubeconfig: kubeconfig, AllowMissingOpenShift: true})
+		Expect(err).To(MatchError(ContainSubstring("does not serve the api groups k8s.cni.cncf.io")))
+	})
+
+	It("lets the OpenShift groups be absent when allowed", func() {
+		start("sriovnetwork.openshift.io", "k8s.cni.cncf.io")
+		_, err := NewWithOptions(context.Background(), Options{Kubeconfig: kubeconfig})
+		Expect(err).To(MatchError(ContainSubstring("config.openshift.io, machineconfiguration.openshift.io")))
+
+		clients, err := NewWithOptions(context.Background(), Options{Kubeconfig: kubeconfig, AllowMissingOpenShift: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clients.Config.Host).To(Equal(server.URL))
+	})
+
+	It("bounds only the discovery check with the context", func() {
+		start("sriovnetwork.openshift.io", "k8s.cni.cncf.io")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		clients, err := NewWithOptions(ctx, Options{Kubeconfig: kubeconfig, AllowMissingOpenShift: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clients.Config.Timeout).To(BeZero())
+	})
+})
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+
+	netattdefv1 "github.com/openshift/sriov-network-operator/pkg/apis/k8s/v1"
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// NewFake returns a ClientSet backed by an in memory api server, pre populated
+// with the given objects. The typed clients, the runtime client and discovery
+// all talk to the same server, so an object created through one of them is
+// seen by the others, watches included. The server serves the core, apps,
+// sriov and network attachment definition resources used by the suites. The
+// returned func stops the server, closing the open watches.
+func NewFake(objects ...runtime.Object) (*ClientSet, func(), error) {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, netattdefv1.SchemeBuilder.AddToScheme, sriovv1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			return nil, nil, fmt.Errorf("Failed to build the scheme %v", err)
+		}
+	}
+
+	server := newFakeServer()
+	for _, obj := range objects {
+		gvks, _, err := scheme.ObjectKinds(obj)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to find the kind of %T %v", obj, err)
+		}
+		res := fakeResourceFor(gvks[0])
+		if res == nil {
+			return nil, nil, fmt.Errorf("Kind %s is not served by the fake server", gvks[0])
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to encode %T %v", obj, err)
+		}
+		content := fakeObject{}
+		if err := json.Unmarshal(data, &content); err != nil {
+			return nil, nil, fmt.Errorf("Failed to decode %T %v", obj, err)
+		}
+		if _, err := server.add(res, content); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	httpServer := httptest.NewServer(server)
+	stop := func() {
+		// the watches last until their connection is closed
+		httpServer.CloseClientConnections()
+		httpServer.Close()
+	}
+	clients, err := NewForConfig(&rest.Config{Host: httpServer.URL}, sriovv1.AddToScheme)
+	if err != nil {
+		stop()
+		return nil, nil, err
+	}
+	return clients, stop, nil
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	netattdefv1 "github.com/openshift/sriov-network-operator/pkg/apis/k8s/v1"
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("NewFake", func() {
+	var (
+		clients *ClientSet
+		stop    func()
+	)
+
+	BeforeEach(func() {
+		var err error
+		clients, stop, err = NewFake(
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Labels: map[string]string{"sriov": "true"}}},
+			&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}},
+			&sriovv1.SriovNetworkNodeState{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "sriov"},
+				Status:     sriovv1.SriovNetworkNodeStateStatus{SyncStatus: "Succeeded"},
+			},
+		)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	It("serves the initial objects to the typed and the runtime clients", func() {
+		nodes, err := clients.Nodes().List(metav1.ListOptions{LabelSelector: "sriov=true"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(nodes.Items).To(HaveLen(1))
+		Expect(nodes.Items[0].Name).To(Equal("worker-0"))
+
+		state := &sriovv1.SriovNetworkNodeState{}
+		err = clients.Get(context.Background(), types.NamespacedName{Namespace: "sriov", Name: "worker-0"}, state)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state.Status.SyncStatus).To(Equal("Succeeded"))
+
+		_, err = clients.Nodes().Get("worker-2", metav1.GetOptions{})
+		Expect(k8serrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("shares the objects between the clients", func() {
+		nad := &netattdefv1.NetworkAttachmentDefinition{ObjectMeta: metav1.ObjectMeta{Name: "net", Namespace: "test"}}
+		Expect(clients.Create(context.Background(), nad)).To(Succeed())
+		Expect(k8serrors.IsAlreadyExists(clients.Create(context.Background(), nad.DeepCopy()))).To(BeTrue())
+
+		list := &netattdefv1.NetworkAttachmentDefinitionList{}
+		Expect(clients.List(context.Background(), list, runtimeclient.InNamespace("test"))).To(Succeed())
+		Expect(list.Items).To(HaveLen(1))
+
+		pod, err := clients.Pods("test").Create(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{GenerateName: "pod-"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pod.Name).To(HavePrefix("pod-"))
+		Expect(pod.Namespace).To(Equal("test"))
+	})
+
+	It("keeps the status on spec updates and bumps the generation", func() {
+		state, err := clients.SriovNetworkNodeStates("sriov").Get("worker-0", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(state.Generation).To(BeEquivalentTo(1))
+
+		state.Spec.Interfaces = sriovv1.Interfaces{{Name: "ens1f0", NumVfs: 5}}
+		state.Status.SyncStatus = "InProgress"
+		updated, err := clients.SriovNetworkNodeStates("sriov").Update(state)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updated.Generation).To(BeEquivalentTo(2))
+		Expect(updated.Status.SyncStatus).To(Equal("Succeeded"))
+
+		_, err = clients.SriovNetworkNodeStates("sriov").Update(state)
+		Expect(k8serrors.IsConflict(err)).To(BeTrue())
+
+		updated.Status.SyncStatus = "InProgress"
+		updated, err = clients.SriovNetworkNodeStates("sriov").UpdateStatus(updated)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updated.Generation).To(BeEquivalentTo(2))
+		Expect(updated.Status.SyncStatus).To(Equal("InProgress"))
+	})
+
+	It("patches the objects", func() {
+		_, err := clients.Nodes().Patch("worker-1", types.MergePatchType, []byte(`{"metadata":{"labels":{"sriov":"true"}}}`))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = clients.Nodes().Patch("worker-1", types.JSONPatchType, []byte(`[{"op":"add","path":"/spec/unschedulable","value":true}]`))
+		Expect(err).ToNot(HaveOccurred())
+
+		node, err := clients.Nodes().Get("worker-1", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(node.Labels).To(HaveKeyWithValue("sriov", "true"))
+		Expect(node.Spec.Unschedulable).To(BeTrue())
+	})
+
+	It("does not lose concurrent patches", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				patch := fmt.Sprintf(`{"metadata":{"labels":{"label-%d":"true"}}}`, i)
+				_, err := clients.Nodes().Patch("worker-1", types.MergePatchType, []byte(patch))
+				Expect(err).ToNot(HaveOccurred())
+			}(i)
+		}
+		wg.Wait()
+
+		node, err := clients.Nodes().Get("worker-1", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(node.Labels).To(HaveLen(20))
+	})
+
+	It("deletes by label and the content of the deleted namespaces", func() {
+		for _, name := range []string{"a", "b"} {
+			_, err := clients.Pods("test").Create(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"app": name}}})
+			Expect(err).ToNot(HaveOccurred())
+		}
+		_, err := clients.ConfigMaps("test").Create(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm"}})
+		Expect(err).ToNot(HaveOccurred())
+
+		err = clients.Pods("test").DeleteCollection(&metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: "app=a"})
+		Expect(err).ToNot(HaveOccurred())
+		pods, err := clients.Pods("test").List(metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pods.Items).To(HaveLen(1))
+
+		_, err = clients.Namespaces().Create(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clients.Namespaces().Delete("test", &metav1.DeleteOptions{})).To(Succeed())
+		pods, err = clients.Pods("test").List(metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pods.Items).To(BeEmpty())
+	})
+
+	It("streams the changes to the watchers", func() {
+		list, err := clients.SriovNetworkNodeStates("sriov").List(metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		w, err := clients.SriovNetworkNodeStates("sriov").Watch(metav1.ListOptions{
+			ResourceVersion: list.ResourceVersion,
+			FieldSelector:   "metadata.name=worker-0",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer w.Stop()
+
+		state := list.Items[0]
+		state.Status.SyncStatus = "InProgress"
+		_, err = clients.SriovNetworkNodeStates("sriov").UpdateStatus(&state)
+		Expect(err).ToNot(HaveOccurred())
+
+		var ev watch.Event
+		Eventually(w.ResultChan(), 5*time.Second).Should(Receive(&ev))
+		Expect(ev.Type).To(Equal(watch.Modified))
+		Expect(ev.Object.(*sriovv1.SriovNetworkNodeState).Status.SyncStatus).To(Equal("InProgress"))
+	})
+
+	It("closes the open watches when stopped", func() {
+		w, err := clients.Nodes().Watch(metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		defer w.Stop()
+
+		stopped := make(chan struct{})
+		go func() {
+			stop()
+			close(stopped)
+		}()
+		Eventually(stopped, 5*time.Second).Should(BeClosed())
+		Eventually(w.ResultChan(), 5*time.Second).Should(BeClosed())
+		stop = func() {}
+	})
+})
@@ -0,0 +1,710 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// fakeResource is a resource served by the fake api server.
+type fakeResource struct {
+	gvr        schema.GroupVersionResource
+	kind       string
+	namespaced bool
+	// status tells if the resource has a status subresource, whose content
+	// is not changed by the updates of the main resource.
+	status bool
+}
+
+func (r *fakeResource) apiVersion() string {
+	return r.gvr.GroupVersion().String()
+}
+
+var fakeResources = []*fakeResource{
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, kind: "Namespace", status: true},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "nodes"}, kind: "Node", status: true},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "pods"}, kind: "Pod", namespaced: true, status: true},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, kind: "ConfigMap", namespaced: true},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, kind: "Secret", namespaced: true},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "events"}, kind: "Event", namespaced: true},
+	{gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}, kind: "DaemonSet", namespaced: true, status: true},
+	{gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, kind: "Deployment", namespaced: true, status: true},
+	{gvr: schema.GroupVersionResource{Group: "sriovnetwork.openshift.io", Version: "v1", Resource: "sriovnetworks"}, kind: "SriovNetwork", namespaced: true, status: true},
+	{gvr: schema.GroupVersionResource{Group: "sriovnetwork.openshift.io", Version: "v1", Resource: "sriovnetworknodepolicies"}, kind: "SriovNetworkNodePolicy", namespaced: true, status: true},
+	{gvr: schema.GroupVersionResource{Group: "sriovnetwork.openshift.io", Version: "v1", Resource: "sriovnetworknodestates"}, kind: "SriovNetworkNodeState", namespaced: true, status: true},
+	{gvr: schema.GroupVersionResource{Group: "sriovnetwork.openshift.io", Version: "v1", Resource: "sriovoperatorconfigs"}, kind: "SriovOperatorConfig", namespaced: true, status: true},
+	{gvr: schema.GroupVersionResource{Group: "k8s.cni.cncf.io", Version: "v1", Resource: "network-attachment-definitions"}, kind: "NetworkAttachmentDefinition", namespaced: true},
+}
+
+type fakeObject = map[string]interface{}
+
+type fakeEvent struct {
+	resourceVersion int64
+	eventType       watch.EventType
+	resource        *fakeResource
+	object          fakeObject
+}
+
+// fakeServer is a minimal in memory api server: it serves discovery and the
+// get, list, watch, create, update, patch and delete verbs of fakeResources.
+// There is no admission, defaulting nor garbage collection, apart from the
+// objects of a deleted namespace.
+type fakeServer struct {
+	mu              sync.Mutex
+	resourceVersion int64
+	objects         map[*fakeResource]map[string]fakeObject
+	events          []fakeEvent
+	watchers        map[chan fakeEvent]bool
+}
+
+func newFakeServer() *fakeServer {
+	s := &fakeServer{
+		objects:  map[*fakeResource]map[string]fakeObject{},
+		watchers: map[chan fakeEvent]bool{},
+	}
+	for _, r := range fakeResources {
+		s.objects[r] = map[string]fakeObject{}
+	}
+	return s
+}
+
+func fakeResourceFor(gvk schema.GroupVersionKind) *fakeResource {
+	for _, r := range fakeResources {
+		if r.gvr.Group == gvk.Group && r.gvr.Version == gvk.Version && r.kind == gvk.Kind {
+			return r
+		}
+	}
+	return nil
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.serveDiscovery(w, r) {
+		return
+	}
+	req, err := parseFakeRequest(r)
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && req.name == "" && isWatch(r):
+		s.watch(w, r, req)
+	case r.Method == http.MethodGet && req.name == "":
+		s.respond(w, http.StatusOK, s.list(req))
+	case r.Method == http.MethodGet:
+		s.respond(w, http.StatusOK)(s.get(req))
+	case r.Method == http.MethodPost && req.name == "":
+		s.withBody(w, r, http.StatusCreated, s.create, req)
+	case r.Method == http.MethodPut && req.name != "":
+		s.withBody(w, r, http.StatusOK, s.update, req)
+	case r.Method == http.MethodPatch && req.name != "":
+		s.withBody(w, r, http.StatusOK, func(req *fakeRequest, patch fakeObject) (fakeObject, error) {
+			return s.patch(req, r.Header.Get("Content-Type"), patch)
+		}, req)
+	case r.Method == http.MethodDelete && req.name == "":
+		s.respond(w, http.StatusOK, s.deleteCollection(req))
+	case r.Method == http.MethodDelete:
+		s.respond(w, http.StatusOK)(s.delete(req))
+	default:
+		writeStatus(w, k8serrors.NewMethodNotSupported(req.resource.gvr.GroupResource(), r.Method))
+	}
+}
+
+// respond returns a function writing the object or the error, so it can be
+// called with the results of the handlers directly.
+func (s *fakeServer) respond(w http.ResponseWriter, code int, objs ...fakeObject) func(fakeObject, error) {
+	write := func(obj fakeObject, err error) {
+		if err != nil {
+			writeStatus(w, err)
+			return
+		}
+		writeJSON(w, code, obj)
+	}
+	if len(objs) > 0 {
+		write(objs[0], nil)
+	}
+	return write
+}
+
+func (s *fakeServer) withBody(w http.ResponseWriter, r *http.Request, code int, handler func(*fakeRequest, fakeObject) (fakeObject, error), req *fakeRequest) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeStatus(w, k8serrors.NewBadRequest(err.Error()))
+		return
+	}
+	body := fakeObject{}
+	if strings.Contains(r.Header.Get("Content-Type"), "json-patch") {
+		// a json patch is a list, it's applied as is
+		body["patch"] = string(data)
+	} else if err := json.Unmarshal(data, &body); err != nil {
+		writeStatus(w, k8serrors.NewBadRequest(err.Error()))
+		return
+	}
+	s.respond(w, code)(handler(req, body))
+}
+
+// fakeRequest is a request to a resource, as parsed from the url.
+type fakeRequest struct {
+	resource    *fakeResource
+	namespace   string
+	name        string
+	subresource string
+	labels      labels.Selector
+	fields      fields.Selector
+}
+
+func parseFakeRequest(r *http.Request) (*fakeRequest, error) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	var gv schema.GroupVersion
+	switch {
+	case len(segments) > 2 && segments[0] == "api":
+		gv = schema.GroupVersion{Version: segments[1]}
+		segments = segments[2:]
+	case len(segments) > 3 && segments[0] == "apis":
+		gv = schema.GroupVersion{Group: segments[1], Version: segments[2]}
+		segments = segments[3:]
+	default:
+		return nil, k8serrors.NewNotFound(schema.GroupResource{}, r.URL.Path)
+	}
+
+	req := &fakeRequest{}
+	if segments[0] == "namespaces" && len(segments) > 2 && segments[2] != "status" && segments[2] != "finalize" {
+		req.namespace = segments[1]
+		segments = segments[2:]
+	}
+	for _, res := range fakeResources {
+		if res.gvr.GroupVersion() == gv && res.gvr.Resource == segments[0] {
+			req.resource = res
+		}
+	}
+	if req.resource == nil {
+		return nil, k8serrors.NewNotFound(gv.WithResource(segments[0]).GroupResource(), "")
+	}
+	if len(segments) > 1 {
+		req.name = segments[1]
+	}
+	if len(segments) > 2 {
+		req.subresource = segments[2]
+		if req.subresource != "status" || !req.resource.status {
+			return nil, k8serrors.NewNotFound(req.resource.gvr.GroupResource(), req.name+"/"+req.subresource)
+		}
+	}
+
+	var err error
+	query := r.URL.Query()
+	req.labels, err = labels.Parse(query.Get("labelSelector"))
+	if err != nil {
+		return nil, k8serrors.NewBadRequest(err.Error())
+	}
+	req.fields, err = fields.ParseSelector(query.Get("fieldSelector"))
+	if err != nil {
+		return nil, k8serrors.NewBadRequest(err.Error())
+	}
+	return req, nil
+}
+
+func isWatch(r *http.Request) bool {
+	w := r.URL.Query().Get("watch")
+	return w == "true" || w == "1"
+}
+
+func (req *fakeRequest) key(namespace, name string) string {
+	if !req.resource.namespaced {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// matches tells if the object is selected by the namespace and the selectors of the request.
+func (req *fakeRequest) matches(obj fakeObject) bool {
+	if req.resource.namespaced && req.namespace != "" && stringField(obj, "metadata.namespace") != req.namespace {
+		return false
+	}
+	objLabels := map[string]string{}
+	if l, ok := nestedMap(obj, "metadata")["labels"].(map[string]interface{}); ok {
+		for k, v := range l {
+			objLabels[k] = fmt.Sprint(v)
+		}
+	}
+	if !req.labels.Matches(labels.Set(objLabels)) {
+		return false
+	}
+	for _, r := range req.fields.Requirements() {
+		value := stringField(obj, r.Field)
+		switch r.Operator {
+		case selection.Equals, selection.DoubleEquals:
+			if value != r.Value {
+				return false
+			}
+		case selection.NotEquals:
+			if value == r.Value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *fakeServer) get(req *fakeRequest) (fakeObject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getLocked(req)
+}
+
+func (s *fakeServer) getLocked(req *fakeRequest) (fakeObject, error) {
+	obj, ok := s.objects[req.resource][req.key(req.namespace, req.name)]
+	if !ok {
+		return nil, k8serrors.NewNotFound(req.resource.gvr.GroupResource(), req.name)
+	}
+	return obj, nil
+}
+
+func (s *fakeServer) list(req *fakeRequest) fakeObject {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listLocked(req)
+}
+
+func (s *fakeServer) listLocked(req *fakeRequest) fakeObject {
+	items := []interface{}{}
+	for _, obj := range s.objects[req.resource] {
+		if req.matches(obj) {
+			items = append(items, obj)
+		}
+	}
+	return fakeObject{
+		"apiVersion": req.resource.apiVersion(),
+		"kind":       req.resource.kind + "List",
+		"metadata":   fakeObject{"resourceVersion": strconv.FormatInt(s.resourceVersion, 10)},
+		"items":      items,
+	}
+}
+
+// add stores a new object, for the requests and for the initial objects.
+func (s *fakeServer) add(res *fakeResource, obj fakeObject) (fakeObject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta := nestedMap(obj, "metadata")
+	name, _ := meta["name"].(string)
+	if name == "" {
+		if generateName, _ := meta["generateName"].(string); generateName != "" {
+			name = generateName + rand.String(5)
+			meta["name"] = name
+		}
+	}
+	if name == "" {
+		return nil, k8serrors.NewBadRequest("name or generateName is required")
+	}
+	namespace, _ := meta["namespace"].(string)
+	if res.namespaced {
+		if namespace == "" {
+			return nil, k8serrors.NewBadRequest("the namespace of the object is required")
+		}
+	} else {
+		delete(meta, "namespace")
+	}
+	key := (&fakeRequest{resource: res}).key(namespace, name)
+	if _, ok := s.objects[res][key]; ok {
+		return nil, k8serrors.NewAlreadyExists(res.gvr.GroupResource(), name)
+	}
+
+	obj["apiVersion"] = res.apiVersion()
+	obj["kind"] = res.kind
+	meta["uid"] = string(types.UID(rand.String(16)))
+	meta["creationTimestamp"] = time.Now().UTC().Format(time.RFC3339)
+	meta["generation"] = int64(1)
+	if res.kind == "Namespace" {
+		obj["status"] = fakeObject{"phase": "Active"}
+	}
+	s.store(watch.Added, res, key, obj)
+	return obj, nil
+}
+
+func (s *fakeServer) create(req *fakeRequest, obj fakeObject) (fakeObject, error) {
+	if req.resource.namespaced {
+		nestedMap(obj, "metadata")["namespace"] = req.namespace
+	}
+	return s.add(req.resource, obj)
+}
+
+func (s *fakeServer) update(req *fakeRequest, obj fakeObject) (fakeObject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateLocked(req, obj)
+}
+
+func (s *fakeServer) updateLocked(req *fakeRequest, obj fakeObject) (fakeObject, error) {
+	key := req.key(req.namespace, req.name)
+	old, ok := s.objects[req.resource][key]
+	if !ok {
+		return nil, k8serrors.NewNotFound(req.resource.gvr.GroupResource(), req.name)
+	}
+	meta := nestedMap(obj, "metadata")
+	oldMeta := nestedMap(old, "metadata")
+	if rv, _ := meta["resourceVersion"].(string); rv != "" && rv != oldMeta["resourceVersion"] {
+		return nil, k8serrors.NewConflict(req.resource.gvr.GroupResource(), req.name,
+			fmt.Errorf("the object has been modified; please apply your changes to the latest version and try again"))
+	}
+
+	if req.subresource == "status" {
+		status := obj["status"]
+		obj = deepCopy(old)
+		obj["status"] = status
+		meta = nestedMap(obj, "metadata")
+	} else if req.resource.status {
+		if status, ok := old["status"]; ok {
+			obj["status"] = status
+		} else {
+			delete(obj, "status")
+		}
+	}
+
+	for _, field := range []string{"uid", "creationTimestamp", "namespace", "name"} {
+		if value, ok := oldMeta[field]; ok {
+			meta[field] = value
+		}
+	}
+	obj["apiVersion"] = req.resource.apiVersion()
+	obj["kind"] = req.resource.kind
+	meta["generation"] = oldMeta["generation"]
+	if !reflect.DeepEqual(withoutMetaAndStatus(old), withoutMetaAndStatus(obj)) {
+		meta["generation"] = toInt64(oldMeta["generation"]) + 1
+	}
+	s.store(watch.Modified, req.resource, key, obj)
+	return obj, nil
+}
+
+// patch applies merge and json patches. The strategic merge patches are
+// applied as merge patches, lists are replaced instead of merged. The lock is
+// held from the read to the write, so concurrent writes are not lost.
+func (s *fakeServer) patch(req *fakeRequest, contentType string, body fakeObject) (fakeObject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.getLocked(req)
+	if err != nil {
+		return nil, err
+	}
+	original, err := json.Marshal(current)
+	if err != nil {
+		return nil, k8serrors.NewInternalError(err)
+	}
+
+	var patched []byte
+	if strings.Contains(contentType, "json-patch") {
+		patch, err := jsonpatch.DecodePatch([]byte(body["patch"].(string)))
+		if err != nil {
+			return nil, k8serrors.NewBadRequest(err.Error())
+		}
+		patched, err = patch.Apply(original)
+		if err != nil {
+			return nil, k8serrors.NewBadRequest(err.Error())
+		}
+	} else {
+		patch, err := json.Marshal(body)
+		if err != nil {
+			return nil, k8serrors.NewBadRequest(err.Error())
+		}
+		patched, err = jsonpatch.MergePatch(original, patch)
+		if err != nil {
+			return nil, k8serrors.NewBadRequest(err.Error())
+		}
+	}
+
+	obj := fakeObject{}
+	if err := json.Unmarshal(patched, &obj); err != nil {
+		return nil, k8serrors.NewBadRequest(err.Error())
+	}
+	// the resource version is not checked on patches
+	delete(nestedMap(obj, "metadata"), "resourceVersion")
+	return s.updateLocked(req, obj)
+}
+
+func (s *fakeServer) delete(req *fakeRequest) (fakeObject, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := req.key(req.namespace, req.name)
+	obj, ok := s.objects[req.resource][key]
+	if !ok {
+		return nil, k8serrors.NewNotFound(req.resource.gvr.GroupResource(), req.name)
+	}
+	s.remove(req.resource, key, deepCopy(obj))
+	return obj, nil
+}
+
+func (s *fakeServer) deleteCollection(req *fakeRequest) fakeObject {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := s.listLocked(req)
+	for _, item := range list["items"].([]interface{}) {
+		obj := item.(fakeObject)
+		s.remove(req.resource, req.key(stringField(obj, "metadata.namespace"), stringField(obj, "metadata.name")), deepCopy(obj))
+	}
+	return list
+}
+
+// remove deletes the object, and the content of the namespace when a namespace is deleted.
+func (s *fakeServer) remove(res *fakeResource, key string, obj fakeObject) {
+	delete(s.objects[res], key)
+	s.notify(watch.Deleted, res, obj)
+	if res.kind != "Namespace" {
+		return
+	}
+	for _, r := range fakeResources {
+		if !r.namespaced {
+			continue
+		}
+		for k, o := range s.objects[r] {
+			if stringField(o, "metadata.namespace") == key {
+				delete(s.objects[r], k)
+				s.notify(watch.Deleted, r, deepCopy(o))
+			}
+		}
+	}
+}
+
+// store saves the object with a new resource version and notifies the watchers.
+func (s *fakeServer) store(eventType watch.EventType, res *fakeResource, key string, obj fakeObject) {
+	s.objects[res][key] = obj
+	s.notify(eventType, res, obj)
+}
+
+func (s *fakeServer) notify(eventType watch.EventType, res *fakeResource, obj fakeObject) {
+	s.resourceVersion++
+	nestedMap(obj, "metadata")["resourceVersion"] = strconv.FormatInt(s.resourceVersion, 10)
+	event := fakeEvent{resourceVersion: s.resourceVersion, eventType: eventType, resource: res, object: deepCopy(obj)}
+	s.events = append(s.events, event)
+	for ch := range s.watchers {
+		select {
+		case ch <- event:
+		default:
+			// the watcher is too slow, the client will list again
+			delete(s.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// watch streams the events of the resource. Without resource version, the
+// existing objects are sent as added first, as the real api server does.
+func (s *fakeServer) watch(w http.ResponseWriter, r *http.Request, req *fakeRequest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeStatus(w, k8serrors.NewInternalError(fmt.Errorf("streaming not supported")))
+		return
+	}
+
+	ch := make(chan fakeEvent, 100)
+	initial := []fakeEvent{}
+	s.mu.Lock()
+	resourceVersion, err := strconv.ParseInt(r.URL.Query().Get("resourceVersion"), 10, 64)
+	if err != nil || resourceVersion == 0 {
+		for _, obj := range s.objects[req.resource] {
+			initial = append(initial, fakeEvent{eventType: watch.Added, resource: req.resource, object: deepCopy(obj)})
+		}
+	} else {
+		for _, e := range s.events {
+			if e.resourceVersion > resourceVersion {
+				initial = append(initial, e)
+			}
+		}
+	}
+	s.watchers[ch] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.watchers[ch] {
+			delete(s.watchers, ch)
+			close(ch)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	encoder := json.NewEncoder(w)
+	send := func(e fakeEvent) bool {
+		if e.resource != req.resource || !req.matches(e.object) {
+			return true
+		}
+		err := encoder.Encode(fakeObject{"type": e.eventType, "object": e.object})
+		flusher.Flush()
+		return err == nil
+	}
+
+	for _, e := range initial {
+		if !send(e) {
+			return
+		}
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok || !send(e) {
+				return
+			}
+		}
+	}
+}
+
+func (s *fakeServer) serveDiscovery(w http.ResponseWriter, r *http.Request) bool {
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "api" {
+		writeJSON(w, http.StatusOK, &metav1.APIVersions{
+			TypeMeta: metav1.TypeMeta{Kind: "APIVersions"},
+			Versions: []string{"v1"},
+		})
+		return true
+	}
+	if path == "apis" {
+		list := &metav1.APIGroupList{TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"}}
+		seen := map[string]bool{}
+		for _, res := range fakeResources {
+			gv := res.gvr.GroupVersion()
+			if gv.Group == "" || seen[gv.Group] {
+				continue
+			}
+			seen[gv.Group] = true
+			version := metav1.GroupVersionForDiscovery{GroupVersion: gv.String(), Version: gv.Version}
+			list.Groups = append(list.Groups, metav1.APIGroup{
+				Name:             gv.Group,
+				Versions:         []metav1.GroupVersionForDiscovery{version},
+				PreferredVersion: version,
+			})
+		}
+		writeJSON(w, http.StatusOK, list)
+		return true
+	}
+
+	var gv schema.GroupVersion
+	segments := strings.Split(path, "/")
+	switch {
+	case len(segments) == 2 && segments[0] == "api":
+		gv = schema.GroupVersion{Version: segments[1]}
+	case len(segments) == 3 && segments[0] == "apis":
+		gv = schema.GroupVersion{Group: segments[1], Version: segments[2]}
+	default:
+		return false
+	}
+	list := &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: gv.String(),
+	}
+	verbs := metav1.Verbs{"create", "delete", "deletecollection", "get", "list", "patch", "update", "watch"}
+	for _, res := range fakeResources {
+		if res.gvr.GroupVersion() != gv {
+			continue
+		}
+		list.APIResources = append(list.APIResources, metav1.APIResource{
+			Name:       res.gvr.Resource,
+			Namespaced: res.namespaced,
+			Kind:       res.kind,
+			Verbs:      verbs,
+		})
+		if res.status {
+			list.APIResources = append(list.APIResources, metav1.APIResource{
+				Name:       res.gvr.Resource + "/status",
+				Namespaced: res.namespaced,
+				Kind:       res.kind,
+				Verbs:      metav1.Verbs{"get", "patch", "update"},
+			})
+		}
+	}
+	if len(list.APIResources) == 0 {
+		writeStatus(w, k8serrors.NewNotFound(schema.GroupResource{}, path))
+		return true
+	}
+	writeJSON(w, http.StatusOK, list)
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(obj)
+}
+
+func writeStatus(w http.ResponseWriter, err error) {
+	statusErr, ok := err.(*k8serrors.StatusError)
+	if !ok {
+		statusErr = k8serrors.NewInternalError(err)
+	}
+	status := statusErr.ErrStatus
+	status.Kind = "Status"
+	status.APIVersion = "v1"
+	writeJSON(w, int(status.Code), status)
+}
+
+func nestedMap(obj fakeObject, field string) fakeObject {
+	res, ok := obj[field].(map[string]interface{})
+	if !ok {
+		res = fakeObject{}
+		obj[field] = res
+	}
+	return res
+}
+
+// stringField returns the value of the dotted field path, as used by the field selectors.
+func stringField(obj fakeObject, path string) string {
+	var current interface{} = obj
+	for _, f := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		current = m[f]
+	}
+	if current == nil {
+		return ""
+	}
+	return fmt.Sprint(current)
+}
+
+func withoutMetaAndStatus(obj fakeObject) fakeObject {
+	res := fakeObject{}
+	for k, v := range obj {
+		if k != "metadata" && k != "status" && k != "apiVersion" && k != "kind" {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+func deepCopy(obj fakeObject) fakeObject {
+	data, _ := json.Marshal(obj)
+	res := fakeObject{}
+	json.Unmarshal(data, &res)
+	return res
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
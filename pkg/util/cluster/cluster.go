@@ -5,6 +5,7 @@ import (
 
 	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
 	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+	"github.com/openshift/sriov-tests/pkg/util/nics"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -12,18 +13,22 @@ import (
 type EnabledNodes struct {
 	Nodes  []string
 	States map[string]sriovv1.SriovNetworkNodeState
+	// Catalog tells which cards are supported and their capabilities.
+	Catalog *nics.Catalog
 }
 
-var (
-	supportedDrivers = []string{"mlx5_core", "i40e", "ixgbe"}
-)
-
 // DiscoverSriov retrieves Sriov related information of a given cluster.
 func DiscoverSriov(clients *testclient.ClientSet, operatorNamespace string) (*EnabledNodes, error) {
+	catalog, err := nics.Load()
+	if err != nil {
+		return nil, err
+	}
+
 	nodeStates, err := clients.SriovNetworkNodeStates(operatorNamespace).List(metav1.ListOptions{})
 	res := &EnabledNodes{}
 	res.States = make(map[string]sriovv1.SriovNetworkNodeState)
 	res.Nodes = make([]string, 0)
+	res.Catalog = catalog
 	if err != nil {
 		return nil, fmt.Errorf("Failed to retrieve note states %v", err)
 	}
@@ -35,7 +40,7 @@ func DiscoverSriov(clients *testclient.ClientSet, operatorNamespace string) (*En
 
 		node := state.Name
 		for _, itf := range state.Status.Interfaces {
-			if isDriverSupported(itf.Driver) {
+			if catalog.IsSupported(itf) {
 				res.Nodes = append(res.Nodes, node)
 				res.States[node] = state
 				break
@@ -55,8 +60,12 @@ func (n *EnabledNodes) FindOneSriovDevice(node string) (*sriovv1.InterfaceExt, e
 	if !ok {
 		return nil, fmt.Errorf("Node %s not found", node)
 	}
+	catalog := n.Catalog
+	if catalog == nil {
+		catalog = nics.Default()
+	}
 	for _, itf := range s.Status.Interfaces {
-		if isDriverSupported(itf.Driver) {
+		if catalog.IsSupported(itf) {
 			return &itf, nil
 		}
 	}
@@ -64,6 +73,19 @@ func (n *EnabledNodes) FindOneSriovDevice(node string) (*sriovv1.InterfaceExt, e
 	return nil, fmt.Errorf("Unable to find sriov devices in node %s", node)
 }
 
+// FindBestSriovDevice retrieves the valid sriov device of the given node with the
+// most vfs and the fastest link, as ranked by FindSriovDevices.
+func (n *EnabledNodes) FindBestSriovDevice(node string) (*sriovv1.InterfaceExt, error) {
+	selection, err := n.FindSriovDevices(node)
+	if err != nil {
+		return nil, err
+	}
+	if len(selection.Candidates) == 0 {
+		return nil, fmt.Errorf("Unable to find sriov devices in node %s", node)
+	}
+	return &selection.Candidates[0], nil
+}
+
 // SriovStable tells if all the node states are in sync (and the cluster is ready for another round of tests)
 func SriovStable(operatorNamespace string, clients *testclient.ClientSet) (bool, error) {
 	nodeStates, err := clients.SriovNetworkNodeStates(operatorNamespace).List(metav1.ListOptions{})
@@ -81,6 +103,40 @@ func SriovStable(operatorNamespace string, clients *testclient.ClientSet) (bool,
 	return true, nil
 }
 
+// DaemonsScheduledOnNodes tells if the config daemons run on the nodes matching the
+// selector, one per node and on no other node.
+func DaemonsScheduledOnNodes(clients *testclient.ClientSet, operatorNamespace, selector string) (bool, error) {
+	nn, err := clients.Nodes().List(metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return false, fmt.Errorf("Failed to list nodes %v", err)
+	}
+	nodes := nn.Items
+
+	daemons, err := clients.Pods(operatorNamespace).List(metav1.ListOptions{LabelSelector: "app=sriov-network-config-daemon"})
+	if err != nil {
+		return false, fmt.Errorf("Failed to list config daemons %v", err)
+	}
+	for _, d := range daemons.Items {
+		foundNode := false
+		for i, n := range nodes {
+			if d.Spec.NodeName == n.Name {
+				foundNode = true
+				// Removing the element from the list as we want to make sure
+				// the daemons are running on different nodes
+				nodes = append(nodes[:i], nodes[i+1:]...)
+				break
+			}
+		}
+		if !foundNode {
+			return false, nil
+		}
+	}
+	// the nodes left have no daemon
+	return len(nodes) == 0, nil
+}
+
 func stateStable(state sriovv1.SriovNetworkNodeState) bool {
 	switch state.Status.SyncStatus {
 	case "Succeeded":
@@ -90,13 +146,3 @@ func stateStable(state sriovv1.SriovNetworkNodeState) bool {
 	}
 	return false
 }
-
-func isDriverSupported(driver string) bool {
-	for _, supportedDriver := range supportedDrivers {
-		if driver == supportedDriver {
-			return true
-		}
-	}
-
-	return false
-}
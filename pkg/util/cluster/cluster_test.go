@@ -0,0 +1,128 @@
+package cluster
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+)
+
+const operatorNamespace = "sriov-operator"
+
+func fakeState(node, syncStatus string, interfaces ...sriovv1.InterfaceExt) *sriovv1.SriovNetworkNodeState {
+	return &sriovv1.SriovNetworkNodeState{
+		ObjectMeta: metav1.ObjectMeta{Name: node, Namespace: operatorNamespace},
+		Status: sriovv1.SriovNetworkNodeStateStatus{
+			SyncStatus: syncStatus,
+			Interfaces: interfaces,
+		},
+	}
+}
+
+func fakeNode(name string, labels map[string]string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func fakeDaemon(name, node string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sriov-network-config-daemon-" + name,
+			Namespace: operatorNamespace,
+			Labels:    map[string]string{"app": "sriov-network-config-daemon"},
+		},
+		Spec: corev1.PodSpec{NodeName: node},
+	}
+}
+
+// stopFakes are the servers of the fake clients created by the running spec.
+var stopFakes []func()
+
+var _ = AfterEach(func() {
+	for _, stop := range stopFakes {
+		stop()
+	}
+	stopFakes = nil
+})
+
+func fakeClients(objects ...runtime.Object) *testclient.ClientSet {
+	clients, stop, err := testclient.NewFake(objects...)
+	Expect(err).ToNot(HaveOccurred())
+	stopFakes = append(stopFakes, stop)
+	return clients
+}
+
+var (
+	mlx  = testInterface("ens1f0", "0000:3b:00.0", "mlx5_core", 8, "25000 Mb/s")
+	tg3  = testInterface("eno1", "0000:19:00.0", "tg3", 0, "1000 Mb/s")
+	i40e = testInterface("ens2f0", "0000:5e:00.0", "i40e", 64, "10000 Mb/s")
+)
+
+var _ = Describe("DiscoverSriov", func() {
+	DescribeTable("finds the nodes with supported cards",
+		func(states []runtime.Object, expectedNodes []string, expectedErr string) {
+			res, err := DiscoverSriov(fakeClients(states...), operatorNamespace)
+			if expectedErr != "" {
+				Expect(err).To(MatchError(ContainSubstring(expectedErr)))
+				return
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(res.Nodes).To(ConsistOf(expectedNodes))
+			Expect(res.Catalog).ToNot(BeNil())
+		},
+		Entry("without node states", nil, nil, "No sriov enabled node found"),
+		Entry("with unsupported cards only",
+			[]runtime.Object{fakeState("worker-0", "Succeeded", tg3)}, nil, "No sriov enabled node found"),
+		Entry("while a node is syncing",
+			[]runtime.Object{fakeState("worker-0", "InProgress", mlx)}, nil, "Sync status still in progress"),
+		Entry("with supported cards",
+			[]runtime.Object{
+				fakeState("worker-0", "Succeeded", tg3, mlx),
+				fakeState("worker-1", "", i40e),
+				fakeState("worker-2", "Succeeded", tg3),
+			}, []string{"worker-0", "worker-1"}, ""),
+	)
+})
+
+var _ = Describe("SriovStable", func() {
+	DescribeTable("tells if all the states are in sync",
+		func(states []runtime.Object, expected bool) {
+			stable, err := SriovStable(operatorNamespace, fakeClients(states...))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stable).To(Equal(expected))
+		},
+		Entry("without node states", nil, false),
+		Entry("with all the states synced",
+			[]runtime.Object{fakeState("worker-0", "Succeeded"), fakeState("worker-1", "")}, true),
+		Entry("with a state in progress",
+			[]runtime.Object{fakeState("worker-0", "Succeeded"), fakeState("worker-1", "InProgress")}, false),
+		Entry("with a failed state",
+			[]runtime.Object{fakeState("worker-0", "Failed")}, false),
+	)
+})
+
+var _ = Describe("DaemonsScheduledOnNodes", func() {
+	nodes := []runtime.Object{
+		fakeNode("worker-0", map[string]string{"sriovenabled": "true"}),
+		fakeNode("worker-1", map[string]string{"sriovenabled": "true"}),
+		fakeNode("master-0", nil),
+	}
+
+	DescribeTable("checks the daemons run only on the selected nodes",
+		func(daemons []runtime.Object, selector string, expected bool) {
+			scheduled, err := DaemonsScheduledOnNodes(fakeClients(append(daemons, nodes...)...), operatorNamespace, selector)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(scheduled).To(Equal(expected))
+		},
+		Entry("on the selected nodes", []runtime.Object{fakeDaemon("a", "worker-0"), fakeDaemon("b", "worker-1")}, "sriovenabled=true", true),
+		Entry("on a node out of the selector", []runtime.Object{fakeDaemon("a", "worker-0"), fakeDaemon("b", "master-0")}, "sriovenabled=true", false),
+		Entry("missing on a selected node", []runtime.Object{fakeDaemon("a", "worker-0")}, "sriovenabled=true", false),
+		Entry("twice on the same node", []runtime.Object{fakeDaemon("a", "worker-0"), fakeDaemon("b", "worker-0")}, "sriovenabled=true", false),
+		Entry("on the nodes out of the selector", []runtime.Object{fakeDaemon("a", "master-0")}, "sriovenabled!=true", true),
+	)
+})
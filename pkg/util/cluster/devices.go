@@ -0,0 +1,228 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+
+	"github.com/openshift/sriov-tests/pkg/util/nics"
+)
+
+// Candidate is an interface considered by FindSriovDevices.
+type Candidate struct {
+	Node      string
+	Interface sriovv1.InterfaceExt
+	State     *sriovv1.SriovNetworkNodeState
+}
+
+// DeviceFilter tells if the candidate is suitable, and the reason when it is not.
+type DeviceFilter func(c Candidate) (bool, string)
+
+// DeviceSelection is the outcome of FindSriovDevices.
+type DeviceSelection struct {
+	Node string
+	// Candidates are the accepted interfaces, the ones with more vfs and
+	// faster links first.
+	Candidates []sriovv1.InterfaceExt
+	// Rejected maps the name of the rejected interfaces to the reason.
+	Rejected map[string]string
+}
+
+// String describes why the interfaces were rejected.
+func (s *DeviceSelection) String() string {
+	names := []string{}
+	for name := range s.Rejected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	reasons := []string{}
+	for _, name := range names {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", name, s.Rejected[name]))
+	}
+	return fmt.Sprintf("node %s: %d candidates, rejected [%s]", s.Node, len(s.Candidates), strings.Join(reasons, "; "))
+}
+
+// FindSriovDevices returns the interfaces of the node accepted by all the filters,
+// ranked by total vfs and link speed. Interfaces not in the catalog are always rejected.
+func (n *EnabledNodes) FindSriovDevices(node string, filters ...DeviceFilter) (*DeviceSelection, error) {
+	s, ok := n.States[node]
+	if !ok {
+		return nil, fmt.Errorf("Node %s not found", node)
+	}
+	catalog := n.Catalog
+	if catalog == nil {
+		catalog = nics.Default()
+	}
+
+	res := &DeviceSelection{Node: node, Rejected: map[string]string{}}
+	for _, itf := range s.Status.Interfaces {
+		if !catalog.IsSupported(itf) {
+			res.Rejected[itf.Name] = fmt.Sprintf("%s:%s (%s) not in the nic catalog", itf.Vendor, itf.DeviceID, itf.Driver)
+			continue
+		}
+		c := Candidate{Node: node, Interface: itf, State: &s}
+		accepted := true
+		for _, f := range filters {
+			if ok, reason := f(c); !ok {
+				res.Rejected[itf.Name] = reason
+				accepted = false
+				break
+			}
+		}
+		if accepted {
+			res.Candidates = append(res.Candidates, itf)
+		}
+	}
+
+	sort.SliceStable(res.Candidates, func(i, j int) bool {
+		a, b := res.Candidates[i], res.Candidates[j]
+		if a.TotalVfs != b.TotalVfs {
+			return a.TotalVfs > b.TotalVfs
+		}
+		return linkSpeedMbps(a.LinkSpeed) > linkSpeedMbps(b.LinkSpeed)
+	})
+	return res, nil
+}
+
+// FindNodeWithDevices returns the first node having at least count interfaces
+// accepted by the filters. The error describes the rejected interfaces of
+// each node, so it can be used as the reason to skip a spec.
+func (n *EnabledNodes) FindNodeWithDevices(count int, filters ...DeviceFilter) (*DeviceSelection, error) {
+	reasons := []string{}
+	for _, node := range n.Nodes {
+		selection, err := n.FindSriovDevices(node, filters...)
+		if err != nil {
+			return nil, err
+		}
+		if len(selection.Candidates) >= count {
+			return selection, nil
+		}
+		reasons = append(reasons, selection.String())
+	}
+	return nil, fmt.Errorf("No node with %d suitable sriov devices found: %s", count, strings.Join(reasons, ", "))
+}
+
+// WithDriver accepts the interfaces bound to one of the given drivers.
+func WithDriver(drivers ...string) DeviceFilter {
+	return func(c Candidate) (bool, string) {
+		for _, d := range drivers {
+			if c.Interface.Driver == d {
+				return true, ""
+			}
+		}
+		return false, fmt.Sprintf("driver %s not in %v", c.Interface.Driver, drivers)
+	}
+}
+
+// WithDevice accepts the interfaces of the given vendor and device id.
+// An empty device id matches any device of the vendor.
+func WithDevice(vendor, deviceID string) DeviceFilter {
+	return func(c Candidate) (bool, string) {
+		if c.Interface.Vendor == vendor && (deviceID == "" || c.Interface.DeviceID == deviceID) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("device %s:%s is not %s:%s", c.Interface.Vendor, c.Interface.DeviceID, vendor, deviceID)
+	}
+}
+
+// WithMinTotalVfs accepts the interfaces supporting at least the given number of vfs.
+func WithMinTotalVfs(count int) DeviceFilter {
+	return func(c Candidate) (bool, string) {
+		if c.Interface.TotalVfs >= count {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%d total vfs, %d needed", c.Interface.TotalVfs, count)
+	}
+}
+
+// WithMinLinkSpeed accepts the interfaces with a link at least as fast as the given speed.
+func WithMinLinkSpeed(mbps int) DeviceFilter {
+	return func(c Candidate) (bool, string) {
+		if linkSpeedMbps(c.Interface.LinkSpeed) >= mbps {
+			return true, ""
+		}
+		return false, fmt.Sprintf("link speed %q below %d Mb/s", c.Interface.LinkSpeed, mbps)
+	}
+}
+
+// WithCapability accepts the interfaces whose nic supports the given capability.
+func WithCapability(catalog *nics.Catalog, capability nics.Capability) DeviceFilter {
+	return func(c Candidate) (bool, string) {
+		return catalog.Supports(c.Interface, capability)
+	}
+}
+
+// NotIn rejects the given interfaces.
+func NotIn(names ...string) DeviceFilter {
+	return func(c Candidate) (bool, string) {
+		for _, name := range names {
+			if c.Interface.Name == name {
+				return false, "excluded"
+			}
+		}
+		return true, ""
+	}
+}
+
+// NotDefaultRoute rejects the interfaces carrying the default route of the node,
+// as given by the map of node names to interface names.
+func NotDefaultRoute(defaultRoutes map[string][]string) DeviceFilter {
+	return func(c Candidate) (bool, string) {
+		for _, name := range defaultRoutes[c.Node] {
+			if c.Interface.Name == name {
+				return false, "carries the default route"
+			}
+		}
+		return true, ""
+	}
+}
+
+// NotUsedByOtherPolicies rejects the interfaces configured by the policies not
+// accepted by isTestPolicy, as found in the spec of the node state.
+func NotUsedByOtherPolicies(policies []sriovv1.SriovNetworkNodePolicy, isTestPolicy func(p *sriovv1.SriovNetworkNodePolicy) bool) DeviceFilter {
+	otherResources := map[string]string{}
+	for i := range policies {
+		p := &policies[i]
+		if p.Name == "default" || isTestPolicy(p) {
+			continue
+		}
+		otherResources[p.Spec.ResourceName] = p.Name
+	}
+
+	return func(c Candidate) (bool, string) {
+		if c.State == nil {
+			return true, ""
+		}
+		for _, itf := range c.State.Spec.Interfaces {
+			if itf.PciAddress != c.Interface.PciAddress {
+				continue
+			}
+			for _, group := range itf.VfGroups {
+				if policy, ok := otherResources[group.ResourceName]; ok {
+					return false, fmt.Sprintf("used by policy %s", policy)
+				}
+			}
+		}
+		return true, ""
+	}
+}
+
+// linkSpeedMbps parses the link speed reported in the node state, i.e. "25000 Mb/s".
+// It returns 0 when the speed is unknown.
+func linkSpeedMbps(speed string) int {
+	fields := strings.Fields(speed)
+	if len(fields) == 0 {
+		return 0
+	}
+	value, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0
+	}
+	if len(fields) > 1 && strings.HasPrefix(fields[1], "Gb") {
+		return value * 1000
+	}
+	return value
+}
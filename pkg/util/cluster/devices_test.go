@@ -0,0 +1,168 @@
+package cluster
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/sriov-tests/pkg/util/nics"
+)
+
+func testInterface(name, pciAddress, driver string, totalVfs int, speed string) sriovv1.InterfaceExt {
+	vendor := "15b3"
+	if driver != "mlx5_core" {
+		vendor = "8086"
+	}
+	return sriovv1.InterfaceExt{
+		InterfaceProperty: sriovv1.InterfaceProperty{
+			Name:       name,
+			PciAddress: pciAddress,
+			Driver:     driver,
+			Vendor:     vendor,
+		},
+		TotalVfs:  totalVfs,
+		LinkSpeed: speed,
+	}
+}
+
+func testNodes() *EnabledNodes {
+	return &EnabledNodes{
+		Nodes: []string{"worker-0", "worker-1"},
+		States: map[string]sriovv1.SriovNetworkNodeState{
+			"worker-0": {
+				ObjectMeta: metav1.ObjectMeta{Name: "worker-0"},
+				Spec: sriovv1.SriovNetworkNodeStateSpec{
+					Interfaces: sriovv1.Interfaces{{
+						Name:       "ens1f1",
+						PciAddress: "0000:3b:00.1",
+						NumVfs:     4,
+						VfGroups:   []sriovv1.VfGroup{{ResourceName: "production", VfRange: "0-3"}},
+					}},
+				},
+				Status: sriovv1.SriovNetworkNodeStateStatus{
+					Interfaces: sriovv1.InterfaceExts{
+						testInterface("eno1", "0000:19:00.0", "tg3", 0, "1000 Mb/s"),
+						testInterface("ens1f0", "0000:3b:00.0", "mlx5_core", 8, "25000 Mb/s"),
+						testInterface("ens1f1", "0000:3b:00.1", "mlx5_core", 8, "25000 Mb/s"),
+						testInterface("ens2f0", "0000:5e:00.0", "i40e", 64, "10000 Mb/s"),
+					},
+				},
+			},
+			"worker-1": {
+				ObjectMeta: metav1.ObjectMeta{Name: "worker-1"},
+				Status: sriovv1.SriovNetworkNodeStateStatus{
+					Interfaces: sriovv1.InterfaceExts{
+						testInterface("ens1f0", "0000:3b:00.0", "mlx5_core", 16, "100000 Mb/s"),
+						testInterface("ens1f1", "0000:3b:00.1", "mlx5_core", 16, "100000 Mb/s"),
+					},
+				},
+			},
+		},
+		Catalog: nics.Default(),
+	}
+}
+
+func candidateNames(s *DeviceSelection) []string {
+	res := []string{}
+	for _, c := range s.Candidates {
+		res = append(res, c.Name)
+	}
+	return res
+}
+
+var _ = Describe("FindSriovDevices", func() {
+	It("ranks the supported interfaces and reports the rejected ones", func() {
+		s, err := testNodes().FindSriovDevices("worker-0")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(candidateNames(s)).To(Equal([]string{"ens2f0", "ens1f0", "ens1f1"}))
+		Expect(s.Rejected).To(HaveKeyWithValue("eno1", ContainSubstring("not in the nic catalog")))
+	})
+
+	It("applies the filters", func() {
+		policies := []sriovv1.SriovNetworkNodePolicy{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "production"},
+				Spec:       sriovv1.SriovNetworkNodePolicySpec{ResourceName: "production"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Labels: map[string]string{"test": "true"}},
+				Spec:       sriovv1.SriovNetworkNodePolicySpec{ResourceName: "testresource"},
+			},
+		}
+		isTestPolicy := func(p *sriovv1.SriovNetworkNodePolicy) bool {
+			return p.Labels["test"] == "true"
+		}
+
+		s, err := testNodes().FindSriovDevices("worker-0",
+			WithDriver("mlx5_core"),
+			NotUsedByOtherPolicies(policies, isTestPolicy),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(candidateNames(s)).To(Equal([]string{"ens1f0"}))
+		Expect(s.Rejected).To(HaveKeyWithValue("ens1f1", "used by policy production"))
+		Expect(s.Rejected).To(HaveKeyWithValue("ens2f0", ContainSubstring("driver i40e")))
+
+		s, err = testNodes().FindSriovDevices("worker-0",
+			WithMinTotalVfs(16),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(candidateNames(s)).To(Equal([]string{"ens2f0"}))
+
+		s, err = testNodes().FindSriovDevices("worker-0",
+			WithMinLinkSpeed(25000),
+			NotDefaultRoute(map[string][]string{"worker-0": {"ens1f0"}}),
+			NotIn("ens2f0"),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(candidateNames(s)).To(Equal([]string{"ens1f1"}))
+		Expect(s.Rejected).To(HaveKeyWithValue("ens1f0", "carries the default route"))
+
+		s, err = testNodes().FindSriovDevices("worker-0",
+			WithDevice("8086", ""),
+			WithCapability(nics.Default(), nics.RateLimit),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s.Candidates).To(BeEmpty())
+		Expect(s.String()).To(ContainSubstring("BZ 1772847"))
+	})
+
+	It("finds a node with enough devices", func() {
+		s, err := testNodes().FindNodeWithDevices(2, WithMinTotalVfs(16))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s.Node).To(Equal("worker-1"))
+
+		_, err = testNodes().FindNodeWithDevices(4, WithMinTotalVfs(8))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("node worker-0"))
+		Expect(err.Error()).To(ContainSubstring("node worker-1"))
+	})
+
+	It("fails on unknown nodes", func() {
+		_, err := testNodes().FindSriovDevices("worker-5")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("keeps FindOneSriovDevice returning the first supported interface", func() {
+		itf, err := testNodes().FindOneSriovDevice("worker-0")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(itf.Name).To(Equal("ens1f0"))
+	})
+
+	It("returns the best candidate with FindBestSriovDevice", func() {
+		itf, err := testNodes().FindBestSriovDevice("worker-0")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(itf.Name).To(Equal("ens2f0"))
+	})
+})
+
+var _ = Describe("linkSpeedMbps", func() {
+	It("parses the speeds", func() {
+		Expect(linkSpeedMbps("25000 Mb/s")).To(Equal(25000))
+		Expect(linkSpeedMbps("10 Gb/s")).To(Equal(10000))
+		Expect(linkSpeedMbps("")).To(Equal(0))
+		Expect(linkSpeedMbps("-1 Mb/s")).To(Equal(-1))
+		Expect(linkSpeedMbps("Unknown!")).To(Equal(0))
+	})
+})
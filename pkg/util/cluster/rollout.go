@@ -0,0 +1,312 @@
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+)
+
+// RolloutTimeout is the longest a wait for the node states is extended to
+// while the nodes are drained, rebooted or updated by the machine config operator.
+var RolloutTimeout = 45 * time.Minute
+
+// rolloutInterval is the interval the nodes and the pools are checked at.
+var rolloutInterval = 5 * time.Second
+
+// RolloutEventKind is the kind of change observed on the nodes or on the pools.
+type RolloutEventKind string
+
+// The kinds of the rollout events.
+const (
+	NodeCordoned   RolloutEventKind = "Cordoned"
+	NodeUncordoned RolloutEventKind = "Uncordoned"
+	NodeRebooted   RolloutEventKind = "Rebooted"
+	PoolUpdating   RolloutEventKind = "PoolUpdating"
+	PoolUpdated    RolloutEventKind = "PoolUpdated"
+	PoolDegraded   RolloutEventKind = "PoolDegraded"
+)
+
+// RolloutEvent is a change observed on a node or on a machine config pool.
+type RolloutEvent struct {
+	Time    time.Time
+	Kind    RolloutEventKind
+	Object  string
+	Message string
+}
+
+func (e RolloutEvent) String() string {
+	res := fmt.Sprintf("%s %-14s %s", e.Time.Format("15:04:05"), e.Kind, e.Object)
+	if e.Message != "" {
+		res += ": " + e.Message
+	}
+	return res
+}
+
+type nodeRollout struct {
+	unschedulable bool
+	bootID        string
+	// changed is set once the node is cordoned or uncordoned after the baseline.
+	changed bool
+}
+
+type poolRollout struct {
+	updating bool
+	degraded bool
+	// changed is set once the pool starts or stops updating after the baseline,
+	// degradedSince once it goes degraded after it.
+	changed       bool
+	degradedSince bool
+}
+
+// RolloutMonitor tracks the drains, the reboots and the machine config pool
+// updates triggered by the config daemon while applying the policies. Only the
+// sriov nodes and the pools they belong to are tracked, and only the changes
+// after the first observation count: a node cordoned or a pool updating or
+// degraded before it is taken as the baseline.
+type RolloutMonitor struct {
+	clients           *testclient.ClientSet
+	operatorNamespace string
+	log               io.Writer
+	nodes             map[string]nodeRollout
+	pools             map[string]poolRollout
+	noPools           bool
+	Timeline          []RolloutEvent
+}
+
+// NewRolloutMonitor returns a monitor of the nodes with a node state in the
+// operator namespace, writing the events to log as they are observed.
+func NewRolloutMonitor(clients *testclient.ClientSet, operatorNamespace string, log io.Writer) *RolloutMonitor {
+	return &RolloutMonitor{
+		clients:           clients,
+		operatorNamespace: operatorNamespace,
+		log:               log,
+		nodes:             map[string]nodeRollout{},
+		pools:             map[string]poolRollout{},
+	}
+}
+
+// Observe fetches the sriov nodes and their pools and records what changed since
+// the previous call. The pools are ignored on clusters without the machine config operator.
+func (m *RolloutMonitor) Observe() error {
+	states, err := m.clients.SriovNetworkNodeStates(m.operatorNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("Failed to list node states %v", err)
+	}
+	sriovNodes := map[string]bool{}
+	for _, s := range states.Items {
+		sriovNodes[s.Name] = true
+	}
+	list, err := m.clients.Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("Failed to list nodes %v", err)
+	}
+	nodes := []corev1.Node{}
+	for _, n := range list.Items {
+		if sriovNodes[n.Name] {
+			nodes = append(nodes, n)
+		}
+	}
+	pools := []mcfgv1.MachineConfigPool{}
+	if !m.noPools {
+		list, err := m.clients.MachineConfigPools().List(metav1.ListOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			m.noPools = true
+		case err != nil:
+			return fmt.Errorf("Failed to list machine config pools %v", err)
+		default:
+			pools = list.Items
+		}
+	}
+	m.observe(time.Now(), nodes, pools)
+	return nil
+}
+
+// observe records the changes of the given nodes and of the pools selecting
+// any of them. The first call only sets the baseline.
+func (m *RolloutMonitor) observe(now time.Time, nodes []corev1.Node, pools []mcfgv1.MachineConfigPool) {
+	for _, n := range nodes {
+		current := nodeRollout{unschedulable: n.Spec.Unschedulable, bootID: n.Status.NodeInfo.BootID}
+		previous, known := m.nodes[n.Name]
+		if !known {
+			m.nodes[n.Name] = current
+			if current.unschedulable {
+				m.record(now, NodeCordoned, n.Name, "already cordoned")
+			}
+			continue
+		}
+		current.changed = previous.changed
+		if current.unschedulable && !previous.unschedulable {
+			current.changed = true
+			m.record(now, NodeCordoned, n.Name, "")
+		}
+		if !current.unschedulable && previous.unschedulable {
+			current.changed = true
+			m.record(now, NodeUncordoned, n.Name, "")
+		}
+		if current.bootID != previous.bootID && previous.bootID != "" && current.bootID != "" {
+			m.record(now, NodeRebooted, n.Name, fmt.Sprintf("boot id %s", current.bootID))
+		}
+		m.nodes[n.Name] = current
+	}
+
+	for _, p := range pools {
+		if !selectsAny(p, nodes) {
+			continue
+		}
+		current := poolRollout{
+			updating: mcfgv1.IsMachineConfigPoolConditionTrue(p.Status.Conditions, mcfgv1.MachineConfigPoolUpdating),
+			degraded: mcfgv1.IsMachineConfigPoolConditionTrue(p.Status.Conditions, mcfgv1.MachineConfigPoolDegraded),
+		}
+		previous, known := m.pools[p.Name]
+		if !known {
+			m.pools[p.Name] = current
+			continue
+		}
+		current.changed = previous.changed
+		current.degradedSince = previous.degradedSince && current.degraded
+		if current.updating && !previous.updating {
+			current.changed = true
+			m.record(now, PoolUpdating, p.Name, fmt.Sprintf("%d/%d machines updated", p.Status.UpdatedMachineCount, p.Status.MachineCount))
+		}
+		if !current.updating && previous.updating {
+			current.changed = true
+			m.record(now, PoolUpdated, p.Name, "")
+		}
+		if current.degraded && !previous.degraded {
+			current.degradedSince = true
+			message := ""
+			if c := mcfgv1.GetMachineConfigPoolCondition(p.Status, mcfgv1.MachineConfigPoolDegraded); c != nil {
+				message = c.Message
+			}
+			m.record(now, PoolDegraded, p.Name, message)
+		}
+		m.pools[p.Name] = current
+	}
+}
+
+// selectsAny tells if the node selector of the pool matches one of the nodes.
+func selectsAny(pool mcfgv1.MachineConfigPool, nodes []corev1.Node) bool {
+	if pool.Spec.NodeSelector == nil {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(pool.Spec.NodeSelector)
+	if err != nil {
+		return false
+	}
+	for _, n := range nodes {
+		if selector.Matches(labels.Set(n.Labels)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *RolloutMonitor) record(now time.Time, kind RolloutEventKind, object, message string) {
+	event := RolloutEvent{Time: now, Kind: kind, Object: object, Message: message}
+	m.Timeline = append(m.Timeline, event)
+	if m.log != nil {
+		fmt.Fprintln(m.log, event)
+	}
+}
+
+// InProgress tells if a node was cordoned or a pool started updating since the
+// baseline, and has not recovered yet.
+func (m *RolloutMonitor) InProgress() bool {
+	for _, n := range m.nodes {
+		if n.changed && n.unschedulable {
+			return true
+		}
+	}
+	for _, p := range m.pools {
+		if p.changed && p.updating {
+			return true
+		}
+	}
+	return false
+}
+
+// Degraded returns an error naming the pools that went degraded since the
+// baseline, if any.
+func (m *RolloutMonitor) Degraded() error {
+	degraded := []string{}
+	for name, p := range m.pools {
+		if p.degradedSince {
+			degraded = append(degraded, name)
+		}
+	}
+	sort.Strings(degraded)
+	if len(degraded) == 0 {
+		return nil
+	}
+	return fmt.Errorf("Machine config pools degraded: %s", strings.Join(degraded, ", "))
+}
+
+// TimelineString returns the observed events, one per line.
+func (m *RolloutMonitor) TimelineString() string {
+	lines := []string{}
+	for _, e := range m.Timeline {
+		lines = append(lines, e.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WaitForSriovStable waits for the node states to be in sync. While the nodes are
+// being drained, rebooted or updated, the deadline is moved forward by timeout, up to
+// RolloutTimeout. It fails right away when the pool of an sriov node goes degraded.
+// The rollout events are written to log as they are observed.
+func WaitForSriovStable(operatorNamespace string, clients *testclient.ClientSet, timeout time.Duration, log io.Writer) error {
+	monitor := NewRolloutMonitor(clients, operatorNamespace, log)
+	start := time.Now()
+	deadline := start.Add(timeout)
+	for {
+		err := monitor.Observe()
+		if err != nil {
+			return err
+		}
+		if err := monitor.Degraded(); err != nil {
+			return fmt.Errorf("%v\n%s", err, monitor.TimelineString())
+		}
+		now := time.Now()
+		deadline = extendDeadline(deadline, now, start, timeout, monitor.InProgress())
+
+		stable, err := SriovStable(operatorNamespace, clients)
+		if err != nil {
+			return err
+		}
+		if stable && !monitor.InProgress() {
+			return nil
+		}
+		if now.After(deadline) {
+			return fmt.Errorf("Timed out waiting for the node states to be in sync after %v\n%s",
+				now.Sub(start).Round(time.Second), monitor.TimelineString())
+		}
+		time.Sleep(rolloutInterval)
+	}
+}
+
+// extendDeadline moves the deadline forward while a rollout is in progress,
+// without going past RolloutTimeout from the start.
+func extendDeadline(deadline, now, start time.Time, timeout time.Duration, inProgress bool) time.Time {
+	if !inProgress {
+		return deadline
+	}
+	extended := now.Add(timeout)
+	if max := start.Add(RolloutTimeout); extended.After(max) {
+		extended = max
+	}
+	if extended.After(deadline) {
+		return extended
+	}
+	return deadline
+}
@@ -0,0 +1,133 @@
+package cluster
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	mcfgv1 "github.com/openshift/machine-config-operator/pkg/apis/machineconfiguration.openshift.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func rolloutNode(name, bootID string, unschedulable bool) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"node-role.kubernetes.io/worker": ""}},
+		Spec:       corev1.NodeSpec{Unschedulable: unschedulable},
+		Status:     corev1.NodeStatus{NodeInfo: corev1.NodeSystemInfo{BootID: bootID}},
+	}
+}
+
+func rolloutPool(name string, updating, degraded corev1.ConditionStatus) mcfgv1.MachineConfigPool {
+	return mcfgv1.MachineConfigPool{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: mcfgv1.MachineConfigPoolSpec{
+			NodeSelector: metav1.AddLabelToSelector(&metav1.LabelSelector{}, "node-role.kubernetes.io/"+name, ""),
+		},
+		Status: mcfgv1.MachineConfigPoolStatus{
+			MachineCount:        3,
+			UpdatedMachineCount: 1,
+			Conditions: []mcfgv1.MachineConfigPoolCondition{
+				{Type: mcfgv1.MachineConfigPoolUpdating, Status: updating},
+				{Type: mcfgv1.MachineConfigPoolDegraded, Status: degraded, Message: "node worker-1 is reporting: unexpected on-disk state"},
+			},
+		},
+	}
+}
+
+func eventKinds(m *RolloutMonitor) []RolloutEventKind {
+	res := []RolloutEventKind{}
+	for _, e := range m.Timeline {
+		res = append(res, e.Kind)
+	}
+	return res
+}
+
+var _ = Describe("RolloutMonitor", func() {
+	var (
+		m   *RolloutMonitor
+		log *bytes.Buffer
+		now time.Time
+	)
+
+	BeforeEach(func() {
+		log = &bytes.Buffer{}
+		m = NewRolloutMonitor(nil, "sriov-operator", log)
+		now = time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	})
+
+	It("records drains and reboots", func() {
+		m.observe(now, []corev1.Node{rolloutNode("worker-0", "a", false)}, nil)
+		Expect(m.Timeline).To(BeEmpty())
+		Expect(m.InProgress()).To(BeFalse())
+
+		m.observe(now, []corev1.Node{rolloutNode("worker-0", "a", true)}, nil)
+		Expect(m.InProgress()).To(BeTrue())
+
+		m.observe(now, []corev1.Node{rolloutNode("worker-0", "b", true)}, nil)
+		m.observe(now, []corev1.Node{rolloutNode("worker-0", "b", false)}, nil)
+		Expect(m.InProgress()).To(BeFalse())
+
+		Expect(eventKinds(m)).To(Equal([]RolloutEventKind{NodeCordoned, NodeRebooted, NodeUncordoned}))
+		Expect(log.String()).To(ContainSubstring("10:00:00 Rebooted       worker-0: boot id b"))
+	})
+
+	It("ignores the nodes cordoned before the first observation", func() {
+		m.observe(now, []corev1.Node{rolloutNode("worker-0", "a", true)}, nil)
+		Expect(eventKinds(m)).To(Equal([]RolloutEventKind{NodeCordoned}))
+		Expect(m.InProgress()).To(BeFalse())
+
+		m.observe(now, []corev1.Node{rolloutNode("worker-0", "a", true)}, nil)
+		Expect(m.InProgress()).To(BeFalse())
+
+		m.observe(now, []corev1.Node{rolloutNode("worker-0", "a", false)}, nil)
+		m.observe(now, []corev1.Node{rolloutNode("worker-0", "a", true)}, nil)
+		Expect(m.InProgress()).To(BeTrue())
+	})
+
+	It("records the pool updates and reports the pools going degraded", func() {
+		nodes := []corev1.Node{rolloutNode("worker-0", "a", false)}
+		m.observe(now, nodes, []mcfgv1.MachineConfigPool{rolloutPool("worker", corev1.ConditionFalse, corev1.ConditionFalse)})
+		Expect(m.InProgress()).To(BeFalse())
+
+		m.observe(now, nodes, []mcfgv1.MachineConfigPool{rolloutPool("worker", corev1.ConditionTrue, corev1.ConditionFalse)})
+		Expect(m.InProgress()).To(BeTrue())
+		Expect(m.Degraded()).To(Succeed())
+
+		m.observe(now, nodes, []mcfgv1.MachineConfigPool{rolloutPool("worker", corev1.ConditionFalse, corev1.ConditionTrue)})
+		Expect(m.InProgress()).To(BeFalse())
+		Expect(m.Degraded()).To(MatchError("Machine config pools degraded: worker"))
+
+		Expect(eventKinds(m)).To(Equal([]RolloutEventKind{PoolUpdating, PoolUpdated, PoolDegraded}))
+		Expect(m.Timeline[0].Message).To(Equal("1/3 machines updated"))
+		Expect(m.TimelineString()).To(ContainSubstring("unexpected on-disk state"))
+	})
+
+	It("ignores the pools already updating or degraded and the pools of other nodes", func() {
+		nodes := []corev1.Node{rolloutNode("worker-0", "a", false)}
+		m.observe(now, nodes, []mcfgv1.MachineConfigPool{
+			rolloutPool("worker", corev1.ConditionTrue, corev1.ConditionTrue),
+			rolloutPool("infra", corev1.ConditionFalse, corev1.ConditionFalse),
+		})
+		m.observe(now, nodes, []mcfgv1.MachineConfigPool{
+			rolloutPool("worker", corev1.ConditionTrue, corev1.ConditionTrue),
+			rolloutPool("infra", corev1.ConditionTrue, corev1.ConditionTrue),
+		})
+		Expect(m.InProgress()).To(BeFalse())
+		Expect(m.Degraded()).To(Succeed())
+		Expect(m.Timeline).To(BeEmpty())
+	})
+
+	It("extends the deadline only while in progress, up to the rollout timeout", func() {
+		start := now
+		deadline := start.Add(5 * time.Minute)
+		later := start.Add(4 * time.Minute)
+		Expect(extendDeadline(deadline, later, start, 5*time.Minute, false)).To(Equal(deadline))
+		Expect(extendDeadline(deadline, later, start, 5*time.Minute, true)).To(Equal(later.Add(5 * time.Minute)))
+
+		muchLater := start.Add(RolloutTimeout - time.Minute)
+		Expect(extendDeadline(deadline, muchLater, start, 5*time.Minute, true)).To(Equal(start.Add(RolloutTimeout)))
+	})
+})
@@ -0,0 +1,229 @@
+package cluster
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+	"github.com/openshift/sriov-tests/pkg/util/nics"
+)
+
+// ConnectivityEnv is the environment variable pointing to the file
+// describing how the PFs are cabled.
+const ConnectivityEnv = "SRIOV_CONNECTIVITY"
+
+// NICModel identifies PFs expected to behave the same way.
+type NICModel struct {
+	Vendor    string
+	DeviceID  string
+	LinkSpeed string
+}
+
+// PF is a physical function of a node.
+type PF struct {
+	Node      string
+	Interface sriovv1.InterfaceExt
+}
+
+// Model returns the nic model of the PF.
+func (p PF) Model() NICModel {
+	return NICModel{Vendor: p.Interface.Vendor, DeviceID: p.Interface.DeviceID, LinkSpeed: p.Interface.LinkSpeed}
+}
+
+func (p PF) String() string {
+	return p.Node + "/" + p.Interface.Name
+}
+
+// PFPair are two PFs of the same model on different nodes.
+type PFPair struct {
+	A, B PF
+	// Networks are the physical networks both PFs are connected to,
+	// according to the connectivity map.
+	Networks []string
+}
+
+// Connected tells if the PFs are known to reach each other.
+func (p PFPair) Connected() bool {
+	return len(p.Networks) > 0
+}
+
+// Connectivity is the user declared map of the physical networks the PFs are cabled to.
+type Connectivity struct {
+	Networks []PhysicalNetwork `json:"networks"`
+}
+
+// PhysicalNetwork is a set of PFs reaching each other, i.e. through the same switch and vlan.
+type PhysicalNetwork struct {
+	Name    string          `json:"name"`
+	Vlan    int             `json:"vlan,omitempty"`
+	Members []NetworkMember `json:"members"`
+}
+
+// NetworkMember is a PF connected to a physical network.
+type NetworkMember struct {
+	Node      string `json:"node"`
+	Interface string `json:"interface"`
+}
+
+// Topology describes the SR-IOV capable PFs of the cluster and how they relate.
+type Topology struct {
+	Nodes []string
+	// PFs holds the supported PFs of each node.
+	PFs          map[string][]PF
+	Connectivity *Connectivity
+}
+
+// ParseConnectivity decodes a yaml connectivity map.
+func ParseConnectivity(data []byte) (*Connectivity, error) {
+	res := &Connectivity{}
+	err := yaml.UnmarshalStrict(data, res)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range res.Networks {
+		if n.Name == "" {
+			return nil, fmt.Errorf("Physical network without name")
+		}
+	}
+	return res, nil
+}
+
+// BuildTopology builds the topology out of the node states, keeping the PFs
+// in the catalog. The connectivity map is optional.
+func BuildTopology(states []sriovv1.SriovNetworkNodeState, catalog *nics.Catalog, connectivity *Connectivity) *Topology {
+	res := &Topology{
+		Nodes:        []string{},
+		PFs:          map[string][]PF{},
+		Connectivity: connectivity,
+	}
+	for _, s := range states {
+		pfs := []PF{}
+		for _, itf := range s.Status.Interfaces {
+			if catalog.IsSupported(itf) {
+				pfs = append(pfs, PF{Node: s.Name, Interface: itf})
+			}
+		}
+		if len(pfs) == 0 {
+			continue
+		}
+		res.Nodes = append(res.Nodes, s.Name)
+		res.PFs[s.Name] = pfs
+	}
+	sort.Strings(res.Nodes)
+	return res
+}
+
+// DiscoverTopology builds the topology of the cluster. The connectivity map is
+// read from the file pointed by SRIOV_CONNECTIVITY, when set.
+func DiscoverTopology(clients *testclient.ClientSet, operatorNamespace string) (*Topology, error) {
+	catalog, err := nics.Load()
+	if err != nil {
+		return nil, err
+	}
+	var connectivity *Connectivity
+	if path := os.Getenv(ConnectivityEnv); path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read connectivity map %s %v", path, err)
+		}
+		connectivity, err = ParseConnectivity(data)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse connectivity map %s %v", path, err)
+		}
+	}
+
+	nodeStates, err := clients.SriovNetworkNodeStates(operatorNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve node states %v", err)
+	}
+	return BuildTopology(nodeStates.Items, catalog, connectivity), nil
+}
+
+// Groups returns the PFs of the node grouped by nic model.
+func (t *Topology) Groups(node string) map[NICModel][]PF {
+	res := map[NICModel][]PF{}
+	for _, pf := range t.PFs[node] {
+		res[pf.Model()] = append(res[pf.Model()], pf)
+	}
+	return res
+}
+
+// CrossNodePairs returns the pairs of PFs of the same model on different nodes.
+// The connected pairs come first.
+func (t *Topology) CrossNodePairs() []PFPair {
+	res := []PFPair{}
+	for i, nodeA := range t.Nodes {
+		for _, nodeB := range t.Nodes[i+1:] {
+			for _, a := range t.PFs[nodeA] {
+				for _, b := range t.PFs[nodeB] {
+					if a.Model() != b.Model() {
+						continue
+					}
+					res = append(res, PFPair{A: a, B: b, Networks: t.sharedNetworks(a, b)})
+				}
+			}
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Connected() && !res[j].Connected()
+	})
+	return res
+}
+
+// ConnectedPair returns a pair of PFs on different nodes connected to the given
+// physical network, or to any network when empty.
+func (t *Topology) ConnectedPair(network string) (*PFPair, error) {
+	for _, p := range t.CrossNodePairs() {
+		for _, n := range p.Networks {
+			if network == "" || n == network {
+				pair := p
+				return &pair, nil
+			}
+		}
+	}
+	if t.Connectivity == nil {
+		return nil, fmt.Errorf("No connectivity map provided, set %s", ConnectivityEnv)
+	}
+	return nil, fmt.Errorf("No connected pair of PFs found on different nodes")
+}
+
+func (t *Topology) sharedNetworks(a, b PF) []string {
+	if t.Connectivity == nil {
+		return nil
+	}
+	res := []string{}
+	for _, n := range t.Connectivity.Networks {
+		if n.hasMember(a) && n.hasMember(b) {
+			res = append(res, n.Name)
+		}
+	}
+	return res
+}
+
+func (n PhysicalNetwork) hasMember(pf PF) bool {
+	for _, m := range n.Members {
+		if m.Node == pf.Node && m.Interface == pf.Interface.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// Topology returns the topology of the enabled nodes, without connectivity map.
+func (n *EnabledNodes) Topology() *Topology {
+	states := []sriovv1.SriovNetworkNodeState{}
+	for _, node := range n.Nodes {
+		states = append(states, n.States[node])
+	}
+	catalog := n.Catalog
+	if catalog == nil {
+		catalog = nics.Default()
+	}
+	return BuildTopology(states, catalog, nil)
+}
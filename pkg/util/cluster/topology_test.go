@@ -0,0 +1,117 @@
+package cluster
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/sriov-tests/pkg/util/nics"
+)
+
+const testConnectivity = `
+networks:
+- name: switch-a
+  vlan: 100
+  members:
+  - node: worker-0
+    interface: ens1f1
+  - node: worker-2
+    interface: ens1f1
+`
+
+func topologyStates() []sriovv1.SriovNetworkNodeState {
+	nodes := testNodes()
+	return []sriovv1.SriovNetworkNodeState{
+		nodes.States["worker-0"],
+		nodes.States["worker-1"],
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-2"},
+			Status: sriovv1.SriovNetworkNodeStateStatus{
+				Interfaces: sriovv1.InterfaceExts{
+					testInterface("ens1f0", "0000:3b:00.0", "mlx5_core", 8, "25000 Mb/s"),
+					testInterface("ens1f1", "0000:3b:00.1", "mlx5_core", 8, "25000 Mb/s"),
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "master-0"},
+			Status: sriovv1.SriovNetworkNodeStateStatus{
+				Interfaces: sriovv1.InterfaceExts{
+					testInterface("eno1", "0000:19:00.0", "tg3", 0, "1000 Mb/s"),
+				},
+			},
+		},
+	}
+}
+
+func pairNames(pairs []PFPair) []string {
+	res := []string{}
+	for _, p := range pairs {
+		res = append(res, p.A.String()+"-"+p.B.String())
+	}
+	return res
+}
+
+var _ = Describe("Topology", func() {
+	It("keeps the nodes with supported PFs", func() {
+		t := BuildTopology(topologyStates(), nics.Default(), nil)
+		Expect(t.Nodes).To(Equal([]string{"worker-0", "worker-1", "worker-2"}))
+		Expect(t.PFs["worker-0"]).To(HaveLen(3))
+	})
+
+	It("groups the PFs by model", func() {
+		groups := BuildTopology(topologyStates(), nics.Default(), nil).Groups("worker-0")
+		Expect(groups).To(HaveLen(2))
+		mlx := NICModel{Vendor: "15b3", LinkSpeed: "25000 Mb/s"}
+		Expect(groups).To(HaveKey(mlx))
+		Expect(groups[mlx]).To(HaveLen(2))
+	})
+
+	It("pairs PFs of the same model on different nodes", func() {
+		pairs := BuildTopology(topologyStates(), nics.Default(), nil).CrossNodePairs()
+		Expect(pairNames(pairs)).To(ConsistOf(
+			"worker-0/ens1f0-worker-2/ens1f0",
+			"worker-0/ens1f0-worker-2/ens1f1",
+			"worker-0/ens1f1-worker-2/ens1f0",
+			"worker-0/ens1f1-worker-2/ens1f1",
+		))
+		for _, p := range pairs {
+			Expect(p.Connected()).To(BeFalse())
+		}
+	})
+
+	It("uses the connectivity map to find connected pairs", func() {
+		connectivity, err := ParseConnectivity([]byte(testConnectivity))
+		Expect(err).ToNot(HaveOccurred())
+		t := BuildTopology(topologyStates(), nics.Default(), connectivity)
+
+		pairs := t.CrossNodePairs()
+		Expect(pairs[0].Networks).To(Equal([]string{"switch-a"}))
+		Expect(pairs[1].Connected()).To(BeFalse())
+
+		pair, err := t.ConnectedPair("switch-a")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pairNames([]PFPair{*pair})).To(Equal([]string{"worker-0/ens1f1-worker-2/ens1f1"}))
+
+		_, err = t.ConnectedPair("switch-b")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("falls back to the default catalog for the enabled nodes", func() {
+		nodes := testNodes()
+		nodes.Catalog = nil
+		Expect(nodes.Topology().Nodes).To(Equal(BuildTopology(topologyStates()[:2], nics.Default(), nil).Nodes))
+	})
+
+	It("fails without a connectivity map", func() {
+		_, err := BuildTopology(topologyStates(), nics.Default(), nil).ConnectedPair("")
+		Expect(err).To(MatchError(ContainSubstring(ConnectivityEnv)))
+	})
+
+	It("rejects unknown fields in the connectivity map", func() {
+		_, err := ParseConnectivity([]byte("networks:\n- name: a\n  switch: b\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
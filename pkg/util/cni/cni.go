@@ -0,0 +1,135 @@
+// Package cni models the sriov cni configuration the operator renders in the
+// network attachment definitions, so it can be compared field by field.
+package cni
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	netattdefv1 "github.com/openshift/sriov-network-operator/pkg/apis/k8s/v1"
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+)
+
+// The values the operator always renders.
+const (
+	Version = "0.3.1"
+	Type    = "sriov"
+)
+
+// SriovConfig is the configuration of the sriov cni plugin.
+type SriovConfig struct {
+	CNIVersion   string          `json:"cniVersion"`
+	Name         string          `json:"name"`
+	Type         string          `json:"type"`
+	Vlan         int             `json:"vlan"`
+	VlanQoS      int             `json:"vlanQoS"`
+	SpoofChk     string          `json:"spoofchk,omitempty"`
+	Trust        string          `json:"trust,omitempty"`
+	LinkState    string          `json:"link_state,omitempty"`
+	MinTxRate    *int            `json:"min_tx_rate,omitempty"`
+	MaxTxRate    *int            `json:"max_tx_rate,omitempty"`
+	Capabilities map[string]bool `json:"capabilities,omitempty"`
+	IPAM         json.RawMessage `json:"ipam,omitempty"`
+}
+
+// FromSriovNetwork returns the configuration the operator is expected to render
+// for the network.
+func FromSriovNetwork(network *sriovv1.SriovNetwork) (*SriovConfig, error) {
+	spec := network.Spec
+	config := &SriovConfig{
+		CNIVersion: Version,
+		Name:       network.Name,
+		Type:       Type,
+		Vlan:       spec.Vlan,
+		VlanQoS:    spec.VlanQoS,
+		SpoofChk:   spec.SpoofChk,
+		Trust:      spec.Trust,
+		LinkState:  spec.LinkState,
+		MinTxRate:  spec.MinTxRate,
+		MaxTxRate:  spec.MaxTxRate,
+	}
+	if spec.Capabilities != "" {
+		if err := json.Unmarshal([]byte(spec.Capabilities), &config.Capabilities); err != nil {
+			return nil, fmt.Errorf("Failed to parse the capabilities of %s %v", network.Name, err)
+		}
+	}
+	if spec.IPAM != "" {
+		if !json.Valid([]byte(spec.IPAM)) {
+			return nil, fmt.Errorf("Failed to parse the ipam of %s: invalid json", network.Name)
+		}
+		config.IPAM = json.RawMessage(spec.IPAM)
+	}
+	return config, nil
+}
+
+// Namespace returns the namespace the network attachment definition of the
+// network is created in.
+func Namespace(network *sriovv1.SriovNetwork) string {
+	if network.Spec.NetworkNamespace != "" {
+		return network.Spec.NetworkNamespace
+	}
+	return network.Namespace
+}
+
+// Parse returns the configuration of the network attachment definition.
+func Parse(nad *netattdefv1.NetworkAttachmentDefinition) (*SriovConfig, error) {
+	config := &SriovConfig{}
+	if err := json.Unmarshal([]byte(nad.Spec.Config), config); err != nil {
+		return nil, fmt.Errorf("Failed to parse the config of %s/%s %v", nad.Namespace, nad.Name, err)
+	}
+	return config, nil
+}
+
+// Diff returns the fields of the actual configuration not matching the expected
+// one, empty when they match. The ipam is compared as parsed json.
+func Diff(expected, actual *SriovConfig) []string {
+	diff := []string{}
+	compare := func(field string, expected, actual interface{}) {
+		if !reflect.DeepEqual(expected, actual) {
+			diff = append(diff, fmt.Sprintf("%s: expected %s, got %s", field, format(expected), format(actual)))
+		}
+	}
+	compare("cniVersion", expected.CNIVersion, actual.CNIVersion)
+	compare("name", expected.Name, actual.Name)
+	compare("type", expected.Type, actual.Type)
+	compare("vlan", expected.Vlan, actual.Vlan)
+	compare("vlanQoS", expected.VlanQoS, actual.VlanQoS)
+	compare("spoofchk", expected.SpoofChk, actual.SpoofChk)
+	compare("trust", expected.Trust, actual.Trust)
+	compare("link_state", expected.LinkState, actual.LinkState)
+	compare("min_tx_rate", expected.MinTxRate, actual.MinTxRate)
+	compare("max_tx_rate", expected.MaxTxRate, actual.MaxTxRate)
+	compare("capabilities", expected.Capabilities, actual.Capabilities)
+	compare("ipam", parsed(expected.IPAM), parsed(actual.IPAM))
+	return diff
+}
+
+func parsed(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return string(raw)
+	}
+	return value
+}
+
+func format(value interface{}) string {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || (v.Kind() == reflect.Ptr || v.Kind() == reflect.Map) && v.IsNil() {
+		return "<unset>"
+	}
+	if v.Kind() == reflect.Ptr {
+		value = v.Elem().Interface()
+	}
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
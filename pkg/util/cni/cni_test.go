@@ -0,0 +1,73 @@
+package cni
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	netattdefv1 "github.com/openshift/sriov-network-operator/pkg/apis/k8s/v1"
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func network(spec sriovv1.SriovNetworkSpec) *sriovv1.SriovNetwork {
+	return &sriovv1.SriovNetwork{ObjectMeta: metav1.ObjectMeta{Name: "test-net", Namespace: "sriov"}, Spec: spec}
+}
+
+func nad(config string) *netattdefv1.NetworkAttachmentDefinition {
+	return &netattdefv1.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-net", Namespace: "sriov"},
+		Spec:       netattdefv1.NetworkAttachmentDefinitionSpec{Config: config},
+	}
+}
+
+func rate(r int) *int {
+	return &r
+}
+
+var _ = Describe("SriovConfig", func() {
+	DescribeTable("matches the rendered config regardless of the formatting",
+		func(spec sriovv1.SriovNetworkSpec, rendered string) {
+			expected, err := FromSriovNetwork(network(spec))
+			Expect(err).ToNot(HaveOccurred())
+			actual, err := Parse(nad(rendered))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(Diff(expected, actual)).To(BeEmpty())
+		},
+		Entry("with vlan", sriovv1.SriovNetworkSpec{Vlan: 100, IPAM: `{"type":"host-local","subnet":"10.56.217.0/24"}`},
+			`{ "cniVersion":"0.3.1", "name":"test-net", "type":"sriov", "vlan":100,"vlanQoS":0,"ipam":{"type":"host-local","subnet":"10.56.217.0/24"} }`),
+		Entry("with reordered fields and ipam keys", sriovv1.SriovNetworkSpec{SpoofChk: "on", Trust: "off", LinkState: "auto", IPAM: `{"type":"host-local","subnet":"10.56.217.0/24"}`},
+			`{"ipam": {"subnet": "10.56.217.0/24", "type": "host-local"},
+			  "type": "sriov", "name": "test-net", "cniVersion": "0.3.1",
+			  "link_state": "auto", "trust": "off", "spoofchk": "on", "vlan": 0, "vlanQoS": 0}`),
+		Entry("with rates and capabilities", sriovv1.SriovNetworkSpec{MinTxRate: rate(10), MaxTxRate: rate(100), Capabilities: `{"mac": true}`, IPAM: `{"type":"dhcp"}`},
+			`{"cniVersion":"0.3.1","name":"test-net","type":"sriov","vlan":0,"min_tx_rate":10,"max_tx_rate":100,"capabilities":{"mac":true},"vlanQoS":0,"ipam":{"type":"dhcp"}}`),
+	)
+
+	It("reports the mismatching fields", func() {
+		expected, err := FromSriovNetwork(network(sriovv1.SriovNetworkSpec{Vlan: 100, MaxTxRate: rate(100), IPAM: `{"type":"dhcp"}`}))
+		Expect(err).ToNot(HaveOccurred())
+		actual, err := Parse(nad(`{"cniVersion":"0.3.1","name":"sriov-net","type":"sriov","vlan":200,"vlanQoS":0,"ipam":{"type":"static"}}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(Diff(expected, actual)).To(ConsistOf(
+			`name: expected "test-net", got "sriov-net"`,
+			`vlan: expected 100, got 200`,
+			`max_tx_rate: expected 100, got <unset>`,
+			`ipam: expected {"type":"dhcp"}, got {"type":"static"}`,
+		))
+	})
+
+	It("rejects invalid specs and configs", func() {
+		_, err := FromSriovNetwork(network(sriovv1.SriovNetworkSpec{IPAM: `{"type":`}))
+		Expect(err).To(HaveOccurred())
+		_, err = FromSriovNetwork(network(sriovv1.SriovNetworkSpec{Capabilities: `mac`}))
+		Expect(err).To(HaveOccurred())
+		_, err = Parse(nad(`{"cniVersion":`))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("uses the network namespace when set", func() {
+		Expect(Namespace(network(sriovv1.SriovNetworkSpec{}))).To(Equal("sriov"))
+		Expect(Namespace(network(sriovv1.SriovNetworkSpec{NetworkNamespace: "default"}))).To(Equal("default"))
+	})
+})
@@ -0,0 +1,287 @@
+// Package deviceplugin validates the device plugin config rendered by the operator
+// against the node policies.
+package deviceplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ConfigMapName is the config map the operator renders the device plugin config in.
+const ConfigMapName = "device-plugin-config"
+
+// ClusterKey is the key of the config map holding the config shared by all the nodes.
+// Any other key holds the config of the node with the same name.
+const ClusterKey = "config.json"
+
+// Selectors are the device selectors of a resource. Unlike the vendored device
+// plugin types they include the root devices.
+type Selectors struct {
+	Vendors     []string `json:"vendors,omitempty"`
+	Devices     []string `json:"devices,omitempty"`
+	Drivers     []string `json:"drivers,omitempty"`
+	PfNames     []string `json:"pfNames,omitempty"`
+	RootDevices []string `json:"rootDevices,omitempty"`
+	LinkTypes   []string `json:"linkTypes,omitempty"`
+}
+
+// ResourceConfig is a resource exposed by the device plugin.
+type ResourceConfig struct {
+	ResourceName string    `json:"resourceName"`
+	IsRdma       bool      `json:"isRdma,omitempty"`
+	Selectors    Selectors `json:"selectors,omitempty"`
+}
+
+// Config is the config of the device plugin.
+type Config struct {
+	ResourceList []ResourceConfig `json:"resourceList"`
+}
+
+// Resource returns the resource with the given name, nil if not found.
+func (c *Config) Resource(name string) *ResourceConfig {
+	for i := range c.ResourceList {
+		if c.ResourceList[i].ResourceName == name {
+			return &c.ResourceList[i]
+		}
+	}
+	return nil
+}
+
+// Parse parses the config.
+func Parse(raw string) (*Config, error) {
+	config := &Config{}
+	if err := json.Unmarshal([]byte(raw), config); err != nil {
+		return nil, fmt.Errorf("Failed to parse the device plugin config %v", err)
+	}
+	return config, nil
+}
+
+// ParseConfigMap returns the configs of the config map by key.
+func ParseConfigMap(cm *corev1.ConfigMap) (map[string]*Config, error) {
+	configs := map[string]*Config{}
+	for key, raw := range cm.Data {
+		config, err := Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse %s of %s %v", key, cm.Name, err)
+		}
+		configs[key] = config
+	}
+	return configs, nil
+}
+
+// ResourceDiff is what differs between a rendered resource and the policies
+// requesting it.
+type ResourceDiff struct {
+	// Key is the config map key of the config, i.e. the node name.
+	Key          string
+	ResourceName string
+	Problems     []string
+}
+
+func (d ResourceDiff) String() string {
+	return fmt.Sprintf("%s/%s: %s", d.Key, d.ResourceName, strings.Join(d.Problems, ", "))
+}
+
+// Validate returns the differences between the config and the resources requested
+// by the policies, empty when they match. The policies sharing a resource name are
+// merged, as the operator does.
+func Validate(policies []*sriovv1.SriovNetworkNodePolicy, config *Config) []ResourceDiff {
+	return validate(ClusterKey, policies, config)
+}
+
+// ValidateConfigMap validates each config of the config map. The per node configs
+// are validated against the policies selecting the node.
+func ValidateConfigMap(policies []*sriovv1.SriovNetworkNodePolicy, cm *corev1.ConfigMap, nodes []corev1.Node) ([]ResourceDiff, error) {
+	configs, err := ParseConfigMap(cm)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for key := range configs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	res := []ResourceDiff{}
+	for _, key := range keys {
+		if key == ClusterKey {
+			res = append(res, validate(key, policies, configs[key])...)
+			continue
+		}
+		node := findNode(nodes, key)
+		if node == nil {
+			res = append(res, ResourceDiff{Key: key, Problems: []string{"no such node"}})
+			continue
+		}
+		res = append(res, validate(key, SelectingNode(policies, node), configs[key])...)
+	}
+	return res, nil
+}
+
+// SelectingNode returns the policies whose node selector matches the node.
+func SelectingNode(policies []*sriovv1.SriovNetworkNodePolicy, node *corev1.Node) []*sriovv1.SriovNetworkNodePolicy {
+	res := []*sriovv1.SriovNetworkNodePolicy{}
+	for _, p := range policies {
+		if labels.SelectorFromSet(p.Spec.NodeSelector).Matches(labels.Set(node.Labels)) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func findNode(nodes []corev1.Node, name string) *corev1.Node {
+	for i := range nodes {
+		if nodes[i].Name == name {
+			return &nodes[i]
+		}
+	}
+	return nil
+}
+
+func validate(key string, policies []*sriovv1.SriovNetworkNodePolicy, config *Config) []ResourceDiff {
+	expected := map[string][]*sriovv1.SriovNetworkNodePolicy{}
+	names := []string{}
+	for _, p := range policies {
+		if _, ok := expected[p.Spec.ResourceName]; !ok {
+			names = append(names, p.Spec.ResourceName)
+		}
+		expected[p.Spec.ResourceName] = append(expected[p.Spec.ResourceName], p)
+	}
+	sort.Strings(names)
+
+	res := []ResourceDiff{}
+	for _, name := range names {
+		var problems []string
+		rc := config.Resource(name)
+		if rc == nil {
+			problems = []string{"missing"}
+		} else {
+			problems = compareResource(expected[name], rc)
+		}
+		if len(problems) > 0 {
+			res = append(res, ResourceDiff{Key: key, ResourceName: name, Problems: problems})
+		}
+	}
+	for _, rc := range config.ResourceList {
+		if _, ok := expected[rc.ResourceName]; !ok {
+			res = append(res, ResourceDiff{Key: key, ResourceName: rc.ResourceName, Problems: []string{"not requested by any policy"}})
+		}
+	}
+	return res
+}
+
+func compareResource(policies []*sriovv1.SriovNetworkNodePolicy, rc *ResourceConfig) []string {
+	var vendors, devices, rootDevices, pfNames []string
+	isRdma := false
+	vfio := false
+	problems := []string{}
+	for _, p := range policies {
+		selector := p.Spec.NicSelector
+		if selector.Vendor != "" {
+			vendors = append(vendors, selector.Vendor)
+		}
+		if selector.DeviceID != "" {
+			devices = append(devices, selector.DeviceID)
+		}
+		rootDevices = append(rootDevices, selector.RootDevices...)
+		for _, pf := range selector.PfNames {
+			normalized, err := normalizePfName(pf)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("policy %s: %v", p.Name, err))
+				continue
+			}
+			pfNames = append(pfNames, normalized)
+		}
+		isRdma = isRdma || p.Spec.IsRdma
+		vfio = vfio || p.Spec.DeviceType == "vfio-pci"
+	}
+
+	actualPfNames := []string{}
+	for _, pf := range rc.Selectors.PfNames {
+		normalized, err := normalizePfName(pf)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("pfNames: %v", err))
+			continue
+		}
+		actualPfNames = append(actualPfNames, normalized)
+	}
+
+	if rc.IsRdma != isRdma {
+		problems = append(problems, fmt.Sprintf("isRdma: expected %t, got %t", isRdma, rc.IsRdma))
+	}
+	problems = append(problems, compareSets("vendors", vendors, rc.Selectors.Vendors)...)
+	problems = append(problems, compareSets("devices", devices, rc.Selectors.Devices)...)
+	problems = append(problems, compareSets("rootDevices", rootDevices, rc.Selectors.RootDevices)...)
+	problems = append(problems, compareSets("pfNames", pfNames, actualPfNames)...)
+
+	hasVfio := contains(rc.Selectors.Drivers, "vfio-pci")
+	if vfio && (!hasVfio || len(rc.Selectors.Drivers) != 1) {
+		problems = append(problems, fmt.Sprintf("drivers: expected [vfio-pci], got %v", rc.Selectors.Drivers))
+	}
+	if !vfio && hasVfio {
+		problems = append(problems, fmt.Sprintf("drivers: expected netdevice drivers, got %v", rc.Selectors.Drivers))
+	}
+	return problems
+}
+
+// normalizePfName validates the vf range of a pf name, i.e. ens1f0#0-3, and
+// returns it without the padding.
+func normalizePfName(pf string) (string, error) {
+	idx := strings.Index(pf, "#")
+	if idx < 0 {
+		return pf, nil
+	}
+	name := pf[:idx]
+	bounds := strings.Split(pf[idx+1:], "-")
+	if name == "" || len(bounds) != 2 {
+		return "", fmt.Errorf("invalid pf range %q", pf)
+	}
+	first, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return "", fmt.Errorf("invalid pf range %q", pf)
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if err != nil || first < 0 || first > last {
+		return "", fmt.Errorf("invalid pf range %q", pf)
+	}
+	return fmt.Sprintf("%s#%d-%d", name, first, last), nil
+}
+
+// compareSets reports the values missing from and unexpected in actual.
+func compareSets(field string, expected, actual []string) []string {
+	var missing, unexpected []string
+	for _, e := range expected {
+		if !contains(actual, e) && !contains(missing, e) {
+			missing = append(missing, e)
+		}
+	}
+	for _, a := range actual {
+		if !contains(expected, a) && !contains(unexpected, a) {
+			unexpected = append(unexpected, a)
+		}
+	}
+	res := []string{}
+	if len(missing) > 0 {
+		res = append(res, fmt.Sprintf("%s: missing %v", field, missing))
+	}
+	if len(unexpected) > 0 {
+		res = append(res, fmt.Sprintf("%s: unexpected %v", field, unexpected))
+	}
+	return res
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+package deviceplugin
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestDeviceplugin(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Deviceplugin Suite")
+}
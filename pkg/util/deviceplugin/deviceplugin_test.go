@@ -0,0 +1,125 @@
+package deviceplugin
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func policy(name string, spec sriovv1.SriovNetworkNodePolicySpec) *sriovv1.SriovNetworkNodePolicy {
+	return &sriovv1.SriovNetworkNodePolicy{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: spec}
+}
+
+var mlx = policy("mlx", sriovv1.SriovNetworkNodePolicySpec{
+	ResourceName: "resource_1",
+	NodeSelector: map[string]string{"sriov": "mlx"},
+	NicSelector: sriovv1.SriovNetworkNicSelector{
+		Vendor:   "15b3",
+		DeviceID: "1015",
+		PfNames:  []string{"ens1f0"},
+	},
+})
+
+var vfio = policy("vfio", sriovv1.SriovNetworkNodePolicySpec{
+	ResourceName: "resource_2",
+	DeviceType:   "vfio-pci",
+	NicSelector: sriovv1.SriovNetworkNicSelector{
+		RootDevices: []string{"0000:3b:00.1"},
+		PfNames:     []string{"ens2f1#0-3"},
+	},
+})
+
+var vfRange = policy("range", sriovv1.SriovNetworkNodePolicySpec{
+	ResourceName: "resource_2",
+	DeviceType:   "vfio-pci",
+	NicSelector: sriovv1.SriovNetworkNicSelector{
+		RootDevices: []string{"0000:3b:00.1"},
+		PfNames:     []string{"ens2f1#4-7"},
+	},
+})
+
+const (
+	mlxResource  = `{"resourceName":"resource_1","selectors":{"vendors":["15b3"],"devices":["1015"],"pfNames":["ens1f0"]}}`
+	vfioResource = `{"resourceName":"resource_2","selectors":{"drivers":["vfio-pci"],"rootDevices":["0000:3b:00.1"],"pfNames":["ens2f1#0-3"]}}`
+)
+
+var _ = Describe("Validate", func() {
+	DescribeTable("compares the config with the policies",
+		func(policies []*sriovv1.SriovNetworkNodePolicy, raw string, expected []ResourceDiff) {
+			config, err := Parse(raw)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(Validate(policies, config)).To(Equal(expected))
+		},
+		Entry("matching the policy", []*sriovv1.SriovNetworkNodePolicy{mlx},
+			`{"resourceList":[`+mlxResource+`]}`, []ResourceDiff{}),
+		Entry("matching several policies", []*sriovv1.SriovNetworkNodePolicy{mlx, vfio},
+			`{"resourceList":[`+vfioResource+`,`+mlxResource+`]}`, []ResourceDiff{}),
+		Entry("merging the policies of the same resource", []*sriovv1.SriovNetworkNodePolicy{vfio, vfRange},
+			`{"resourceList":[{"resourceName":"resource_2","selectors":{"drivers":["vfio-pci"],"rootDevices":["0000:3b:00.1"],"pfNames":["ens2f1#04-7","ens2f1#0-3"]}}]}`, []ResourceDiff{}),
+		Entry("with a different vendor", []*sriovv1.SriovNetworkNodePolicy{mlx},
+			`{"resourceList":[{"resourceName":"resource_1","selectors":{"vendors":["8086"],"devices":["1015"],"pfNames":["ens1f0"]}}]}`,
+			[]ResourceDiff{{Key: ClusterKey, ResourceName: "resource_1", Problems: []string{"vendors: missing [15b3]", "vendors: unexpected [8086]"}}}),
+		Entry("with rdma enabled", []*sriovv1.SriovNetworkNodePolicy{mlx},
+			`{"resourceList":[{"resourceName":"resource_1","isRdma":true,"selectors":{"vendors":["15b3"],"devices":["1015"],"pfNames":["ens1f0"]}}]}`,
+			[]ResourceDiff{{Key: ClusterKey, ResourceName: "resource_1", Problems: []string{"isRdma: expected false, got true"}}}),
+		Entry("with netdevice drivers for a vfio policy", []*sriovv1.SriovNetworkNodePolicy{vfio},
+			`{"resourceList":[{"resourceName":"resource_2","selectors":{"drivers":["iavf"],"rootDevices":["0000:3b:00.1"],"pfNames":["ens2f1#0-3"]}}]}`,
+			[]ResourceDiff{{Key: ClusterKey, ResourceName: "resource_2", Problems: []string{"drivers: expected [vfio-pci], got [iavf]"}}}),
+		Entry("with a different vf range and root device", []*sriovv1.SriovNetworkNodePolicy{vfio},
+			`{"resourceList":[{"resourceName":"resource_2","selectors":{"drivers":["vfio-pci"],"pfNames":["ens2f1#0-2"]}}]}`,
+			[]ResourceDiff{{Key: ClusterKey, ResourceName: "resource_2", Problems: []string{
+				"rootDevices: missing [0000:3b:00.1]", "pfNames: missing [ens2f1#0-3]", "pfNames: unexpected [ens2f1#0-2]"}}}),
+		Entry("with missing and extra resources", []*sriovv1.SriovNetworkNodePolicy{vfio},
+			`{"resourceList":[`+mlxResource+`]}`,
+			[]ResourceDiff{
+				{Key: ClusterKey, ResourceName: "resource_2", Problems: []string{"missing"}},
+				{Key: ClusterKey, ResourceName: "resource_1", Problems: []string{"not requested by any policy"}},
+			}),
+	)
+
+	It("rejects the invalid configs and ranges", func() {
+		_, err := Parse(`{`)
+		Expect(err).To(MatchError(ContainSubstring("unexpected end of JSON input")))
+
+		_, err = normalizePfName("ens1f0#3-1")
+		Expect(err).To(MatchError(`invalid pf range "ens1f0#3-1"`))
+		_, err = normalizePfName("#0-1")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ValidateConfigMap", func() {
+	nodes := []corev1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Labels: map[string]string{"sriov": "mlx"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}},
+	}
+
+	It("validates each node against the policies selecting it", func() {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName},
+			Data: map[string]string{
+				"worker-0": `{"resourceList":[` + mlxResource + `,` + vfioResource + `]}`,
+				"worker-1": `{"resourceList":[` + mlxResource + `]}`,
+				"worker-2": `{"resourceList":[]}`,
+			},
+		}
+		diff, err := ValidateConfigMap([]*sriovv1.SriovNetworkNodePolicy{mlx, vfio}, cm, nodes)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diff).To(Equal([]ResourceDiff{
+			{Key: "worker-1", ResourceName: "resource_2", Problems: []string{"missing"}},
+			{Key: "worker-1", ResourceName: "resource_1", Problems: []string{"not requested by any policy"}},
+			{Key: "worker-2", Problems: []string{"no such node"}},
+		}))
+		Expect(diff[0].String()).To(Equal("worker-1/resource_2: missing"))
+	})
+
+	It("fails on invalid configs", func() {
+		cm := &corev1.ConfigMap{Data: map[string]string{ClusterKey: "{"}}
+		_, err := ValidateConfigMap(nil, cm, nodes)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,176 @@
+package envtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kube-openapi/pkg/common"
+)
+
+// sriovTypesPackage is the prefix of the sriov types in the openapi definitions.
+const sriovTypesPackage = "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1."
+
+// crdNames describes a custom resource to be installed.
+type crdNames struct {
+	group      string
+	kind       string
+	plural     string
+	shortNames []string
+}
+
+var sriovCRDs = []crdNames{
+	{group: sriovv1.SchemeGroupVersion.Group, kind: "SriovNetwork", plural: "sriovnetworks"},
+	{group: sriovv1.SchemeGroupVersion.Group, kind: "SriovNetworkNodePolicy", plural: "sriovnetworknodepolicies"},
+	{group: sriovv1.SchemeGroupVersion.Group, kind: "SriovNetworkNodeState", plural: "sriovnetworknodestates"},
+	{group: sriovv1.SchemeGroupVersion.Group, kind: "SriovOperatorConfig", plural: "sriovoperatorconfigs"},
+}
+
+// CRDs returns the custom resource definitions of the sriov operator and of
+// the NetworkAttachmentDefinition. The schemas of the sriov resources are
+// generated from the openapi definitions of the vendored types.
+func CRDs() ([]*apiextv1beta1.CustomResourceDefinition, error) {
+	definitions := sriovv1.GetOpenAPIDefinitions(func(path string) spec.Ref {
+		return spec.MustCreateRef(path)
+	})
+
+	res := []*apiextv1beta1.CustomResourceDefinition{}
+	for _, names := range sriovCRDs {
+		schema, err := openAPISchema(definitions, sriovTypesPackage+names.kind)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to generate the schema for %s %v", names.kind, err)
+		}
+		res = append(res, newCRD(names, schema))
+	}
+
+	res = append(res, newCRD(crdNames{
+		group:      "k8s.cni.cncf.io",
+		kind:       "NetworkAttachmentDefinition",
+		plural:     "network-attachment-definitions",
+		shortNames: []string{"net-attach-def"},
+	}, &apiextv1beta1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextv1beta1.JSONSchemaProps{
+			"spec": {
+				Type: "object",
+				Properties: map[string]apiextv1beta1.JSONSchemaProps{
+					"config": {Type: "string"},
+				},
+			},
+		},
+	}))
+	return res, nil
+}
+
+func newCRD(names crdNames, schema *apiextv1beta1.JSONSchemaProps) *apiextv1beta1.CustomResourceDefinition {
+	return &apiextv1beta1.CustomResourceDefinition{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: apiextv1beta1.SchemeGroupVersion.String(),
+			Kind:       "CustomResourceDefinition",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: names.plural + "." + names.group,
+		},
+		Spec: apiextv1beta1.CustomResourceDefinitionSpec{
+			Group: names.group,
+			Names: apiextv1beta1.CustomResourceDefinitionNames{
+				Kind:       names.kind,
+				ListKind:   names.kind + "List",
+				Plural:     names.plural,
+				Singular:   strings.ToLower(names.kind),
+				ShortNames: names.shortNames,
+			},
+			Scope: apiextv1beta1.NamespaceScoped,
+			Versions: []apiextv1beta1.CustomResourceDefinitionVersion{
+				{Name: "v1", Served: true, Storage: true},
+			},
+			Subresources: &apiextv1beta1.CustomResourceSubresources{
+				Status: &apiextv1beta1.CustomResourceSubresourceStatus{},
+			},
+			Validation: &apiextv1beta1.CustomResourceValidation{
+				OpenAPIV3Schema: schema,
+			},
+		},
+	}
+}
+
+// openAPISchema returns the schema of the given type, with the references
+// to the other sriov types inlined. References to types without a definition
+// (i.e. ObjectMeta) are replaced with a generic object.
+func openAPISchema(definitions map[string]common.OpenAPIDefinition, name string) (*apiextv1beta1.JSONSchemaProps, error) {
+	inlined, err := inlineDefinition(definitions, name)
+	if err != nil {
+		return nil, err
+	}
+	obj := inlined.(map[string]interface{})
+	// kind, apiVersion and metadata are validated by the apiserver
+	if props, ok := obj["properties"].(map[string]interface{}); ok {
+		props["metadata"] = map[string]interface{}{"type": "object"}
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	res := &apiextv1beta1.JSONSchemaProps{}
+	err = json.Unmarshal(data, res)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func inlineDefinition(definitions map[string]common.OpenAPIDefinition, name string) (interface{}, error) {
+	def, ok := definitions[name]
+	if !ok {
+		return map[string]interface{}{"type": "object"}, nil
+	}
+	data, err := json.Marshal(def.Schema)
+	if err != nil {
+		return nil, err
+	}
+	var schema interface{}
+	err = json.Unmarshal(data, &schema)
+	if err != nil {
+		return nil, err
+	}
+	return inlineRefs(definitions, schema)
+}
+
+func inlineRefs(definitions map[string]common.OpenAPIDefinition, schema interface{}) (interface{}, error) {
+	switch s := schema.(type) {
+	case map[string]interface{}:
+		if ref, ok := s["$ref"].(string); ok {
+			inlined, err := inlineDefinition(definitions, ref)
+			if err != nil {
+				return nil, err
+			}
+			if description, ok := s["description"]; ok {
+				inlined.(map[string]interface{})["description"] = description
+			}
+			return inlined, nil
+		}
+		for k, v := range s {
+			inlined, err := inlineRefs(definitions, v)
+			if err != nil {
+				return nil, err
+			}
+			s[k] = inlined
+		}
+		return s, nil
+	case []interface{}:
+		for i, v := range s {
+			inlined, err := inlineRefs(definitions, v)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = inlined
+		}
+		return s, nil
+	}
+	return schema, nil
+}
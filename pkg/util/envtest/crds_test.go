@@ -0,0 +1,73 @@
+package envtest
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/go-openapi/spec"
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/kube-openapi/pkg/common"
+)
+
+var _ = Describe("CRDs", func() {
+	var crds map[string]*apiextv1beta1.CustomResourceDefinition
+
+	BeforeEach(func() {
+		res, err := CRDs()
+		Expect(err).ToNot(HaveOccurred())
+		crds = map[string]*apiextv1beta1.CustomResourceDefinition{}
+		for _, crd := range res {
+			crds[crd.Name] = crd
+		}
+	})
+
+	It("should define all the resources used by the tests", func() {
+		Expect(crds).To(HaveLen(5))
+		Expect(crds).To(HaveKey("sriovnetworks.sriovnetwork.openshift.io"))
+		Expect(crds).To(HaveKey("sriovnetworknodepolicies.sriovnetwork.openshift.io"))
+		Expect(crds).To(HaveKey("sriovnetworknodestates.sriovnetwork.openshift.io"))
+		Expect(crds).To(HaveKey("sriovoperatorconfigs.sriovnetwork.openshift.io"))
+		Expect(crds).To(HaveKey("network-attachment-definitions.k8s.cni.cncf.io"))
+		Expect(crds["sriovnetworknodepolicies.sriovnetwork.openshift.io"].Spec.Names.Kind).To(Equal("SriovNetworkNodePolicy"))
+	})
+
+	It("should inline the referenced sriov types", func() {
+		schema := crds["sriovnetworknodepolicies.sriovnetwork.openshift.io"].Spec.Validation.OpenAPIV3Schema
+		spec := schema.Properties["spec"]
+		Expect(spec.Type).To(Equal("object"))
+		Expect(spec.Properties).To(HaveKey("numVfs"))
+		Expect(spec.Properties["numVfs"].Type).To(Equal("integer"))
+		Expect(spec.Required).To(ContainElement("resourceName"))
+		Expect(schema.Properties["metadata"].Type).To(Equal("object"))
+		Expect(schema.Properties["metadata"].Properties).To(BeEmpty())
+	})
+
+	It("should keep the node state interfaces as generic objects", func() {
+		// the vendored openapi definitions do not include the Interface type
+		schema := crds["sriovnetworknodestates.sriovnetwork.openshift.io"].Spec.Validation.OpenAPIV3Schema
+		interfaces := schema.Properties["spec"].Properties["interfaces"]
+		Expect(interfaces.Type).To(Equal("array"))
+		Expect(interfaces.Items.Schema.Type).To(Equal("object"))
+		Expect(interfaces.Items.Schema.Properties).To(BeEmpty())
+	})
+
+	It("should replace the unknown types with generic objects", func() {
+		definitions := map[string]common.OpenAPIDefinition{
+			"test.Known": {Schema: spec.Schema{SchemaProps: spec.SchemaProps{
+				Type: []string{"object"},
+				Properties: map[string]spec.Schema{
+					"unknown": {SchemaProps: spec.SchemaProps{
+						Description: "has no definition",
+						Ref:         spec.MustCreateRef("test.Unknown"),
+					}},
+				},
+			}}},
+		}
+		schema, err := openAPISchema(definitions, "test.Known")
+		Expect(err).ToNot(HaveOccurred())
+		unknown := schema.Properties["unknown"]
+		Expect(unknown.Type).To(Equal("object"))
+		Expect(unknown.Properties).To(BeEmpty())
+		Expect(unknown.Description).To(Equal("has no definition"))
+	})
+})
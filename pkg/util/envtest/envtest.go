@@ -0,0 +1,283 @@
+package envtest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AssetsEnv is the environment variable pointing to the directory
+	// containing the etcd and kube-apiserver binaries.
+	AssetsEnv = "KUBEBUILDER_ASSETS"
+
+	defaultAssetsDirectory = "/usr/local/kubebuilder/bin"
+	defaultStartTimeout    = time.Minute
+	defaultStopTimeout     = 20 * time.Second
+)
+
+// Environment runs a local etcd and kube-apiserver with the sriov related
+// custom resources installed, so the suites can run without a cluster.
+// Nothing but the apiserver runs: controllers like the operator must be
+// started separately against the written kubeconfig.
+type Environment struct {
+	// AssetsDirectory contains the etcd and kube-apiserver binaries.
+	// It defaults to $KUBEBUILDER_ASSETS or /usr/local/kubebuilder/bin.
+	AssetsDirectory string
+	// Namespaces are created once the apiserver is up.
+	Namespaces   []string
+	StartTimeout time.Duration
+	StopTimeout  time.Duration
+
+	// Config is the client config pointing to the running apiserver.
+	Config *rest.Config
+	// KubeconfigPath is the path of the kubeconfig written for the running apiserver.
+	KubeconfigPath string
+
+	dir       string
+	etcd      *exec.Cmd
+	apiServer *exec.Cmd
+}
+
+// Start runs etcd and the apiserver, installs the custom resource definitions,
+// creates the namespaces and writes the kubeconfig.
+func (e *Environment) Start() error {
+	if e.AssetsDirectory == "" {
+		e.AssetsDirectory = os.Getenv(AssetsEnv)
+	}
+	if e.AssetsDirectory == "" {
+		e.AssetsDirectory = defaultAssetsDirectory
+	}
+	if e.StartTimeout == 0 {
+		e.StartTimeout = defaultStartTimeout
+	}
+	if e.StopTimeout == 0 {
+		e.StopTimeout = defaultStopTimeout
+	}
+
+	var err error
+	e.dir, err = ioutil.TempDir("", "sriov-envtest")
+	if err != nil {
+		return err
+	}
+
+	ports, err := freePorts(4)
+	if err != nil {
+		return err
+	}
+	etcdURL := fmt.Sprintf("http://127.0.0.1:%d", ports[0])
+	apiServerURL := fmt.Sprintf("http://127.0.0.1:%d", ports[2])
+
+	e.etcd, err = e.run("etcd",
+		"--data-dir="+filepath.Join(e.dir, "etcd"),
+		"--listen-client-urls="+etcdURL,
+		"--advertise-client-urls="+etcdURL,
+		fmt.Sprintf("--listen-peer-urls=http://127.0.0.1:%d", ports[1]),
+	)
+	if err != nil {
+		e.Stop()
+		return err
+	}
+
+	e.apiServer, err = e.run("kube-apiserver",
+		"--advertise-address=127.0.0.1",
+		"--etcd-servers="+etcdURL,
+		"--cert-dir="+filepath.Join(e.dir, "certs"),
+		"--insecure-bind-address=127.0.0.1",
+		fmt.Sprintf("--insecure-port=%d", ports[2]),
+		fmt.Sprintf("--secure-port=%d", ports[3]),
+		"--disable-admission-plugins=ServiceAccount",
+		"--service-cluster-ip-range=10.0.0.0/24",
+		"--allow-privileged=true",
+	)
+	if err != nil {
+		e.Stop()
+		return err
+	}
+
+	err = waitHealthy(apiServerURL, e.StartTimeout)
+	if err != nil {
+		e.Stop()
+		return err
+	}
+
+	e.Config = &rest.Config{Host: apiServerURL}
+	err = e.install()
+	if err != nil {
+		e.Stop()
+		return err
+	}
+
+	e.KubeconfigPath = filepath.Join(e.dir, "kubeconfig")
+	err = writeKubeconfig(apiServerURL, e.KubeconfigPath)
+	if err != nil {
+		e.Stop()
+		return err
+	}
+	return nil
+}
+
+// Stop terminates the apiserver and etcd, and removes their data.
+func (e *Environment) Stop() error {
+	var err error
+	for _, cmd := range []*exec.Cmd{e.apiServer, e.etcd} {
+		stopErr := stop(cmd, e.StopTimeout)
+		if stopErr != nil && err == nil {
+			err = stopErr
+		}
+	}
+	if e.dir != "" {
+		os.RemoveAll(e.dir)
+	}
+	return err
+}
+
+func (e *Environment) run(binary string, args ...string) (*exec.Cmd, error) {
+	path := filepath.Join(e.AssetsDirectory, binary)
+	cmd := exec.Command(path, args...)
+	logFile, err := os.Create(filepath.Join(e.dir, binary+".log"))
+	if err != nil {
+		return nil, err
+	}
+	// the process writes to its own copy of the descriptor once started
+	defer logFile.Close()
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	glog.V(4).Infof("Starting %s %v", path, args)
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to start %s %v", path, err)
+	}
+	return cmd, nil
+}
+
+// install creates the custom resource definitions and the namespaces, waiting
+// for the definitions to be established.
+func (e *Environment) install() error {
+	scheme := runtime.NewScheme()
+	apiextv1beta1.AddToScheme(scheme)
+	corev1.AddToScheme(scheme)
+	client, err := runtimeclient.New(e.Config, runtimeclient.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	crds, err := CRDs()
+	if err != nil {
+		return err
+	}
+	for _, crd := range crds {
+		err = client.Create(context.Background(), crd)
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return fmt.Errorf("Failed to create crd %s %v", crd.Name, err)
+		}
+	}
+
+	for _, crd := range crds {
+		err = wait.PollImmediate(100*time.Millisecond, e.StartTimeout, func() (bool, error) {
+			found := &apiextv1beta1.CustomResourceDefinition{}
+			err := client.Get(context.Background(), runtimeclient.ObjectKey{Name: crd.Name}, found)
+			if err != nil {
+				return false, nil
+			}
+			for _, c := range found.Status.Conditions {
+				if c.Type == apiextv1beta1.Established && c.Status == apiextv1beta1.ConditionTrue {
+					return true, nil
+				}
+			}
+			return false, nil
+		})
+		if err != nil {
+			return fmt.Errorf("Crd %s not established %v", crd.Name, err)
+		}
+	}
+
+	for _, ns := range e.Namespaces {
+		err = client.Create(context.Background(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}})
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return fmt.Errorf("Failed to create namespace %s %v", ns, err)
+		}
+	}
+	return nil
+}
+
+func writeKubeconfig(host, path string) error {
+	config := clientcmdapi.NewConfig()
+	config.Clusters["envtest"] = &clientcmdapi.Cluster{Server: host}
+	config.AuthInfos["envtest"] = &clientcmdapi.AuthInfo{}
+	config.Contexts["envtest"] = &clientcmdapi.Context{Cluster: "envtest", AuthInfo: "envtest"}
+	config.CurrentContext = "envtest"
+	return clientcmd.WriteToFile(*config, path)
+}
+
+func waitHealthy(host string, timeout time.Duration) error {
+	err := wait.PollImmediate(100*time.Millisecond, timeout, func() (bool, error) {
+		res, err := http.Get(host + "/healthz")
+		if err != nil {
+			return false, nil
+		}
+		res.Body.Close()
+		return res.StatusCode == http.StatusOK, nil
+	})
+	if err != nil {
+		return fmt.Errorf("Apiserver %s not healthy %v", host, err)
+	}
+	return nil
+}
+
+func stop(cmd *exec.Cmd, timeout time.Duration) error {
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		return fmt.Errorf("Timeout waiting for %s to stop", cmd.Path)
+	}
+}
+
+// freePorts returns a set of ports that are free at the time of the call.
+func freePorts(n int) ([]int, error) {
+	res := []int{}
+	listeners := []net.Listener{}
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+		res = append(res, l.Addr().(*net.TCPAddr).Port)
+	}
+	return res, nil
+}
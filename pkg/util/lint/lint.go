@@ -0,0 +1,327 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Issue describes a problem found in a set of policies.
+type Issue struct {
+	// Policies are the names of the policies involved in the issue.
+	Policies []string
+	// Node is the node the issue was found on, empty when the
+	// issue does not depend on the node.
+	Node string
+	// Interface is the name of the PF the issue was found on, if any.
+	Interface string
+	Message   string
+}
+
+func (i Issue) String() string {
+	location := ""
+	if i.Node != "" {
+		location = fmt.Sprintf("node %s: ", i.Node)
+	}
+	if i.Interface != "" {
+		location = fmt.Sprintf("%spf %s: ", location, i.Interface)
+	}
+	return fmt.Sprintf("%s%s (policies %s)", location, i.Message, strings.Join(i.Policies, ", "))
+}
+
+// vfRange is the range of vfs a policy claims on a given PF.
+type vfRange struct {
+	policy *sriovv1.SriovNetworkNodePolicy
+	start  int
+	end    int
+}
+
+// target is a PF selected by a set of policies. When no node state is available
+// the node is left empty and the PF is identified only by its name.
+type target struct {
+	node  string
+	pf    string
+	iface *sriovv1.InterfaceExt
+}
+
+// Policies checks the given policies for conflicts. Nodes and node states are
+// optional: when provided, the policies are resolved against the interfaces of
+// each node the same way the operator does, otherwise the PF names in the nic
+// selectors are compared across the policies whose node selectors overlap, and
+// the other nic selectors are reported as unresolvable.
+func Policies(policies []sriovv1.SriovNetworkNodePolicy, nodes []corev1.Node, states []sriovv1.SriovNetworkNodeState) []Issue {
+	res := []Issue{}
+	valid := []*sriovv1.SriovNetworkNodePolicy{}
+	for i := range policies {
+		p := &policies[i]
+		if p.Name == "default" {
+			continue
+		}
+		issues := checkPfNames(p)
+		if len(issues) > 0 {
+			res = append(res, issues...)
+			continue
+		}
+		valid = append(valid, p)
+	}
+
+	res = append(res, checkResourceNames(valid)...)
+
+	var targets map[target][]*sriovv1.SriovNetworkNodePolicy
+	if len(states) > 0 {
+		targets = resolveOnNodes(valid, nodes, states)
+	} else {
+		var unresolved []Issue
+		targets, unresolved = resolveByName(valid)
+		res = append(res, unresolved...)
+	}
+
+	keys := make([]target, 0, len(targets))
+	for t := range targets {
+		keys = append(keys, t)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].node != keys[j].node {
+			return keys[i].node < keys[j].node
+		}
+		return keys[i].pf < keys[j].pf
+	})
+
+	for _, t := range keys {
+		res = append(res, checkTarget(t, targets[t])...)
+	}
+	return res
+}
+
+func checkPfNames(p *sriovv1.SriovNetworkNodePolicy) []Issue {
+	res := []Issue{}
+	for _, name := range p.Spec.NicSelector.PfNames {
+		// ParsePFName can't handle a range without separator
+		if strings.Contains(name, "#") && !strings.Contains(name, "-") {
+			res = append(res, invalidPfName(p, name))
+			continue
+		}
+		pf, start, end, err := sriovv1.ParsePFName(name)
+		if err != nil {
+			res = append(res, invalidPfName(p, name))
+			continue
+		}
+		if start > end {
+			res = append(res, Issue{
+				Policies:  []string{p.Name},
+				Interface: pf,
+				Message:   fmt.Sprintf("vf range %d-%d is reversed", start, end),
+			})
+		}
+	}
+	return res
+}
+
+func invalidPfName(p *sriovv1.SriovNetworkNodePolicy, name string) Issue {
+	return Issue{
+		Policies:  []string{p.Name},
+		Interface: strings.Split(name, "#")[0],
+		Message:   fmt.Sprintf("invalid pf name %q", name),
+	}
+}
+
+func checkResourceNames(policies []*sriovv1.SriovNetworkNodePolicy) []Issue {
+	byResource := map[string][]*sriovv1.SriovNetworkNodePolicy{}
+	resources := []string{}
+	for _, p := range policies {
+		if _, ok := byResource[p.Spec.ResourceName]; !ok {
+			resources = append(resources, p.Spec.ResourceName)
+		}
+		byResource[p.Spec.ResourceName] = append(byResource[p.Spec.ResourceName], p)
+	}
+	sort.Strings(resources)
+
+	res := []Issue{}
+	for _, resource := range resources {
+		deviceTypes := []string{}
+		names := []string{}
+		for _, p := range byResource[resource] {
+			deviceTypes = sriovv1.UniqueAppend(deviceTypes, deviceType(p))
+			names = append(names, p.Name)
+		}
+		if len(deviceTypes) > 1 {
+			res = append(res, Issue{
+				Policies: names,
+				Message:  fmt.Sprintf("resource %s is used with different device types %s", resource, strings.Join(deviceTypes, ", ")),
+			})
+		}
+	}
+	return res
+}
+
+func resolveOnNodes(policies []*sriovv1.SriovNetworkNodePolicy, nodes []corev1.Node, states []sriovv1.SriovNetworkNodeState) map[target][]*sriovv1.SriovNetworkNodePolicy {
+	res := map[target][]*sriovv1.SriovNetworkNodePolicy{}
+	nodesByName := map[string]*corev1.Node{}
+	for i := range nodes {
+		nodesByName[nodes[i].Name] = &nodes[i]
+	}
+
+	for i := range states {
+		state := &states[i]
+		node, ok := nodesByName[state.Name]
+		for j := range state.Status.Interfaces {
+			iface := &state.Status.Interfaces[j]
+			t := target{node: state.Name, pf: iface.Name, iface: iface}
+			for _, p := range policies {
+				// Without the node we can't evaluate the node selector, so we assume
+				// the policy applies.
+				if ok && !p.Selected(node) {
+					continue
+				}
+				if !selectsAny(&p.Spec.NicSelector) || !p.Spec.NicSelector.Selected(iface) {
+					continue
+				}
+				res[t] = append(res[t], p)
+			}
+		}
+	}
+	return res
+}
+
+// resolveByName groups the policies by the PF names of their nic selectors. The
+// other nic selectors need the interfaces of the nodes, they are reported as
+// unresolvable.
+func resolveByName(policies []*sriovv1.SriovNetworkNodePolicy) (map[target][]*sriovv1.SriovNetworkNodePolicy, []Issue) {
+	res := map[target][]*sriovv1.SriovNetworkNodePolicy{}
+	issues := []Issue{}
+	for _, p := range policies {
+		if fields := nodeDependentSelectors(&p.Spec.NicSelector); len(fields) > 0 {
+			issues = append(issues, Issue{
+				Policies: []string{p.Name},
+				Message:  fmt.Sprintf("nic selector %s unresolvable without node states", strings.Join(fields, ", ")),
+			})
+		}
+		for _, name := range p.Spec.NicSelector.PfNames {
+			pf, _, _, _ := sriovv1.ParsePFName(name)
+			t := target{pf: pf}
+			res[t] = append(res[t], p)
+		}
+	}
+	return res, issues
+}
+
+func checkTarget(t target, policies []*sriovv1.SriovNetworkNodePolicy) []Issue {
+	res := []Issue{}
+	ranges := make([]vfRange, 0, len(policies))
+	for _, p := range policies {
+		r := policyRange(p, t.pf)
+		ranges = append(ranges, r)
+
+		if r.end >= p.Spec.NumVfs {
+			res = append(res, Issue{
+				Policies:  []string{p.Name},
+				Node:      t.node,
+				Interface: t.pf,
+				Message:   fmt.Sprintf("vf range %d-%d exceeds numVfs %d", r.start, r.end, p.Spec.NumVfs),
+			})
+		}
+		if t.iface != nil && t.iface.TotalVfs > 0 && p.Spec.NumVfs > t.iface.TotalVfs {
+			res = append(res, Issue{
+				Policies:  []string{p.Name},
+				Node:      t.node,
+				Interface: t.pf,
+				Message:   fmt.Sprintf("numVfs %d exceeds the %d vfs supported by the pf", p.Spec.NumVfs, t.iface.TotalVfs),
+			})
+		}
+		if t.iface != nil && t.iface.TotalVfs > 0 && r.end >= t.iface.TotalVfs {
+			res = append(res, Issue{
+				Policies:  []string{p.Name},
+				Node:      t.node,
+				Interface: t.pf,
+				Message:   fmt.Sprintf("vf range %d-%d exceeds the %d vfs supported by the pf", r.start, r.end, t.iface.TotalVfs),
+			})
+		}
+	}
+
+	for i := 0; i < len(ranges); i++ {
+		for j := i + 1; j < len(ranges); j++ {
+			a, b := ranges[i], ranges[j]
+			// only resolved by name, the pf may be on nodes neither policy selects
+			if !nodeSelectorsOverlap(a.policy, b.policy) {
+				continue
+			}
+			names := []string{a.policy.Name, b.policy.Name}
+			if a.policy.Spec.NumVfs != b.policy.Spec.NumVfs {
+				res = append(res, Issue{
+					Policies:  names,
+					Node:      t.node,
+					Interface: t.pf,
+					Message:   fmt.Sprintf("numVfs mismatch %d != %d", a.policy.Spec.NumVfs, b.policy.Spec.NumVfs),
+				})
+			}
+			if a.start <= b.end && b.start <= a.end {
+				res = append(res, Issue{
+					Policies:  names,
+					Node:      t.node,
+					Interface: t.pf,
+					Message:   fmt.Sprintf("vf ranges %d-%d and %d-%d overlap", a.start, a.end, b.start, b.end),
+				})
+			}
+		}
+	}
+	return res
+}
+
+// policyRange returns the range of vfs the policy claims on the given PF,
+// following the same rules the operator uses when generating the vf groups.
+func policyRange(p *sriovv1.SriovNetworkNodePolicy, pf string) vfRange {
+	res := vfRange{policy: p, start: 0, end: p.Spec.NumVfs - 1}
+	for _, name := range p.Spec.NicSelector.PfNames {
+		pfName, start, end, err := sriovv1.ParsePFName(name)
+		if err != nil || pfName != pf {
+			continue
+		}
+		if start == 0 && end == 0 {
+			end = p.Spec.NumVfs - 1
+		}
+		res.start, res.end = start, end
+		break
+	}
+	return res
+}
+
+// nodeSelectorsOverlap tells if some node can be selected by both policies, that
+// is if no label is required with different values.
+func nodeSelectorsOverlap(a, b *sriovv1.SriovNetworkNodePolicy) bool {
+	for k, v := range a.Spec.NodeSelector {
+		if other, ok := b.Spec.NodeSelector[k]; ok && other != v {
+			return false
+		}
+	}
+	return true
+}
+
+// nodeDependentSelectors returns the set fields of the nic selector that only the
+// interfaces of the nodes can resolve.
+func nodeDependentSelectors(s *sriovv1.SriovNetworkNicSelector) []string {
+	res := []string{}
+	if s.Vendor != "" {
+		res = append(res, "vendor")
+	}
+	if s.DeviceID != "" {
+		res = append(res, "deviceID")
+	}
+	if len(s.RootDevices) > 0 {
+		res = append(res, "rootDevices")
+	}
+	return res
+}
+
+func selectsAny(s *sriovv1.SriovNetworkNicSelector) bool {
+	return s.Vendor != "" || s.DeviceID != "" || len(s.RootDevices) > 0 || len(s.PfNames) > 0
+}
+
+func deviceType(p *sriovv1.SriovNetworkNodePolicy) string {
+	if p.Spec.DeviceType == "" {
+		return "netdevice"
+	}
+	return p.Spec.DeviceType
+}
@@ -0,0 +1,154 @@
+package lint
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testPolicy(name, resource, deviceType string, numVfs int, pfNames ...string) sriovv1.SriovNetworkNodePolicy {
+	return sriovv1.SriovNetworkNodePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: sriovv1.SriovNetworkNodePolicySpec{
+			NodeSelector: map[string]string{"kubernetes.io/hostname": "worker-0"},
+			ResourceName: resource,
+			NumVfs:       numVfs,
+			DeviceType:   deviceType,
+			NicSelector: sriovv1.SriovNetworkNicSelector{
+				PfNames: pfNames,
+			},
+		},
+	}
+}
+
+// onNode moves the policy to the given node, or to all the nodes when empty.
+func onNode(p sriovv1.SriovNetworkNodePolicy, node string) sriovv1.SriovNetworkNodePolicy {
+	p.Spec.NodeSelector = map[string]string{}
+	if node != "" {
+		p.Spec.NodeSelector["kubernetes.io/hostname"] = node
+	}
+	return p
+}
+
+func withVendor(p sriovv1.SriovNetworkNodePolicy, vendor string) sriovv1.SriovNetworkNodePolicy {
+	p.Spec.NicSelector.Vendor = vendor
+	return p
+}
+
+func messages(issues []Issue) []string {
+	res := []string{}
+	for _, i := range issues {
+		res = append(res, i.Message)
+	}
+	return res
+}
+
+var _ = Describe("Policies", func() {
+	DescribeTable("without node states",
+		func(policies []sriovv1.SriovNetworkNodePolicy, expected []string) {
+			Expect(messages(Policies(policies, nil, nil))).To(ConsistOf(expected))
+		},
+		Entry("with disjoint ranges", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("p1", "res1", "netdevice", 5, "ens1f0#2-4"),
+			testPolicy("p2", "res2", "vfio-pci", 5, "ens1f0#0-1"),
+		}, []string{}),
+		Entry("with overlapping ranges", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("p1", "res1", "netdevice", 5, "ens1f0#1-4"),
+			testPolicy("p2", "res2", "vfio-pci", 5, "ens1f0#0-2"),
+		}, []string{"vf ranges 1-4 and 0-2 overlap"}),
+		Entry("with a range overlapping a whole pf", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("p1", "res1", "netdevice", 5, "ens1f0"),
+			testPolicy("p2", "res2", "netdevice", 5, "ens1f0#3-4"),
+		}, []string{"vf ranges 0-4 and 3-4 overlap"}),
+		Entry("with mismatching numVfs", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("p1", "res1", "netdevice", 5, "ens1f0#0-1"),
+			testPolicy("p2", "res2", "netdevice", 6, "ens1f0#2-3"),
+		}, []string{"numVfs mismatch 5 != 6"}),
+		Entry("with a resource used with different device types", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("p1", "res1", "", 5, "ens1f0"),
+			testPolicy("p2", "res1", "vfio-pci", 5, "ens1f1"),
+		}, []string{"resource res1 is used with different device types netdevice, vfio-pci"}),
+		Entry("with a range beyond numVfs", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("p1", "res1", "netdevice", 5, "ens1f0#3-5"),
+		}, []string{"vf range 3-5 exceeds numVfs 5"}),
+		Entry("with an invalid pf name", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("p1", "res1", "netdevice", 5, "ens1f0#3"),
+			testPolicy("p2", "res2", "netdevice", 5, "ens1f0#a-b"),
+		}, []string{`invalid pf name "ens1f0#3"`, `invalid pf name "ens1f0#a-b"`}),
+		Entry("with a reversed range", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("p1", "res1", "netdevice", 5, "ens1f0#3-1"),
+		}, []string{"vf range 3-1 is reversed"}),
+		Entry("ignoring the default policy", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("default", "res1", "netdevice", 5, "ens1f0"),
+			testPolicy("p1", "res1", "vfio-pci", 5, "ens1f0"),
+		}, []string{}),
+		Entry("with policies selecting disjoint nodes", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("p1", "res1", "netdevice", 5, "ens1f0"),
+			onNode(testPolicy("p2", "res2", "netdevice", 6, "ens1f0"), "worker-1"),
+		}, []string{}),
+		Entry("with a policy selecting all the nodes", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("p1", "res1", "netdevice", 5, "ens1f0"),
+			onNode(testPolicy("p2", "res2", "netdevice", 5, "ens1f0#3-4"), ""),
+		}, []string{"vf ranges 0-4 and 3-4 overlap"}),
+		Entry("with selectors needing the node states", []sriovv1.SriovNetworkNodePolicy{
+			withVendor(testPolicy("p1", "res1", "netdevice", 5), "8086"),
+		}, []string{"nic selector vendor unresolvable without node states"}),
+	)
+
+	Context("with node states", func() {
+		nodes := []corev1.Node{
+			{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Labels: map[string]string{"kubernetes.io/hostname": "worker-0"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "worker-1", Labels: map[string]string{"kubernetes.io/hostname": "worker-1"}}},
+		}
+		state := func(name string) sriovv1.SriovNetworkNodeState {
+			return sriovv1.SriovNetworkNodeState{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Status: sriovv1.SriovNetworkNodeStateStatus{
+					Interfaces: sriovv1.InterfaceExts{{
+						InterfaceProperty: sriovv1.InterfaceProperty{
+							Name:       "ens1f0",
+							PciAddress: "0000:3b:00.0",
+							Vendor:     "15b3",
+							DeviceID:   "1015",
+						},
+						TotalVfs: 4,
+					}},
+				},
+			}
+		}
+		states := []sriovv1.SriovNetworkNodeState{state("worker-0"), state("worker-1")}
+
+		It("should report ranges beyond the total vfs of the pf", func() {
+			issues := Policies([]sriovv1.SriovNetworkNodePolicy{
+				testPolicy("p1", "res1", "netdevice", 6, "ens1f0#2-5"),
+			}, nodes, states)
+			Expect(issues).To(HaveLen(2))
+			Expect(messages(issues)).To(ConsistOf(
+				"numVfs 6 exceeds the 4 vfs supported by the pf",
+				"vf range 2-5 exceeds the 4 vfs supported by the pf",
+			))
+			Expect(issues[0].Node).To(Equal("worker-0"))
+			Expect(issues[0].Interface).To(Equal("ens1f0"))
+		})
+
+		It("should resolve vendor selectors against the interfaces", func() {
+			p1 := testPolicy("p1", "res1", "netdevice", 4, "ens1f0#0-1")
+			p2 := testPolicy("p2", "res2", "netdevice", 4)
+			p2.Spec.NicSelector.Vendor = "15b3"
+			issues := Policies([]sriovv1.SriovNetworkNodePolicy{p1, p2}, nodes, states)
+			Expect(messages(issues)).To(ConsistOf("vf ranges 0-1 and 0-3 overlap"))
+			Expect(issues[0].Policies).To(Equal([]string{"p1", "p2"}))
+		})
+
+		It("should not compare policies selecting different nodes", func() {
+			p1 := testPolicy("p1", "res1", "netdevice", 4, "ens1f0")
+			p2 := testPolicy("p2", "res2", "netdevice", 2, "ens1f0")
+			p2.Spec.NodeSelector = map[string]string{"kubernetes.io/hostname": "worker-1"}
+			Expect(Policies([]sriovv1.SriovNetworkNodePolicy{p1, p2}, nodes, states)).To(BeEmpty())
+		})
+	})
+})
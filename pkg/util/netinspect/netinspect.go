@@ -0,0 +1,281 @@
+package netinspect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+	"github.com/openshift/sriov-tests/pkg/util/pod"
+)
+
+// execTimeout bounds the ip commands run in the pods.
+const execTimeout = time.Minute
+
+// CommandError is returned when ip exits with a non zero status,
+// i.e. when the requested device does not exist.
+type CommandError struct {
+	Args     []string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("ip %v exited with status %d: %s", e.Args, e.ExitCode, strings.TrimSpace(e.Stderr))
+}
+
+// Link is a network interface as reported by `ip -j -d link` or `ip -j addr`.
+type Link struct {
+	Ifindex   int        `json:"ifindex"`
+	Ifname    string     `json:"ifname"`
+	Flags     []string   `json:"flags"`
+	Mtu       int        `json:"mtu"`
+	MinMtu    int        `json:"min_mtu"`
+	MaxMtu    int        `json:"max_mtu"`
+	Operstate string     `json:"operstate"`
+	LinkType  string     `json:"link_type"`
+	Address   string     `json:"address"`
+	Master    string     `json:"master"`
+	AddrInfo  []AddrInfo `json:"addr_info"`
+	VFs       []VFInfo   `json:"vfinfo_list"`
+}
+
+// Route is a route as reported by `ip -j route`.
+type Route struct {
+	Dst     string `json:"dst"`
+	Gateway string `json:"gateway"`
+	Dev     string `json:"dev"`
+}
+
+// AddrInfo is an address assigned to a link.
+type AddrInfo struct {
+	Family    string `json:"family"`
+	Local     string `json:"local"`
+	Prefixlen int    `json:"prefixlen"`
+	Broadcast string `json:"broadcast"`
+	Scope     string `json:"scope"`
+	Label     string `json:"label"`
+}
+
+// VFInfo is the configuration of a virtual function, as reported on its pf.
+// The fields are normalized across the iproute2 releases: the vlan is taken from
+// the first entry of vlan_list when present, and the mac from either address or mac.
+type VFInfo struct {
+	ID        int
+	Mac       string
+	Vlan      int
+	Qos       int
+	MaxTxRate int
+	MinTxRate int
+	SpoofChk  bool
+	LinkState string
+	Trust     bool
+}
+
+type rawVFInfo struct {
+	VF       int    `json:"vf"`
+	Address  string `json:"address"`
+	Mac      string `json:"mac"`
+	Vlan     int    `json:"vlan"`
+	Qos      int    `json:"qos"`
+	VlanList []struct {
+		Vlan int `json:"vlan"`
+		Qos  int `json:"qos"`
+	} `json:"vlan_list"`
+	TxRate int `json:"tx_rate"`
+	Rate   struct {
+		MaxTx int `json:"max_tx"`
+		MinTx int `json:"min_tx"`
+	} `json:"rate"`
+	SpoofChk  bool   `json:"spoofchk"`
+	LinkState string `json:"link_state"`
+	Trust     bool   `json:"trust"`
+}
+
+// UnmarshalJSON decodes a vfinfo_list entry.
+func (v *VFInfo) UnmarshalJSON(data []byte) error {
+	raw := rawVFInfo{}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	*v = VFInfo{
+		ID:        raw.VF,
+		Mac:       raw.Address,
+		Vlan:      raw.Vlan,
+		Qos:       raw.Qos,
+		MaxTxRate: raw.Rate.MaxTx,
+		MinTxRate: raw.Rate.MinTx,
+		SpoofChk:  raw.SpoofChk,
+		LinkState: raw.LinkState,
+		Trust:     raw.Trust,
+	}
+	if v.Mac == "" {
+		v.Mac = raw.Mac
+	}
+	if len(raw.VlanList) > 0 {
+		v.Vlan = raw.VlanList[0].Vlan
+		v.Qos = raw.VlanList[0].Qos
+	}
+	if v.MaxTxRate == 0 {
+		v.MaxTxRate = raw.TxRate
+	}
+	return nil
+}
+
+// VF returns the virtual function with the given id.
+func (l *Link) VF(id int) (*VFInfo, error) {
+	for i := range l.VFs {
+		if l.VFs[i].ID == id {
+			return &l.VFs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("Vf %d not found on %s", id, l.Ifname)
+}
+
+// HasFlag tells if the link has the given flag (i.e. UP).
+func (l *Link) HasFlag(flag string) bool {
+	for _, f := range l.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// IPs returns the addresses of the link of the given family (inet or inet6),
+// or all of them if the family is empty.
+func (l *Link) IPs(family string) []string {
+	res := []string{}
+	for _, a := range l.AddrInfo {
+		if family == "" || a.Family == family {
+			res = append(res, a.Local)
+		}
+	}
+	return res
+}
+
+// ParseLinks decodes the output of `ip -j link` or `ip -j addr`.
+func ParseLinks(data string) ([]Link, error) {
+	links := []Link{}
+	err := json.Unmarshal([]byte(strings.TrimSpace(data)), &links)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the ip output %v", err)
+	}
+	// ip -j addr emits empty objects for the links filtered out
+	res := []Link{}
+	for _, l := range links {
+		if l.Ifname != "" {
+			res = append(res, l)
+		}
+	}
+	return res, nil
+}
+
+// ParseRoutes decodes the output of `ip -j route`.
+func ParseRoutes(data string) ([]Route, error) {
+	res := []Route{}
+	err := json.Unmarshal([]byte(strings.TrimSpace(data)), &res)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the ip output %v", err)
+	}
+	return res, nil
+}
+
+// DefaultRouteDevices returns the devices carrying the default route: the
+// ones the route goes through, and the ones enslaved to them (i.e. the ports
+// of a bridge or of a bond).
+func DefaultRouteDevices(routes []Route, links []Link) []string {
+	res := []string{}
+	carrying := map[string]bool{}
+	for _, r := range routes {
+		if r.Dst == "default" && r.Dev != "" && !carrying[r.Dev] {
+			carrying[r.Dev] = true
+			res = append(res, r.Dev)
+		}
+	}
+	for added := true; added; {
+		added = false
+		for _, l := range links {
+			if l.Master != "" && carrying[l.Master] && !carrying[l.Ifname] {
+				carrying[l.Ifname] = true
+				res = append(res, l.Ifname)
+				added = true
+			}
+		}
+	}
+	return res
+}
+
+// DefaultRoute returns the devices carrying the default route in the given pod,
+// which must run in the host network to inspect the node.
+func DefaultRoute(cs *testclient.ClientSet, podObj *corev1.Pod) ([]string, error) {
+	res, err := pod.Exec(context.Background(), cs, podObj, pod.ExecOptions{
+		Command: []string{"ip", "-j", "route", "show", "default"},
+		Timeout: execTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get the routes of pod %s/%s %v", podObj.Namespace, podObj.Name, err)
+	}
+	if !res.Success() {
+		return nil, &CommandError{Args: []string{"-j", "route", "show", "default"}, ExitCode: res.ExitCode, Stderr: res.Stderr}
+	}
+	routes, err := ParseRoutes(res.Stdout)
+	if err != nil {
+		return nil, err
+	}
+	links, err := Links(cs, podObj)
+	if err != nil {
+		return nil, err
+	}
+	return DefaultRouteDevices(routes, links), nil
+}
+
+// Links returns all the links of the pod, with their details.
+func Links(cs *testclient.ClientSet, podObj *corev1.Pod) ([]Link, error) {
+	return runIP(cs, podObj, "-j", "-d", "link", "show")
+}
+
+// LinkByName returns the link of the pod with the given name, with its details.
+func LinkByName(cs *testclient.ClientSet, podObj *corev1.Pod, name string) (*Link, error) {
+	links, err := runIP(cs, podObj, "-j", "-d", "link", "show", "dev", name)
+	if err != nil {
+		return nil, err
+	}
+	return single(links, name)
+}
+
+// Addresses returns the link of the pod with the given name, with its addresses.
+func Addresses(cs *testclient.ClientSet, podObj *corev1.Pod, name string) (*Link, error) {
+	links, err := runIP(cs, podObj, "-j", "addr", "show", "dev", name)
+	if err != nil {
+		return nil, err
+	}
+	return single(links, name)
+}
+
+func single(links []Link, name string) (*Link, error) {
+	if len(links) != 1 {
+		return nil, fmt.Errorf("Expected one link named %s, found %d", name, len(links))
+	}
+	return &links[0], nil
+}
+
+func runIP(cs *testclient.ClientSet, podObj *corev1.Pod, args ...string) ([]Link, error) {
+	res, err := pod.Exec(context.Background(), cs, podObj, pod.ExecOptions{
+		Command: append([]string{"ip"}, args...),
+		Timeout: execTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to run ip %v in pod %s/%s %v", args, podObj.Namespace, podObj.Name, err)
+	}
+	if !res.Success() {
+		return nil, &CommandError{Args: args, ExitCode: res.ExitCode, Stderr: res.Stderr}
+	}
+	return ParseLinks(res.Stdout)
+}
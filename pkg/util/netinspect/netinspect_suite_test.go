@@ -0,0 +1,13 @@
+package netinspect
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestNetinspect(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Netinspect Suite")
+}
@@ -0,0 +1,109 @@
+package netinspect
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func fixture(name string) []Link {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	Expect(err).ToNot(HaveOccurred())
+	links, err := ParseLinks(string(data))
+	Expect(err).ToNot(HaveOccurred())
+	return links
+}
+
+var _ = Describe("ParseLinks", func() {
+	It("decodes the vfs reported with vlan_list", func() {
+		links := fixture("pf_link.json")
+		Expect(links).To(HaveLen(1))
+		pf := links[0]
+		Expect(pf.Ifname).To(Equal("ens1f0"))
+		Expect(pf.Mtu).To(Equal(1500))
+		Expect(pf.MaxMtu).To(Equal(9978))
+		Expect(pf.HasFlag("UP")).To(BeTrue())
+		Expect(pf.VFs).To(HaveLen(3))
+
+		vf, err := pf.VF(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*vf).To(Equal(VFInfo{
+			ID:        1,
+			Mac:       "36:7c:7a:c4:59:3e",
+			Vlan:      1,
+			Qos:       2,
+			MaxTxRate: 100,
+			MinTxRate: 40,
+			SpoofChk:  true,
+			LinkState: "enable",
+			Trust:     true,
+		}))
+
+		vf, err = pf.VF(2)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vf.LinkState).To(Equal("disable"))
+		Expect(vf.Vlan).To(Equal(0))
+
+		_, err = pf.VF(3)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("decodes the vfs reported by older iproute2 releases", func() {
+		links := fixture("pf_link_legacy.json")
+		Expect(links).To(HaveLen(1))
+		vf, err := links[0].VF(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*vf).To(Equal(VFInfo{
+			ID:        1,
+			Mac:       "d2:4c:5b:e5:4f:0b",
+			Vlan:      10,
+			Qos:       3,
+			MaxTxRate: 200,
+			LinkState: "auto",
+			Trust:     true,
+		}))
+		vf, err = links[0].VF(0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vf.SpoofChk).To(BeTrue())
+	})
+
+	It("decodes the addresses, skipping the filtered links", func() {
+		links := fixture("net1_addr.json")
+		Expect(links).To(HaveLen(1))
+		net1 := links[0]
+		Expect(net1.Ifname).To(Equal("net1"))
+		Expect(net1.Mtu).To(Equal(9000))
+		Expect(net1.Address).To(Equal("36:7c:7a:c4:59:3e"))
+		Expect(net1.IPs("inet")).To(Equal([]string{"10.10.10.171"}))
+		Expect(net1.IPs("inet6")).To(Equal([]string{"fe80::347c:7aff:fec4:593e"}))
+		Expect(net1.IPs("")).To(HaveLen(2))
+	})
+
+	It("tolerates the carriage returns added by the tty", func() {
+		links, err := ParseLinks("[{\"ifname\":\"lo\",\"mtu\":65536}]\r\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(links).To(HaveLen(1))
+	})
+
+	It("fails on invalid output", func() {
+		_, err := ParseLinks("Device \"net2\" does not exist.")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("DefaultRouteDevices", func() {
+	It("includes the ports of the bridge carrying the default route", func() {
+		routes, err := ParseRoutes(`[{"dst":"default","gateway":"192.168.111.1","dev":"br-ex","protocol":"dhcp","metric":48,"flags":[]}]`)
+		Expect(err).ToNot(HaveOccurred())
+		links := []Link{
+			{Ifname: "br-ex"},
+			{Ifname: "bond0", Master: "br-ex"},
+			{Ifname: "eno1", Master: "bond0"},
+			{Ifname: "eno2", Master: "bond0"},
+			{Ifname: "ens1f0"},
+		}
+		Expect(DefaultRouteDevices(routes, links)).To(Equal([]string{"br-ex", "bond0", "eno1", "eno2"}))
+	})
+})
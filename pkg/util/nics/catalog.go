@@ -0,0 +1,175 @@
+package nics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// CatalogEnv is the environment variable pointing to a catalog file whose
+// entries take precedence over the default ones.
+const CatalogEnv = "SRIOV_NIC_CATALOG"
+
+// Capability is a feature a NIC may support on its virtual functions.
+type Capability string
+
+const (
+	RateLimit Capability = "rateLimit"
+	Trust     Capability = "trust"
+	SpoofChk  Capability = "spoofChk"
+	LinkState Capability = "linkState"
+	RDMA      Capability = "rdma"
+	VfioPci   Capability = "vfioPci"
+)
+
+// Catalog lists the NICs the suites can run against.
+type Catalog struct {
+	NICs []NIC `json:"nics"`
+
+	// overrides is the number of leading NICs loaded from the override file.
+	overrides int
+}
+
+// NIC describes the capabilities of a family of cards. Vendor, DeviceID and Driver
+// identify the cards the entry applies to, an empty value matches any card.
+type NIC struct {
+	Name         string       `json:"name"`
+	Vendor       string       `json:"vendor,omitempty"`
+	DeviceID     string       `json:"deviceID,omitempty"`
+	Driver       string       `json:"driver,omitempty"`
+	MaxVfs       int          `json:"maxVfs,omitempty"`
+	Capabilities []Capability `json:"capabilities"`
+	Bugs         []Bug        `json:"bugs,omitempty"`
+}
+
+// Bug is a known issue making a capability unusable.
+type Bug struct {
+	Capability  Capability `json:"capability"`
+	References  []string   `json:"references"`
+	Description string     `json:"description,omitempty"`
+}
+
+// Default returns the catalog shipped with the tests.
+func Default() *Catalog {
+	res, err := Parse([]byte(defaultCatalog))
+	if err != nil {
+		panic(fmt.Sprintf("Invalid default nic catalog %v", err))
+	}
+	return res
+}
+
+// Load returns the default catalog, extended with the one pointed by
+// the SRIOV_NIC_CATALOG environment variable if set.
+func Load() (*Catalog, error) {
+	res := Default()
+	path := os.Getenv(CatalogEnv)
+	if path == "" {
+		return res, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read nic catalog %s %v", path, err)
+	}
+	override, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse nic catalog %s %v", path, err)
+	}
+	res.NICs = append(override.NICs, res.NICs...)
+	res.overrides = len(override.NICs)
+	return res, nil
+}
+
+// Parse decodes a yaml catalog.
+func Parse(data []byte) (*Catalog, error) {
+	res := &Catalog{}
+	err := yaml.UnmarshalStrict(data, res)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range res.NICs {
+		if n.Vendor == "" && n.DeviceID == "" && n.Driver == "" {
+			return nil, fmt.Errorf("Nic %s matches any card", n.Name)
+		}
+	}
+	return res, nil
+}
+
+// Lookup returns the most specific entry matching the card. The entries of the
+// override file win over the default ones whenever they match, and among entries
+// equally specific, the first one wins.
+func (c *Catalog) Lookup(vendor, deviceID, driver string) (*NIC, bool) {
+	if res := mostSpecific(c.NICs[:c.overrides], vendor, deviceID, driver); res != nil {
+		return res, true
+	}
+	res := mostSpecific(c.NICs[c.overrides:], vendor, deviceID, driver)
+	return res, res != nil
+}
+
+func mostSpecific(nics []NIC, vendor, deviceID, driver string) *NIC {
+	var res *NIC
+	best := 0
+	for i := range nics {
+		n := &nics[i]
+		score := 0
+		for _, f := range []struct{ want, got string }{
+			{n.Vendor, vendor},
+			{n.DeviceID, deviceID},
+			{n.Driver, driver},
+		} {
+			if f.want == "" {
+				continue
+			}
+			if !strings.EqualFold(f.want, f.got) {
+				score = -1
+				break
+			}
+			score++
+		}
+		if score > best {
+			res = n
+			best = score
+		}
+	}
+	return res
+}
+
+// LookupInterface returns the entry matching the interface.
+func (c *Catalog) LookupInterface(itf sriovv1.InterfaceExt) (*NIC, bool) {
+	return c.Lookup(itf.Vendor, itf.DeviceID, itf.Driver)
+}
+
+// IsSupported tells if the interface is a card the suites can run against.
+func (c *Catalog) IsSupported(itf sriovv1.InterfaceExt) bool {
+	_, ok := c.LookupInterface(itf)
+	return ok
+}
+
+// Supports tells if the interface supports the given capability.
+// When it does not, the reason is returned.
+func (c *Catalog) Supports(itf sriovv1.InterfaceExt, capability Capability) (bool, string) {
+	n, ok := c.LookupInterface(itf)
+	if !ok {
+		return false, fmt.Sprintf("Nic %s %s:%s (%s) not in the catalog", itf.Name, itf.Vendor, itf.DeviceID, itf.Driver)
+	}
+	return n.Supports(capability)
+}
+
+// Supports tells if the nic supports the given capability.
+// When it does not, the reason is returned.
+func (n *NIC) Supports(capability Capability) (bool, string) {
+	for _, b := range n.Bugs {
+		if b.Capability == capability {
+			return false, fmt.Sprintf("%s %s is broken (%s)", n.Name, capability, strings.Join(b.References, ", "))
+		}
+	}
+	for _, c := range n.Capabilities {
+		if c == capability {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("%s does not support %s", n.Name, capability)
+}
@@ -0,0 +1,142 @@
+package nics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+)
+
+func testInterface(vendor, deviceID, driver string) sriovv1.InterfaceExt {
+	return sriovv1.InterfaceExt{
+		InterfaceProperty: sriovv1.InterfaceProperty{
+			Name:     "ens1f0",
+			Vendor:   vendor,
+			DeviceID: deviceID,
+			Driver:   driver,
+		},
+	}
+}
+
+var _ = Describe("Catalog", func() {
+	DescribeTable("the default catalog",
+		func(itf sriovv1.InterfaceExt, supported bool, capability Capability, capable bool) {
+			catalog := Default()
+			Expect(catalog.IsSupported(itf)).To(Equal(supported))
+			res, reason := catalog.Supports(itf, capability)
+			Expect(res).To(Equal(capable))
+			Expect(reason == "").To(Equal(capable))
+		},
+		Entry("mellanox rate limit", testInterface("15b3", "1015", "mlx5_core"), true, RateLimit, true),
+		Entry("mellanox vfio-pci", testInterface("15b3", "1015", "mlx5_core"), true, VfioPci, false),
+		Entry("intel i40e rate limit", testInterface("8086", "158b", "i40e"), true, RateLimit, false),
+		Entry("intel ixgbe trust", testInterface("8086", "10fb", "ixgbe"), true, Trust, true),
+		Entry("unknown card", testInterface("14e4", "16d7", "bnxt_en"), false, Trust, false),
+	)
+
+	It("reports the bugs preventing a capability", func() {
+		_, reason := Default().Supports(testInterface("8086", "158b", "i40e"), RateLimit)
+		Expect(reason).To(ContainSubstring("BZ 1772847"))
+	})
+
+	It("prefers the most specific entry", func() {
+		catalog, err := Parse([]byte(`
+nics:
+- name: any intel
+  vendor: "8086"
+  capabilities: [trust]
+- name: intel e810
+  vendor: "8086"
+  deviceID: "159b"
+  driver: ice
+  capabilities: [trust, rateLimit]
+`))
+		Expect(err).ToNot(HaveOccurred())
+		n, ok := catalog.Lookup("8086", "159b", "ice")
+		Expect(ok).To(BeTrue())
+		Expect(n.Name).To(Equal("intel e810"))
+		n, ok = catalog.Lookup("8086", "1572", "i40e")
+		Expect(ok).To(BeTrue())
+		Expect(n.Name).To(Equal("any intel"))
+		_, ok = catalog.Lookup("15b3", "1015", "mlx5_core")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("rejects invalid catalogs", func() {
+		_, err := Parse([]byte("nics:\n- name: any\n  capabilities: [trust]\n"))
+		Expect(err).To(HaveOccurred())
+		_, err = Parse([]byte("nics:\n- name: typo\n  driver: ice\n  capabilites: [trust]\n"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("with an override file", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "nics")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.Unsetenv(CatalogEnv)
+			os.RemoveAll(dir)
+		})
+
+		It("adds new cards and overrides the default ones", func() {
+			path := filepath.Join(dir, "catalog.yaml")
+			err := ioutil.WriteFile(path, []byte(`
+nics:
+- name: Intel E810
+  vendor: "8086"
+  driver: ice
+  capabilities: [trust, spoofChk]
+- name: Mellanox without rdma
+  vendor: "15b3"
+  driver: mlx5_core
+  capabilities: [rateLimit, trust, spoofChk, linkState]
+`), 0644)
+			Expect(err).ToNot(HaveOccurred())
+			os.Setenv(CatalogEnv, path)
+
+			catalog, err := Load()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(catalog.IsSupported(testInterface("8086", "159b", "ice"))).To(BeTrue())
+			res, _ := catalog.Supports(testInterface("15b3", "1015", "mlx5_core"), RDMA)
+			Expect(res).To(BeFalse())
+			Expect(catalog.IsSupported(testInterface("8086", "1572", "i40e"))).To(BeTrue())
+		})
+
+		It("prefers an override to a more specific default entry", func() {
+			path := filepath.Join(dir, "catalog.yaml")
+			err := ioutil.WriteFile(path, []byte(`
+nics:
+- name: Any Mellanox
+  vendor: "15b3"
+  capabilities: [trust]
+`), 0644)
+			Expect(err).ToNot(HaveOccurred())
+			os.Setenv(CatalogEnv, path)
+
+			catalog, err := Load()
+			Expect(err).ToNot(HaveOccurred())
+			n, ok := catalog.Lookup("15b3", "1015", "mlx5_core")
+			Expect(ok).To(BeTrue())
+			Expect(n.Name).To(Equal("Any Mellanox"))
+			n, ok = catalog.Lookup("8086", "158b", "i40e")
+			Expect(ok).To(BeTrue())
+			Expect(n.Name).ToNot(Equal("Any Mellanox"))
+		})
+
+		It("fails when the file does not exist", func() {
+			os.Setenv(CatalogEnv, filepath.Join(dir, "missing.yaml"))
+			_, err := Load()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,29 @@
+package nics
+
+// defaultCatalog lists the cards the suites are known to run against.
+const defaultCatalog = `
+nics:
+- name: Mellanox ConnectX
+  vendor: "15b3"
+  driver: mlx5_core
+  capabilities: [rateLimit, trust, spoofChk, linkState, rdma]
+
+- name: Intel XL710
+  vendor: "8086"
+  driver: i40e
+  capabilities: [rateLimit, trust, spoofChk, linkState, vfioPci]
+  bugs:
+  - capability: rateLimit
+    references: ["BZ 1772847", "BZ 1772815", "BZ 1236146"]
+    description: the rate limit flags are not applied to the vf
+
+- name: Intel 82599
+  vendor: "8086"
+  driver: ixgbe
+  maxVfs: 63
+  capabilities: [rateLimit, trust, spoofChk, linkState, vfioPci]
+  bugs:
+  - capability: rateLimit
+    references: ["BZ 1772847", "BZ 1772815", "BZ 1236146"]
+    description: the rate limit flags are not applied to the vf
+`
@@ -0,0 +1,242 @@
+package pod
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/utils/pointer"
+
+	"github.com/openshift/sriov-tests/pkg/util/namespaces"
+)
+
+const (
+	// DefaultImage is the image of the test containers.
+	DefaultImage = "quay.io/schseba/utility-container:latest"
+	// NetworksAnnotation is the annotation the multus attachments are requested with.
+	NetworksAnnotation = "k8s.v1.cni.cncf.io/networks"
+	// ResourcePrefix is the prefix of the resources exposed by the device plugin.
+	ResourcePrefix = "openshift.io/"
+	// hostnameLabel is the label pinning a pod to a node through its node selector.
+	hostnameLabel = "kubernetes.io/hostname"
+)
+
+// Option customizes the pod returned by Define. The options about resources and
+// security apply to the test container, the first one.
+type Option func(*corev1.Pod)
+
+// Define returns a test pod in the test namespace, running the utility image
+// and sleeping, customized by the options.
+func Define(opts ...Option) *corev1.Pod {
+	podObject := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "testpod" + rand.String(12),
+			Namespace: namespaces.Test,
+		},
+		Spec: corev1.PodSpec{
+			TerminationGracePeriodSeconds: pointer.Int64Ptr(0),
+			Containers: []corev1.Container{{
+				Name:    "test",
+				Image:   DefaultImage,
+				Command: []string{"/bin/bash", "-c", "sleep INF"},
+			}},
+		},
+	}
+	for _, o := range opts {
+		o(podObject)
+	}
+	return podObject
+}
+
+// WithName sets the name of the pod.
+func WithName(name string) Option {
+	return func(p *corev1.Pod) {
+		p.Name = name
+	}
+}
+
+// WithNamespace sets the namespace of the pod.
+func WithNamespace(namespace string) Option {
+	return func(p *corev1.Pod) {
+		p.Namespace = namespace
+	}
+}
+
+// WithLabels adds the labels to the pod.
+func WithLabels(labels map[string]string) Option {
+	return func(p *corev1.Pod) {
+		if p.Labels == nil {
+			p.Labels = map[string]string{}
+		}
+		for k, v := range labels {
+			p.Labels[k] = v
+		}
+	}
+}
+
+// OnNode pins the pod to the node through its hostname label, so the scheduler
+// still checks the node has the requested resources.
+func OnNode(node string) Option {
+	return WithNodeSelector(map[string]string{hostnameLabel: node})
+}
+
+// WithNodeName binds the pod to the node, skipping the scheduler.
+func WithNodeName(node string) Option {
+	return func(p *corev1.Pod) {
+		p.Spec.NodeName = node
+	}
+}
+
+// WithNodeSelector adds the labels to the node selector of the pod.
+func WithNodeSelector(selector map[string]string) Option {
+	return func(p *corev1.Pod) {
+		if p.Spec.NodeSelector == nil {
+			p.Spec.NodeSelector = map[string]string{}
+		}
+		for k, v := range selector {
+			p.Spec.NodeSelector[k] = v
+		}
+	}
+}
+
+// WithHostNetwork runs the pod in the host network namespace.
+func WithHostNetwork() Option {
+	return func(p *corev1.Pod) {
+		p.Spec.HostNetwork = true
+	}
+}
+
+// WithImage replaces the image of the test container.
+func WithImage(image string) Option {
+	return func(p *corev1.Pod) {
+		p.Spec.Containers[0].Image = image
+	}
+}
+
+// WithCommand replaces the command of the test container.
+func WithCommand(command ...string) Option {
+	return func(p *corev1.Pod) {
+		p.Spec.Containers[0].Command = command
+	}
+}
+
+// WithContainer adds a container to the pod. Its image defaults to the utility one.
+func WithContainer(container corev1.Container) Option {
+	return func(p *corev1.Pod) {
+		if container.Image == "" {
+			container.Image = DefaultImage
+		}
+		p.Spec.Containers = append(p.Spec.Containers, container)
+	}
+}
+
+// Privileged runs the test container privileged.
+func Privileged() Option {
+	return func(p *corev1.Pod) {
+		securityContext(p).Privileged = pointer.BoolPtr(true)
+	}
+}
+
+// WithNetAdmin grants NET_ADMIN to the test container.
+func WithNetAdmin() Option {
+	return WithCapabilities("NET_ADMIN")
+}
+
+// WithCapabilities grants the capabilities to the test container.
+func WithCapabilities(capabilities ...corev1.Capability) Option {
+	return func(p *corev1.Pod) {
+		sc := securityContext(p)
+		if sc.Capabilities == nil {
+			sc.Capabilities = &corev1.Capabilities{}
+		}
+		sc.Capabilities.Add = append(sc.Capabilities.Add, capabilities...)
+	}
+}
+
+// WithResource requests count devices of the device plugin resource, i.e.
+// testresource for openshift.io/testresource, as both request and limit.
+func WithResource(name string, count int64) Option {
+	if !strings.Contains(name, "/") {
+		name = ResourcePrefix + name
+	}
+	return withGuaranteed(corev1.ResourceName(name), resource.MustParse(strconv.FormatInt(count, 10)))
+}
+
+// WithHugepages requests the amount of hugepages of the given size, i.e. 1Gi,
+// and mounts them at /dev/hugepages. Memory must be requested too, see WithCPUs.
+func WithHugepages(pageSize, amount string) Option {
+	quantity := resource.MustParse(amount)
+	name := corev1.ResourceName(corev1.ResourceHugePagesPrefix + pageSize)
+	return func(p *corev1.Pod) {
+		withGuaranteed(name, quantity)(p)
+		p.Spec.Volumes = append(p.Spec.Volumes, corev1.Volume{
+			Name: "hugepages",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumHugePages},
+			},
+		})
+		c := &p.Spec.Containers[0]
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{Name: "hugepages", MountPath: "/dev/hugepages"})
+	}
+}
+
+// WithCPUs requests whole cpus and memory with equal limits, so the pod is in the
+// guaranteed qos class and gets pinned cpus when the static cpu manager is enabled.
+func WithCPUs(cpus int64, memory string) Option {
+	cpu := withGuaranteed(corev1.ResourceCPU, resource.MustParse(strconv.FormatInt(cpus, 10)))
+	mem := withGuaranteed(corev1.ResourceMemory, resource.MustParse(memory))
+	return func(p *corev1.Pod) {
+		cpu(p)
+		mem(p)
+	}
+}
+
+// WithNetworks requests the multus attachments, in the json form of the annotation.
+func WithNetworks(elements ...NetworkSelectionElement) Option {
+	return func(p *corev1.Pod) {
+		// the elements hold only strings, encoding them can't fail
+		data, _ := json.Marshal(elements)
+		setAnnotation(p, NetworksAnnotation, string(data))
+	}
+}
+
+// WithNetworkNames requests the multus attachments to the networks, in the
+// short form of the annotation.
+func WithNetworkNames(networks ...string) Option {
+	return func(p *corev1.Pod) {
+		setAnnotation(p, NetworksAnnotation, strings.Join(networks, ","))
+	}
+}
+
+func withGuaranteed(name corev1.ResourceName, quantity resource.Quantity) Option {
+	return func(p *corev1.Pod) {
+		c := &p.Spec.Containers[0]
+		if c.Resources.Requests == nil {
+			c.Resources.Requests = corev1.ResourceList{}
+		}
+		if c.Resources.Limits == nil {
+			c.Resources.Limits = corev1.ResourceList{}
+		}
+		c.Resources.Requests[name] = quantity
+		c.Resources.Limits[name] = quantity
+	}
+}
+
+func securityContext(p *corev1.Pod) *corev1.SecurityContext {
+	c := &p.Spec.Containers[0]
+	if c.SecurityContext == nil {
+		c.SecurityContext = &corev1.SecurityContext{}
+	}
+	return c.SecurityContext
+}
+
+func setAnnotation(p *corev1.Pod, key, value string) {
+	if p.Annotations == nil {
+		p.Annotations = map[string]string{}
+	}
+	p.Annotations[key] = value
+}
@@ -0,0 +1,80 @@
+package pod
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/openshift/sriov-tests/pkg/util/namespaces"
+)
+
+var _ = Describe("Define", func() {
+	It("returns a sleeping utility pod in the test namespace by default", func() {
+		p := Define()
+		Expect(p.Name).To(HavePrefix("testpod"))
+		Expect(p.Namespace).To(Equal(namespaces.Test))
+		Expect(p.Spec.Containers).To(HaveLen(1))
+		Expect(p.Spec.Containers[0].Image).To(Equal(DefaultImage))
+		Expect(p.Spec.Containers[0].Command).To(Equal([]string{"/bin/bash", "-c", "sleep INF"}))
+		Expect(Define().Name).ToNot(Equal(p.Name))
+	})
+
+	It("pins the pod and requests the sriov resources", func() {
+		p := Define(
+			OnNode("worker-0"),
+			WithNodeSelector(map[string]string{"sriov": "true"}),
+			WithResource("testresource", 2),
+			WithResource("example.com/other", 1),
+		)
+		Expect(p.Spec.NodeSelector).To(Equal(map[string]string{"kubernetes.io/hostname": "worker-0", "sriov": "true"}))
+		resources := p.Spec.Containers[0].Resources
+		Expect(resources.Requests).To(Equal(resources.Limits))
+		Expect(resources.Limits).To(HaveKeyWithValue(corev1.ResourceName("openshift.io/testresource"), resource.MustParse("2")))
+		Expect(resources.Limits).To(HaveKeyWithValue(corev1.ResourceName("example.com/other"), resource.MustParse("1")))
+
+		Expect(Define(WithNodeName("worker-1")).Spec.NodeName).To(Equal("worker-1"))
+	})
+
+	It("configures a dpdk style pod", func() {
+		p := Define(
+			WithImage("dpdk:latest"),
+			WithCommand("testpmd"),
+			Privileged(),
+			WithNetAdmin(),
+			WithCPUs(4, "1Gi"),
+			WithHugepages("1Gi", "2Gi"),
+			WithContainer(corev1.Container{Name: "sidecar"}),
+		)
+		c := p.Spec.Containers[0]
+		Expect(c.Image).To(Equal("dpdk:latest"))
+		Expect(c.Command).To(Equal([]string{"testpmd"}))
+		Expect(*c.SecurityContext.Privileged).To(BeTrue())
+		Expect(c.SecurityContext.Capabilities.Add).To(Equal([]corev1.Capability{"NET_ADMIN"}))
+		Expect(c.Resources.Limits).To(Equal(corev1.ResourceList{
+			corev1.ResourceCPU:                   resource.MustParse("4"),
+			corev1.ResourceMemory:                resource.MustParse("1Gi"),
+			corev1.ResourceName("hugepages-1Gi"): resource.MustParse("2Gi"),
+		}))
+		Expect(c.Resources.Requests).To(Equal(c.Resources.Limits))
+		Expect(c.VolumeMounts).To(ConsistOf(corev1.VolumeMount{Name: "hugepages", MountPath: "/dev/hugepages"}))
+		Expect(p.Spec.Volumes[0].EmptyDir.Medium).To(Equal(corev1.StorageMediumHugePages))
+
+		Expect(p.Spec.Containers).To(HaveLen(2))
+		Expect(p.Spec.Containers[1].Image).To(Equal(DefaultImage))
+	})
+
+	It("requests the networks through the multus annotation", func() {
+		p := Define(WithNetworks(
+			NetworkSelectionElement{Name: "net", InterfaceRequest: "sriov0", MacRequest: "20:04:0f:f1:88:01", IPRequest: []string{"10.10.10.2/24"}},
+			NetworkSelectionElement{Name: "net", Namespace: "other"},
+		))
+		Expect(p.Annotations[NetworksAnnotation]).To(MatchJSON(`[
+			{"name":"net","interface":"sriov0","mac":"20:04:0f:f1:88:01","ips":["10.10.10.2/24"]},
+			{"name":"net","namespace":"other"}
+		]`))
+
+		Expect(DefineWithNetworks([]string{"a", "b"}).Annotations[NetworksAnnotation]).To(Equal("a,b"))
+	})
+})
@@ -0,0 +1,239 @@
+package pod
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+	"github.com/openshift/sriov-tests/pkg/util/namespaces"
+	"github.com/openshift/sriov-tests/pkg/util/wait"
+)
+
+const (
+	// HostRoot is where the root of the node is mounted in the debug pods.
+	HostRoot = "/host"
+	// DebugLabel marks the debug pods.
+	DebugLabel = "sriov-tests.openshift.io/debug"
+	// DebugNodeAnnotation holds the node of a debug pod, as a node name may
+	// exceed the length of a label value.
+	DebugNodeAnnotation = "sriov-tests.openshift.io/debug-node"
+
+	debugStartTimeout = 3 * time.Minute
+	debugExecTimeout  = time.Minute
+)
+
+var (
+	debuggersLock sync.Mutex
+	debuggers     = map[string]*Debugger{}
+)
+
+// Debugger runs commands on the host of a node through a privileged pod, as
+// `oc debug node` does, so the checks do not depend on the tools of the pod image.
+type Debugger struct {
+	Node string
+	Pod  *corev1.Pod
+	cs   *testclient.ClientSet
+}
+
+// HostVF is a vf of a pf as seen from the sysfs of the node.
+type HostVF struct {
+	ID         int
+	PciAddress string
+	// Driver is empty when no driver is bound.
+	Driver string
+	// Netdev is empty when the vf has no netdevice in the host network namespace,
+	// i.e. when it is bound to vfio-pci or moved into a pod.
+	Netdev string
+}
+
+// DebugOnNode returns the debugger of the node, creating its pod if no running
+// one exists. The pods are reused until CleanupDebuggers is called.
+func DebugOnNode(cs *testclient.ClientSet, node string) (*Debugger, error) {
+	debuggersLock.Lock()
+	defer debuggersLock.Unlock()
+
+	name := debugPodName(node)
+	existing, err := cs.Pods(namespaces.Test).Get(name, metav1.GetOptions{})
+	switch {
+	case err == nil && existing.DeletionTimestamp == nil && existing.Status.Phase == corev1.PodRunning:
+		d := &Debugger{Node: node, Pod: existing, cs: cs}
+		debuggers[node] = d
+		return d, nil
+	case err == nil:
+		if err := deleteDebugPod(cs, name); err != nil {
+			return nil, err
+		}
+	case !k8serrors.IsNotFound(err):
+		return nil, fmt.Errorf("Failed to get the debug pod of %s %v", node, err)
+	}
+
+	definition := Define(
+		WithName(name),
+		WithNodeName(node),
+		WithHostNetwork(),
+		Privileged(),
+		WithLabels(map[string]string{DebugLabel: "true"}),
+	)
+	setAnnotation(definition, DebugNodeAnnotation, node)
+	definition.Spec.HostPID = true
+	definition.Spec.Tolerations = []corev1.Toleration{{Operator: corev1.TolerationOpExists}}
+	definition.Spec.Volumes = append(definition.Spec.Volumes, corev1.Volume{
+		Name: "host",
+		VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{Path: "/"},
+		},
+	})
+	definition.Spec.Containers[0].VolumeMounts = append(definition.Spec.Containers[0].VolumeMounts,
+		corev1.VolumeMount{Name: "host", MountPath: HostRoot})
+
+	if _, err := cs.Pods(namespaces.Test).Create(definition); err != nil {
+		return nil, fmt.Errorf("Failed to create the debug pod of %s %v", node, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), debugStartTimeout)
+	defer cancel()
+	running, err := wait.ForPodRunning(ctx, cs, namespaces.Test, name)
+	if err != nil {
+		return nil, err
+	}
+	d := &Debugger{Node: node, Pod: running, cs: cs}
+	debuggers[node] = d
+	return d, nil
+}
+
+// CleanupDebuggers deletes the debug pods created by DebugOnNode.
+func CleanupDebuggers(cs *testclient.ClientSet) error {
+	debuggersLock.Lock()
+	defer debuggersLock.Unlock()
+	for node := range debuggers {
+		if err := deleteDebugPod(cs, debugPodName(node)); err != nil {
+			return err
+		}
+		delete(debuggers, node)
+	}
+	return nil
+}
+
+// Run runs the command on the host and returns its stdout. A non zero exit
+// status is returned as an error, with the stderr.
+func (d *Debugger) Run(command ...string) (string, error) {
+	res, err := Exec(context.Background(), d.cs, d.Pod, ExecOptions{
+		Command: append([]string{"chroot", HostRoot}, command...),
+		Timeout: debugExecTimeout,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Failed to run %v on %s %v", command, d.Node, err)
+	}
+	if !res.Success() {
+		return res.Stdout, fmt.Errorf("Command %v exited on %s with status %d: %s", command, d.Node, res.ExitCode, strings.TrimSpace(res.Stderr))
+	}
+	return res.Stdout, nil
+}
+
+// NumVfs returns the number of vfs enabled on the pf.
+func (d *Debugger) NumVfs(pf string) (int, error) {
+	out, err := d.Run("cat", path.Join("/sys/class/net", pf, "device/sriov_numvfs"))
+	if err != nil {
+		return 0, err
+	}
+	numVfs, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return 0, fmt.Errorf("Failed to parse the number of vfs of %s on %s %v", pf, d.Node, err)
+	}
+	return numVfs, nil
+}
+
+// vfsScript prints, for each virtfn link of the pf device, the vf id, its pci
+// address, its driver and its netdevice, with - for the missing ones.
+const vfsScript = `cd "/sys/class/net/$1/device" || exit 1
+for vf in virtfn*; do
+	[ -e "$vf" ] || continue
+	driver=-
+	[ -e "$vf/driver" ] && driver=$(basename "$(readlink -f "$vf/driver")")
+	netdev=$(ls "$vf/net" 2>/dev/null | head -n 1)
+	echo "${vf#virtfn} $(basename "$(readlink -f "$vf")") $driver ${netdev:--}"
+done`
+
+// VFs returns the vfs of the pf, read from the virtfn links of its device.
+func (d *Debugger) VFs(pf string) ([]HostVF, error) {
+	out, err := d.Run("/bin/sh", "-c", vfsScript, "sh", pf)
+	if err != nil {
+		return nil, err
+	}
+	return ParseHostVFs(out)
+}
+
+// VFDriver returns the driver bound to the vf with the given pci address, empty
+// when none is.
+func (d *Debugger) VFDriver(pciAddress string) (string, error) {
+	out, err := d.Run("/bin/sh", "-c", `[ -e "$1/driver" ] && basename "$(readlink -f "$1/driver")" || true`,
+		"sh", path.Join("/sys/bus/pci/devices", pciAddress))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// ParseHostVFs parses the output of the vfs script, sorted by vf id.
+func ParseHostVFs(out string) ([]HostVF, error) {
+	res := []HostVF{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("Invalid vf line %q", line)
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid vf id in %q %v", line, err)
+		}
+		vf := HostVF{ID: id, PciAddress: fields[1], Driver: fields[2], Netdev: fields[3]}
+		if vf.Driver == "-" {
+			vf.Driver = ""
+		}
+		if vf.Netdev == "-" {
+			vf.Netdev = ""
+		}
+		res = append(res, vf)
+	}
+	// virtfn10 sorts before virtfn2 in the shell expansion
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+// debugPodName returns the name of the debug pod of the node. The names too long
+// for a label value are truncated and suffixed by a hash of the node, so the nodes
+// sharing a long prefix get different pods.
+func debugPodName(node string) string {
+	name := "debug-" + strings.Replace(node, ".", "-", -1)
+	if len(name) <= 63 {
+		return name
+	}
+	h := fnv.New32a()
+	h.Write([]byte(node))
+	suffix := fmt.Sprintf("-%08x", h.Sum32())
+	return strings.TrimRight(name[:63-len(suffix)], "-") + suffix
+}
+
+func deleteDebugPod(cs *testclient.ClientSet, name string) error {
+	err := cs.Pods(namespaces.Test).Delete(name, &metav1.DeleteOptions{GracePeriodSeconds: pointer.Int64Ptr(0)})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("Failed to delete the debug pod %s %v", name, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), debugStartTimeout)
+	defer cancel()
+	return wait.ForDeletion(ctx, wait.Pods(cs, namespaces.Test), name)
+}
@@ -0,0 +1,148 @@
+package pod
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+	"github.com/openshift/sriov-tests/pkg/util/namespaces"
+)
+
+var _ = Describe("ParseHostVFs", func() {
+	It("parses the vfs sorted by id", func() {
+		vfs, err := ParseHostVFs(`10 0000:3b:0b.2 iavf ens1f0v10
+0 0000:3b:02.0 iavf ens1f0v0
+1 0000:3b:02.1 vfio-pci -
+2 0000:3b:02.2 - -
+`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vfs).To(Equal([]HostVF{
+			{ID: 0, PciAddress: "0000:3b:02.0", Driver: "iavf", Netdev: "ens1f0v0"},
+			{ID: 1, PciAddress: "0000:3b:02.1", Driver: "vfio-pci"},
+			{ID: 2, PciAddress: "0000:3b:02.2"},
+			{ID: 10, PciAddress: "0000:3b:0b.2", Driver: "iavf", Netdev: "ens1f0v10"},
+		}))
+	})
+
+	It("returns no vfs when sriov is disabled", func() {
+		Expect(ParseHostVFs("")).To(BeEmpty())
+	})
+
+	It("rejects malformed lines", func() {
+		_, err := ParseHostVFs("0 0000:3b:02.0")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseHostVFs("x 0000:3b:02.0 iavf -")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("DebugOnNode", func() {
+	var (
+		cs   *testclient.ClientSet
+		stop func()
+	)
+
+	runWhenCreated := func(name string) {
+		go func() {
+			defer GinkgoRecover()
+			Eventually(func() error {
+				p, err := cs.Pods(namespaces.Test).Get(name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				p.Status.Phase = corev1.PodRunning
+				_, err = cs.Pods(namespaces.Test).UpdateStatus(p)
+				return err
+			}, 5*time.Second, 10*time.Millisecond).Should(Succeed())
+		}()
+	}
+
+	BeforeEach(func() {
+		var err error
+		cs, stop, err = testclient.NewFake()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	It("creates a privileged host pod pinned to the node", func() {
+		runWhenCreated("debug-worker-0-example-com")
+		d, err := DebugOnNode(cs, "worker-0.example.com")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(d.Node).To(Equal("worker-0.example.com"))
+
+		p := d.Pod
+		Expect(p.Status.Phase).To(Equal(corev1.PodRunning))
+		Expect(p.Labels).To(HaveKeyWithValue(DebugLabel, "true"))
+		Expect(p.Annotations).To(HaveKeyWithValue(DebugNodeAnnotation, "worker-0.example.com"))
+		Expect(p.Spec.NodeName).To(Equal("worker-0.example.com"))
+		Expect(p.Spec.HostNetwork).To(BeTrue())
+		Expect(p.Spec.HostPID).To(BeTrue())
+		Expect(*p.Spec.Containers[0].SecurityContext.Privileged).To(BeTrue())
+		Expect(p.Spec.Volumes[0].HostPath.Path).To(Equal("/"))
+		Expect(p.Spec.Containers[0].VolumeMounts[0].MountPath).To(Equal(HostRoot))
+	})
+
+	It("reuses the running pod and replaces the stopped one", func() {
+		running := Define(WithName("debug-worker-0"))
+		running.Status.Phase = corev1.PodRunning
+		stopped := Define(WithName("debug-worker-1"))
+		stopped.Status.Phase = corev1.PodFailed
+		cs, stop, err := testclient.NewFake(running, stopped)
+		Expect(err).ToNot(HaveOccurred())
+		defer stop()
+
+		d, err := DebugOnNode(cs, "worker-0")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(d.Pod.UID).ToNot(BeEmpty())
+		Expect(d.Pod.Spec.NodeName).To(BeEmpty(), "the existing pod is reused")
+
+		go func() {
+			defer GinkgoRecover()
+			Eventually(func() error {
+				p, err := cs.Pods(namespaces.Test).Get("debug-worker-1", metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				if p.Spec.NodeName == "" {
+					return k8serrors.NewNotFound(corev1.Resource("pods"), "debug-worker-1")
+				}
+				p.Status.Phase = corev1.PodRunning
+				_, err = cs.Pods(namespaces.Test).UpdateStatus(p)
+				return err
+			}, 5*time.Second, 10*time.Millisecond).Should(Succeed())
+		}()
+		d, err = DebugOnNode(cs, "worker-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(d.Pod.Spec.NodeName).To(Equal("worker-1"))
+
+		Expect(CleanupDebuggers(cs)).To(Succeed())
+		pods, err := cs.Pods(namespaces.Test).List(metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pods.Items).To(BeEmpty())
+	})
+})
+
+var _ = Describe("debugPodName", func() {
+	It("keeps the short names readable", func() {
+		Expect(debugPodName("worker-0.example.com")).To(Equal("debug-worker-0-example-com"))
+	})
+
+	It("gives different names to the long nodes sharing a prefix", func() {
+		prefix := "worker-with-a-very-long-name-in-a-cluster.with.a.long.domain.example.com"
+		first := debugPodName(prefix + "-0")
+		second := debugPodName(prefix + "-1")
+		Expect(len(first)).To(BeNumerically("<=", 63))
+		Expect(len(second)).To(BeNumerically("<=", 63))
+		Expect(first).ToNot(Equal(second))
+		Expect(first).To(HavePrefix("debug-worker-with-a-very-long-name"))
+	})
+})
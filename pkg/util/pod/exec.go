@@ -0,0 +1,180 @@
+package pod
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
+	utilexec "k8s.io/client-go/util/exec"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+)
+
+// streamCloseTimeout bounds the wait for the stream to end once its context expired.
+const streamCloseTimeout = 2 * time.Second
+
+// ExecOptions describes a command to be run in a pod.
+type ExecOptions struct {
+	// Command is the command to run, with its arguments.
+	Command []string
+	// Container is the container to run the command in. It defaults to the first one.
+	Container string
+	// Stdin, when set, is passed as the standard input of the command.
+	Stdin []byte
+	// TTY allocates a terminal. Stderr is merged into stdout when set.
+	TTY bool
+	// Timeout, when set, bounds the execution of the command.
+	Timeout time.Duration
+}
+
+// ExecResult is the outcome of a command run in a pod.
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Success tells if the command exited with a zero status.
+func (r *ExecResult) Success() bool {
+	return r.ExitCode == 0
+}
+
+// Exec runs the command in the pod. A non zero exit status of the command is
+// reported through the ExitCode of the result, and not as an error: the error
+// is returned only when the command could not be run or the context expired.
+func Exec(ctx context.Context, cs *testclient.ClientSet, pod *corev1.Pod, opts ExecOptions) (*ExecResult, error) {
+	container := opts.Container
+	if container == "" {
+		if len(pod.Spec.Containers) == 0 {
+			return nil, fmt.Errorf("Pod %s/%s has no containers", pod.Namespace, pod.Name)
+		}
+		container = pod.Spec.Containers[0].Name
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	req := cs.CoreV1Interface.RESTClient().
+		Post().
+		Namespace(pod.Namespace).
+		Resource("pods").
+		Name(pod.Name).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   opts.Command,
+			Stdin:     opts.Stdin != nil,
+			Stdout:    true,
+			Stderr:    !opts.TTY,
+			TTY:       opts.TTY,
+		}, scheme.ParameterCodec)
+
+	transport, upgrader, err := spdy.RoundTripperFor(cs.Config)
+	if err != nil {
+		return nil, err
+	}
+	conn := &cancelableUpgrader{Upgrader: upgrader}
+	exec, err := remotecommand.NewSPDYExecutorForTransports(transport, conn, "POST", req.URL())
+	if err != nil {
+		return nil, err
+	}
+
+	// the buffers are owned by the stream goroutine, which builds the result
+	done := make(chan execOutcome, 1)
+	go func() {
+		var stdout, stderr bytes.Buffer
+		streamOpts := remotecommand.StreamOptions{
+			Stdout: &stdout,
+			Tty:    opts.TTY,
+		}
+		if !opts.TTY {
+			streamOpts.Stderr = &stderr
+		}
+		if opts.Stdin != nil {
+			streamOpts.Stdin = bytes.NewReader(opts.Stdin)
+		}
+		err := exec.Stream(streamOpts)
+		res, err := execResult(stdout.String(), stderr.String(), err)
+		done <- execOutcome{res, err}
+	}()
+
+	select {
+	case outcome := <-done:
+		return outcome.res, outcome.err
+	case <-ctx.Done():
+	}
+
+	// the vendored executor does not take a context: closing the connection
+	// ends the stream. No connection exists yet while the upgrade is pending,
+	// so the stream is waited for a bounded time only, and its goroutine keeps
+	// the buffers it writes to.
+	conn.close()
+	select {
+	case <-done:
+	case <-time.After(streamCloseTimeout):
+	}
+	return nil, fmt.Errorf("Command %v in pod %s/%s did not complete %v", opts.Command, pod.Namespace, pod.Name, ctx.Err())
+}
+
+type execOutcome struct {
+	res *ExecResult
+	err error
+}
+
+// cancelableUpgrader keeps the connection of the stream so it can be closed
+// when the context expires. A connection created once closed is closed at once.
+type cancelableUpgrader struct {
+	spdy.Upgrader
+
+	mu     sync.Mutex
+	conn   httpstream.Connection
+	closed bool
+}
+
+func (u *cancelableUpgrader) NewConnection(resp *http.Response) (httpstream.Connection, error) {
+	conn, err := u.Upgrader.NewConnection(resp)
+	if err != nil {
+		return nil, err
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		conn.Close()
+		return nil, fmt.Errorf("The exec stream was canceled")
+	}
+	u.conn = conn
+	return conn, nil
+}
+
+func (u *cancelableUpgrader) close() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.closed = true
+	if u.conn != nil {
+		u.conn.Close()
+	}
+}
+
+// execResult builds the result of the command, decoding the exit status from the stream error.
+func execResult(stdout, stderr string, err error) (*ExecResult, error) {
+	res := &ExecResult{Stdout: stdout, Stderr: stderr}
+	if err == nil {
+		return res, nil
+	}
+	if exitErr, ok := err.(utilexec.ExitError); ok && exitErr.Exited() {
+		res.ExitCode = exitErr.ExitStatus()
+		return res, nil
+	}
+	return res, err
+}
@@ -0,0 +1,99 @@
+package pod
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	utilexec "k8s.io/client-go/util/exec"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+)
+
+var _ = Describe("execResult", func() {
+	It("reports a successful command", func() {
+		res, err := execResult("out", "", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Success()).To(BeTrue())
+		Expect(res.Stdout).To(Equal("out"))
+	})
+
+	It("decodes the exit status of the command", func() {
+		res, err := execResult("", "Device \"net2\" does not exist.", utilexec.CodeExitError{
+			Err:  errors.New("command terminated with exit code 1"),
+			Code: 1,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Success()).To(BeFalse())
+		Expect(res.ExitCode).To(Equal(1))
+		Expect(res.Stderr).To(ContainSubstring("does not exist"))
+	})
+
+	It("returns the errors unrelated to the command", func() {
+		_, err := execResult("", "", errors.New("connection refused"))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Exec", func() {
+	It("ends the stream when the timeout expires", func() {
+		// the server accepts the streams of the command and never ends them
+		closed := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(httpstream.HeaderProtocolVersion, "v4.channel.k8s.io")
+			conn := spdy.NewResponseUpgrader().UpgradeResponse(w, r, func(httpstream.Stream, <-chan struct{}) error {
+				return nil
+			})
+			if conn != nil {
+				<-conn.CloseChan()
+				close(closed)
+			}
+		}))
+		defer server.Close()
+
+		config := &rest.Config{Host: server.URL}
+		cs := &testclient.ClientSet{Config: config, CoreV1Interface: corev1client.NewForConfigOrDie(config)}
+		podObj := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "test"}}},
+		}
+
+		start := time.Now()
+		_, err := Exec(context.Background(), cs, podObj, ExecOptions{Command: []string{"sleep", "inf"}, Timeout: 500 * time.Millisecond})
+		Expect(err).To(MatchError(ContainSubstring("did not complete")))
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		Eventually(closed, 5*time.Second).Should(BeClosed())
+	})
+
+	It("returns when the upgrade is never answered", func() {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+
+		config := &rest.Config{Host: server.URL}
+		cs := &testclient.ClientSet{Config: config, CoreV1Interface: corev1client.NewForConfigOrDie(config)}
+		podObj := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "test"}}},
+		}
+
+		start := time.Now()
+		_, err := Exec(context.Background(), cs, podObj, ExecOptions{Command: []string{"true"}, Timeout: 200 * time.Millisecond})
+		Expect(err).To(MatchError(ContainSubstring("did not complete")))
+		Expect(time.Since(start)).To(BeNumerically("<", 200*time.Millisecond+streamCloseTimeout+time.Second))
+	})
+})
@@ -0,0 +1,118 @@
+package pod
+
+import (
+	"fmt"
+
+	"github.com/onsi/gomega/format"
+	"github.com/onsi/gomega/types"
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// HaveSriovAttachment succeeds if the pod is attached to the given network
+// through the vf with the given pci address. An empty pci address matches any device.
+// A bare network name refers to the namespace of the pod, when given the parsed
+// statuses it matches only the statuses reporting the bare name.
+func HaveSriovAttachment(network, pciAddress string) types.GomegaMatcher {
+	return &attachmentMatcher{
+		network:     network,
+		description: fmt.Sprintf("an attachment to %s through %s", network, pciAddress),
+		matchDevice: func(address string) bool {
+			return pciAddress == "" || address == pciAddress
+		},
+	}
+}
+
+// HaveSriovAttachmentFromPF succeeds if the pod is attached to the given network
+// through one of the vfs of the given pf.
+func HaveSriovAttachmentFromPF(network string, pf *sriovv1.InterfaceExt) types.GomegaMatcher {
+	return &attachmentMatcher{
+		network:     network,
+		description: fmt.Sprintf("an attachment to %s through a vf of %s (%s)", network, pf.Name, pf.PciAddress),
+		matchDevice: func(address string) bool {
+			for _, vf := range pf.VFs {
+				if vf.PciAddress == address {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+type attachmentMatcher struct {
+	network     string
+	namespace   string
+	description string
+	matchDevice func(pciAddress string) bool
+	statuses    []NetworkStatus
+}
+
+// Match accepts either a pod or the already parsed network statuses.
+func (m *attachmentMatcher) Match(actual interface{}) (bool, error) {
+	switch a := actual.(type) {
+	case *corev1.Pod:
+		statuses, err := NetworkStatuses(a)
+		if err != nil {
+			return false, err
+		}
+		m.statuses = statuses
+		m.namespace = a.Namespace
+	case corev1.Pod:
+		statuses, err := NetworkStatuses(&a)
+		if err != nil {
+			return false, err
+		}
+		m.statuses = statuses
+		m.namespace = a.Namespace
+	case []NetworkStatus:
+		m.statuses = a
+	default:
+		return false, fmt.Errorf("HaveSriovAttachment expects a pod or a []NetworkStatus, got %s", format.Object(actual, 1))
+	}
+
+	for _, s := range m.statuses {
+		if s.IsNetwork(m.network, m.namespace) && m.matchDevice(s.PciAddress()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *attachmentMatcher) FailureMessage(actual interface{}) string {
+	return format.Message(m.statuses, "to contain", m.description)
+}
+
+func (m *attachmentMatcher) NegatedFailureMessage(actual interface{}) string {
+	return format.Message(m.statuses, "not to contain", m.description)
+}
+
+// HaveRequestedAttachments succeeds if the pod network status reports all the
+// attachments of its networks annotation, with the requested interface, mac and ips.
+func HaveRequestedAttachments() types.GomegaMatcher {
+	return &requestedMatcher{}
+}
+
+type requestedMatcher struct {
+	err error
+}
+
+func (m *requestedMatcher) Match(actual interface{}) (bool, error) {
+	switch a := actual.(type) {
+	case *corev1.Pod:
+		m.err = CheckRequestedAttachments(a)
+	case corev1.Pod:
+		m.err = CheckRequestedAttachments(&a)
+	default:
+		return false, fmt.Errorf("HaveRequestedAttachments expects a pod, got %s", format.Object(actual, 1))
+	}
+	return m.err == nil, nil
+}
+
+func (m *requestedMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected the requested attachments to be reported: %v", m.err)
+}
+
+func (m *requestedMatcher) NegatedFailureMessage(actual interface{}) string {
+	return "Expected the requested attachments not to be all reported"
+}
@@ -0,0 +1,164 @@
+package pod
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// NetworkSelectionElement is an attachment requested to multus. The mac and the
+// ips are passed to the cni as runtime config, so the network must enable the
+// matching capabilities.
+type NetworkSelectionElement struct {
+	Name             string   `json:"name"`
+	Namespace        string   `json:"namespace,omitempty"`
+	InterfaceRequest string   `json:"interface,omitempty"`
+	MacRequest       string   `json:"mac,omitempty"`
+	IPRequest        []string `json:"ips,omitempty"`
+}
+
+// NetworkName returns the name multus reports the attachment with, namespace/name.
+// The namespace defaults to the one of the pod.
+func (e NetworkSelectionElement) NetworkName(podNamespace string) string {
+	namespace := e.Namespace
+	if namespace == "" {
+		namespace = podNamespace
+	}
+	return namespace + "/" + e.Name
+}
+
+// RequiredCapabilities returns the capabilities the network must enable to honor
+// the element.
+func (e NetworkSelectionElement) RequiredCapabilities() []string {
+	res := []string{}
+	if e.MacRequest != "" {
+		res = append(res, "mac")
+	}
+	if len(e.IPRequest) > 0 {
+		res = append(res, "ips")
+	}
+	return res
+}
+
+// ParseNetworkSelectionElements parses the networks annotation, either in its json
+// form or in the short namespace/name@interface one.
+func ParseNetworkSelectionElements(annotation string) ([]NetworkSelectionElement, error) {
+	annotation = strings.TrimSpace(annotation)
+	if annotation == "" {
+		return []NetworkSelectionElement{}, nil
+	}
+	if strings.HasPrefix(annotation, "[") {
+		res := []NetworkSelectionElement{}
+		if err := json.Unmarshal([]byte(annotation), &res); err != nil {
+			return nil, fmt.Errorf("Failed to parse the networks annotation %v", err)
+		}
+		return res, nil
+	}
+
+	res := []NetworkSelectionElement{}
+	for _, item := range strings.Split(annotation, ",") {
+		e := NetworkSelectionElement{Name: strings.TrimSpace(item)}
+		if idx := strings.Index(e.Name, "@"); idx >= 0 {
+			e.InterfaceRequest = e.Name[idx+1:]
+			e.Name = e.Name[:idx]
+		}
+		if idx := strings.Index(e.Name, "/"); idx >= 0 {
+			e.Namespace = e.Name[:idx]
+			e.Name = e.Name[idx+1:]
+		}
+		if e.Name == "" {
+			return nil, fmt.Errorf("Invalid network %q in the networks annotation", item)
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// RequestedNetworks returns the attachments requested by the pod.
+func RequestedNetworks(pod *corev1.Pod) ([]NetworkSelectionElement, error) {
+	return ParseNetworkSelectionElements(pod.Annotations[NetworksAnnotation])
+}
+
+// CheckCapabilities returns an error if the elements attached to the network
+// request a mac or ips the network does not enable through its capabilities.
+func CheckCapabilities(network *sriovv1.SriovNetwork, elements ...NetworkSelectionElement) error {
+	enabled := map[string]bool{}
+	if network.Spec.Capabilities != "" {
+		if err := json.Unmarshal([]byte(network.Spec.Capabilities), &enabled); err != nil {
+			return fmt.Errorf("Failed to parse the capabilities of %s %v", network.Name, err)
+		}
+	}
+	for _, e := range elements {
+		if e.Name != network.Name {
+			continue
+		}
+		for _, c := range e.RequiredCapabilities() {
+			if !enabled[c] {
+				return fmt.Errorf("Network %s does not enable the %s capability", network.Name, c)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckRequestedAttachments returns an error if an attachment requested by the pod
+// is not reported in its network status with the requested interface, mac and ips.
+// A network requested twice must be reported twice.
+func CheckRequestedAttachments(pod *corev1.Pod) error {
+	requested, err := RequestedNetworks(pod)
+	if err != nil {
+		return err
+	}
+	statuses, err := NetworkStatuses(pod)
+	if err != nil {
+		return err
+	}
+
+	used := make([]bool, len(statuses))
+	for _, e := range requested {
+		name := e.NetworkName(pod.Namespace)
+		var found *NetworkStatus
+		for i := range statuses {
+			s := statuses[i]
+			if used[i] || !s.IsNetwork(name, pod.Namespace) {
+				continue
+			}
+			if e.InterfaceRequest != "" && s.Interface != e.InterfaceRequest {
+				continue
+			}
+			used[i] = true
+			found = &statuses[i]
+			break
+		}
+		if found == nil {
+			return fmt.Errorf("Attachment to %s (interface %q) not found in pod %s/%s", name, e.InterfaceRequest, pod.Namespace, pod.Name)
+		}
+		if e.MacRequest != "" && !strings.EqualFold(e.MacRequest, found.Mac) {
+			return fmt.Errorf("Attachment %s of pod %s/%s has mac %s, requested %s", found.Interface, pod.Namespace, pod.Name, found.Mac, e.MacRequest)
+		}
+		for _, ip := range e.IPRequest {
+			if !containsIP(found.IPs, ip) {
+				return fmt.Errorf("Attachment %s of pod %s/%s has ips %v, requested %s", found.Interface, pod.Namespace, pod.Name, found.IPs, ip)
+			}
+		}
+	}
+	return nil
+}
+
+// containsIP tells if the ips hold the requested one, ignoring its prefix length.
+func containsIP(ips []string, requested string) bool {
+	ip, _, err := net.ParseCIDR(requested)
+	if err != nil {
+		ip = net.ParseIP(requested)
+	}
+	for _, i := range ips {
+		if ip != nil && ip.Equal(net.ParseIP(i)) || i == requested {
+			return true
+		}
+	}
+	return false
+}
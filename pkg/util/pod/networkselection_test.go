@@ -0,0 +1,92 @@
+package pod
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const twiceAttached = `[{
+    "name": "openshift-sdn",
+    "interface": "eth0",
+    "ips": ["10.128.2.14"],
+    "default": true
+},{
+    "name": "sriov-testing/sriovnet",
+    "interface": "sriov1",
+    "ips": ["10.10.10.2"],
+    "mac": "20:04:0F:F1:88:01"
+},{
+    "name": "sriov-testing/sriovnet",
+    "interface": "sriov2",
+    "ips": ["10.10.10.3"],
+    "mac": "20:04:0f:f1:88:02"
+}]`
+
+var _ = Describe("NetworkSelectionElement", func() {
+	DescribeTable("parses the networks annotation",
+		func(annotation string, expected []NetworkSelectionElement) {
+			elements, err := ParseNetworkSelectionElements(annotation)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(elements).To(Equal(expected))
+		},
+		Entry("empty", "", []NetworkSelectionElement{}),
+		Entry("in the short form", "net1, other/net2@sriov0",
+			[]NetworkSelectionElement{{Name: "net1"}, {Name: "net2", Namespace: "other", InterfaceRequest: "sriov0"}}),
+		Entry("in the json form", `[{"name":"net","mac":"20:04:0f:f1:88:01","ips":["10.10.10.2/24"]}]`,
+			[]NetworkSelectionElement{{Name: "net", MacRequest: "20:04:0f:f1:88:01", IPRequest: []string{"10.10.10.2/24"}}}),
+	)
+
+	It("rejects invalid annotations", func() {
+		_, err := ParseNetworkSelectionElements("ns/")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseNetworkSelectionElements("[{")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("checks the capabilities of the network", func() {
+		network := &sriovv1.SriovNetwork{ObjectMeta: metav1.ObjectMeta{Name: "net"}, Spec: sriovv1.SriovNetworkSpec{Capabilities: `{"mac": true}`}}
+		Expect(CheckCapabilities(network, NetworkSelectionElement{Name: "net", MacRequest: "20:04:0f:f1:88:01"})).To(Succeed())
+		Expect(CheckCapabilities(network, NetworkSelectionElement{Name: "other", IPRequest: []string{"10.10.10.2/24"}})).To(Succeed())
+		Expect(CheckCapabilities(network, NetworkSelectionElement{Name: "net", IPRequest: []string{"10.10.10.2/24"}})).
+			To(MatchError("Network net does not enable the ips capability"))
+	})
+})
+
+var _ = Describe("HaveRequestedAttachments", func() {
+	podWith := func(elements ...NetworkSelectionElement) *corev1.Pod {
+		p := Define(WithNamespace("sriov-testing"), WithNetworks(elements...))
+		p.Annotations[NetworkStatusAnnotation] = twiceAttached
+		return p
+	}
+
+	It("matches a network attached twice with the requested interfaces, macs and ips", func() {
+		p := podWith(
+			NetworkSelectionElement{Name: "sriovnet", InterfaceRequest: "sriov1", MacRequest: "20:04:0f:f1:88:01", IPRequest: []string{"10.10.10.2/24"}},
+			NetworkSelectionElement{Name: "sriovnet", Namespace: "sriov-testing", MacRequest: "20:04:0f:f1:88:02", IPRequest: []string{"10.10.10.3"}},
+		)
+		Expect(p).To(HaveRequestedAttachments())
+	})
+
+	DescribeTable("fails when a request is not honored",
+		func(element NetworkSelectionElement, expectedErr string) {
+			err := CheckRequestedAttachments(podWith(element))
+			Expect(err).To(MatchError(ContainSubstring(expectedErr)))
+			Expect(podWith(element)).ToNot(HaveRequestedAttachments())
+		},
+		Entry("with another interface", NetworkSelectionElement{Name: "sriovnet", InterfaceRequest: "net1"}, `Attachment to sriov-testing/sriovnet (interface "net1") not found`),
+		Entry("with another namespace", NetworkSelectionElement{Name: "sriovnet", Namespace: "default"}, "Attachment to default/sriovnet"),
+		Entry("with another mac", NetworkSelectionElement{Name: "sriovnet", InterfaceRequest: "sriov1", MacRequest: "20:04:0f:f1:88:09"}, "has mac 20:04:0F:F1:88:01"),
+		Entry("with another ip", NetworkSelectionElement{Name: "sriovnet", InterfaceRequest: "sriov2", IPRequest: []string{"10.10.10.9/24"}}, "has ips [10.10.10.3]"),
+	)
+
+	It("fails when the network is requested more times than attached", func() {
+		element := NetworkSelectionElement{Name: "sriovnet"}
+		Expect(podWith(element, element)).To(HaveRequestedAttachments())
+		Expect(podWith(element, element, element)).ToNot(HaveRequestedAttachments())
+	})
+})
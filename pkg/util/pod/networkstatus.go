@@ -0,0 +1,98 @@
+package pod
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// NetworkStatusAnnotation is the annotation multus reports the pod attachments in.
+	NetworkStatusAnnotation = "k8s.v1.cni.cncf.io/network-status"
+	// OldNetworkStatusAnnotation is the annotation used by the older multus releases.
+	OldNetworkStatusAnnotation = "k8s.v1.cni.cncf.io/networks-status"
+)
+
+// NetworkStatus is an attachment of the pod, as reported by multus.
+type NetworkStatus struct {
+	Name       string      `json:"name"`
+	Interface  string      `json:"interface,omitempty"`
+	IPs        []string    `json:"ips,omitempty"`
+	Mac        string      `json:"mac,omitempty"`
+	Default    bool        `json:"default,omitempty"`
+	DeviceInfo *DeviceInfo `json:"device-info,omitempty"`
+}
+
+// DeviceInfo describes the device backing the attachment.
+type DeviceInfo struct {
+	Type    string     `json:"type"`
+	Version string     `json:"version"`
+	Pci     *PciDevice `json:"pci,omitempty"`
+}
+
+// PciDevice is the pci device info of an attachment.
+type PciDevice struct {
+	PciAddress   string `json:"pci-address"`
+	RdmaDevice   string `json:"rdma-device,omitempty"`
+	PfPciAddress string `json:"pf-pci-address,omitempty"`
+}
+
+// PciAddress returns the pci address of the device backing the attachment,
+// or an empty string if the device info is not reported.
+func (s NetworkStatus) PciAddress() string {
+	if s.DeviceInfo == nil || s.DeviceInfo.Pci == nil {
+		return ""
+	}
+	return s.DeviceInfo.Pci.PciAddress
+}
+
+// IsNetwork tells if the attachment is of the given network. The network can be
+// referenced either by name or by namespace/name, a bare name referring to the
+// given namespace, i.e. the one of the pod. The legacy statuses reporting only
+// the name are resolved the same way.
+func (s NetworkStatus) IsNetwork(network, namespace string) bool {
+	return qualifiedNetwork(s.Name, namespace) == qualifiedNetwork(network, namespace)
+}
+
+func qualifiedNetwork(network, namespace string) string {
+	if strings.Contains(network, "/") {
+		return network
+	}
+	return namespace + "/" + network
+}
+
+// NetworkStatuses parses the network status annotation of the pod, falling back
+// to the legacy one when the current is not set.
+func NetworkStatuses(pod *corev1.Pod) ([]NetworkStatus, error) {
+	value, ok := pod.Annotations[NetworkStatusAnnotation]
+	if !ok {
+		value, ok = pod.Annotations[OldNetworkStatusAnnotation]
+	}
+	if !ok {
+		return nil, fmt.Errorf("Pod %s/%s has no network status annotation", pod.Namespace, pod.Name)
+	}
+
+	res := []NetworkStatus{}
+	err := json.Unmarshal([]byte(value), &res)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse the network status of pod %s/%s %v", pod.Namespace, pod.Name, err)
+	}
+	return res, nil
+}
+
+// FindNetworkStatus returns the status of the first attachment of the pod to the given
+// network, a bare network name referring to the namespace of the pod.
+func FindNetworkStatus(pod *corev1.Pod, network string) (*NetworkStatus, error) {
+	statuses, err := NetworkStatuses(pod)
+	if err != nil {
+		return nil, err
+	}
+	for i := range statuses {
+		if statuses[i].IsNetwork(network, pod.Namespace) {
+			return &statuses[i], nil
+		}
+	}
+	return nil, fmt.Errorf("Network %s not found in pod %s/%s", network, pod.Namespace, pod.Name)
+}
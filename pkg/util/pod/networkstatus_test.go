@@ -0,0 +1,148 @@
+package pod
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const networkStatus = `[{
+    "name": "openshift-sdn",
+    "interface": "eth0",
+    "ips": ["10.128.2.14"],
+    "default": true,
+    "dns": {}
+},{
+    "name": "sriov-testing/sriovnet",
+    "interface": "net1",
+    "ips": ["10.10.10.171"],
+    "mac": "36:7c:7a:c4:59:3e",
+    "dns": {},
+    "device-info": {
+        "type": "pci",
+        "version": "1.0.0",
+        "pci": {
+            "pci-address": "0000:3b:02.1"
+        }
+    }
+}]`
+
+const legacyNetworkStatus = `[{
+    "name": "openshift-sdn",
+    "interface": "eth0",
+    "ips": ["10.128.2.14"],
+    "default": true
+},{
+    "name": "sriovnet",
+    "interface": "net1",
+    "ips": ["10.10.10.171"],
+    "mac": "36:7c:7a:c4:59:3e"
+}]`
+
+func podWithAnnotation(annotation, value string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "testpod",
+			Namespace:   "sriov-testing",
+			Annotations: map[string]string{annotation: value},
+		},
+	}
+}
+
+var _ = Describe("NetworkStatuses", func() {
+	It("parses the network status annotation", func() {
+		statuses, err := NetworkStatuses(podWithAnnotation(NetworkStatusAnnotation, networkStatus))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(statuses).To(HaveLen(2))
+		Expect(statuses[0].Default).To(BeTrue())
+		Expect(statuses[0].PciAddress()).To(Equal(""))
+		Expect(statuses[1]).To(Equal(NetworkStatus{
+			Name:      "sriov-testing/sriovnet",
+			Interface: "net1",
+			IPs:       []string{"10.10.10.171"},
+			Mac:       "36:7c:7a:c4:59:3e",
+			DeviceInfo: &DeviceInfo{
+				Type:    "pci",
+				Version: "1.0.0",
+				Pci:     &PciDevice{PciAddress: "0000:3b:02.1"},
+			},
+		}))
+	})
+
+	It("falls back to the legacy annotation", func() {
+		s, err := FindNetworkStatus(podWithAnnotation(OldNetworkStatusAnnotation, legacyNetworkStatus), "sriovnet")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s.Interface).To(Equal("net1"))
+		Expect(s.DeviceInfo).To(BeNil())
+	})
+
+	It("fails when the annotation is missing or invalid", func() {
+		_, err := NetworkStatuses(&corev1.Pod{})
+		Expect(err).To(HaveOccurred())
+		_, err = NetworkStatuses(podWithAnnotation(NetworkStatusAnnotation, "{"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("matches the network by name or by namespace/name", func() {
+		s := NetworkStatus{Name: "sriov-testing/sriovnet"}
+		Expect(s.IsNetwork("sriovnet", "sriov-testing")).To(BeTrue())
+		Expect(s.IsNetwork("sriov-testing/sriovnet", "sriov-testing")).To(BeTrue())
+		Expect(s.IsNetwork("other/sriovnet", "sriov-testing")).To(BeFalse())
+		Expect(s.IsNetwork("net", "sriov-testing")).To(BeFalse())
+	})
+
+	It("resolves the bare names in the given namespace", func() {
+		s := NetworkStatus{Name: "other/sriovnet"}
+		Expect(s.IsNetwork("sriovnet", "sriov-testing")).To(BeFalse())
+		Expect(s.IsNetwork("sriovnet", "other")).To(BeTrue())
+
+		legacy := NetworkStatus{Name: "sriovnet"}
+		Expect(legacy.IsNetwork("sriovnet", "sriov-testing")).To(BeTrue())
+		Expect(legacy.IsNetwork("sriov-testing/sriovnet", "sriov-testing")).To(BeTrue())
+		Expect(legacy.IsNetwork("other/sriovnet", "sriov-testing")).To(BeFalse())
+	})
+})
+
+var _ = Describe("Attachment matchers", func() {
+	pod := podWithAnnotation(NetworkStatusAnnotation, networkStatus)
+
+	It("matches the attachment by pci address", func() {
+		Expect(pod).To(HaveSriovAttachment("sriovnet", "0000:3b:02.1"))
+		Expect(pod).To(HaveSriovAttachment("sriovnet", ""))
+		Expect(pod).ToNot(HaveSriovAttachment("sriovnet", "0000:3b:02.2"))
+		Expect(pod).ToNot(HaveSriovAttachment("othernet", ""))
+	})
+
+	It("matches the attachment by pf", func() {
+		pf := &sriovv1.InterfaceExt{
+			InterfaceProperty: sriovv1.InterfaceProperty{Name: "ens1f0", PciAddress: "0000:3b:00.0"},
+			VFs: []sriovv1.VirtualFunction{
+				{InterfaceProperty: sriovv1.InterfaceProperty{PciAddress: "0000:3b:02.0"}, VfID: 0},
+				{InterfaceProperty: sriovv1.InterfaceProperty{PciAddress: "0000:3b:02.1"}, VfID: 1},
+			},
+		}
+		otherPf := &sriovv1.InterfaceExt{
+			InterfaceProperty: sriovv1.InterfaceProperty{Name: "ens1f1", PciAddress: "0000:3b:00.1"},
+			VFs: []sriovv1.VirtualFunction{
+				{InterfaceProperty: sriovv1.InterfaceProperty{PciAddress: "0000:3b:0a.0"}, VfID: 0},
+			},
+		}
+		Expect(pod).To(HaveSriovAttachmentFromPF("sriovnet", pf))
+		Expect(pod).ToNot(HaveSriovAttachmentFromPF("sriovnet", otherPf))
+	})
+
+	It("does not match a bare name to the networks of other namespaces", func() {
+		other := pod.DeepCopy()
+		other.Namespace = "other"
+		Expect(other).ToNot(HaveSriovAttachment("sriovnet", ""))
+		Expect(other).To(HaveSriovAttachment("sriov-testing/sriovnet", ""))
+	})
+
+	It("fails on pods without the annotation", func() {
+		_, err := HaveSriovAttachment("sriovnet", "").Match(&corev1.Pod{})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,94 @@
+package render
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+)
+
+// defaultPolicy is the name of the policy created by the operator, which is
+// never applied to the node states.
+const defaultPolicy = "default"
+
+// NodeState computes the interfaces the operator is expected to write in the
+// spec of the given node state, applying the policies selecting the node in the
+// same order the operator does.
+func NodeState(node *corev1.Node, state *sriovv1.SriovNetworkNodeState, policies []sriovv1.SriovNetworkNodePolicy) sriovv1.Interfaces {
+	// The operator leaves the node state untouched until the config daemon
+	// reports the interfaces of the node.
+	if len(state.Status.Interfaces) == 0 {
+		return state.Spec.Interfaces
+	}
+
+	rendered := &sriovv1.SriovNetworkNodeState{}
+	rendered.Status = *state.Status.DeepCopy()
+
+	sorted := make([]sriovv1.SriovNetworkNodePolicy, len(policies))
+	for i := range policies {
+		sorted[i] = *policies[i].DeepCopy()
+	}
+	sort.Sort(sriovv1.ByPriority(sorted))
+
+	for _, p := range sorted {
+		if p.Name == defaultPolicy {
+			continue
+		}
+		// Apply dereferences the vf group, which is only generated for
+		// policies requesting at least one vf.
+		if p.Spec.NumVfs <= 0 {
+			continue
+		}
+		if p.Selected(node) {
+			p.Apply(rendered)
+		}
+	}
+	return rendered.Spec.Interfaces
+}
+
+// NodeStates computes the expected interfaces for each of the given nodes, keyed by node name.
+// Nodes without a matching node state are not part of the result.
+func NodeStates(nodes []corev1.Node, states []sriovv1.SriovNetworkNodeState, policies []sriovv1.SriovNetworkNodePolicy) map[string]sriovv1.Interfaces {
+	statesByName := make(map[string]*sriovv1.SriovNetworkNodeState)
+	for i := range states {
+		statesByName[states[i].Name] = &states[i]
+	}
+
+	res := make(map[string]sriovv1.Interfaces)
+	for i := range nodes {
+		state, ok := statesByName[nodes[i].Name]
+		if !ok {
+			continue
+		}
+		res[nodes[i].Name] = NodeState(&nodes[i], state, policies)
+	}
+	return res
+}
+
+// FromCluster fetches the nodes, node states and policies from the cluster and
+// computes the expected interfaces for each node state.
+func FromCluster(clients *testclient.ClientSet, operatorNamespace string) (map[string]sriovv1.Interfaces, error) {
+	nodes, err := clients.Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list nodes %v", err)
+	}
+
+	states, err := clients.SriovNetworkNodeStates(operatorNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list node states %v", err)
+	}
+
+	policies := sriovv1.SriovNetworkNodePolicyList{}
+	err = clients.List(context.Background(), &policies, runtimeclient.InNamespace(operatorNamespace))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list policies %v", err)
+	}
+
+	return NodeStates(nodes.Items, states.Items, policies.Items), nil
+}
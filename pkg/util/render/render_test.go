@@ -0,0 +1,159 @@
+package render
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testNode(name string) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				"kubernetes.io/hostname": name,
+				"sriov":                  "true",
+			},
+		},
+	}
+}
+
+func testState(name string) sriovv1.SriovNetworkNodeState {
+	return sriovv1.SriovNetworkNodeState{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: sriovv1.SriovNetworkNodeStateStatus{
+			Interfaces: sriovv1.InterfaceExts{
+				{
+					InterfaceProperty: sriovv1.InterfaceProperty{
+						Name:       "ens1f0",
+						PciAddress: "0000:3b:00.0",
+						Driver:     "mlx5_core",
+						Vendor:     "15b3",
+						DeviceID:   "1015",
+					},
+					TotalVfs: 8,
+				},
+				{
+					InterfaceProperty: sriovv1.InterfaceProperty{
+						Name:       "ens1f1",
+						PciAddress: "0000:3b:00.1",
+						Driver:     "mlx5_core",
+						Vendor:     "15b3",
+						DeviceID:   "1015",
+					},
+					TotalVfs: 8,
+				},
+			},
+		},
+	}
+}
+
+func testPolicy(name string, priority, numVfs int, pfNames ...string) sriovv1.SriovNetworkNodePolicy {
+	return sriovv1.SriovNetworkNodePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: sriovv1.SriovNetworkNodePolicySpec{
+			NodeSelector: map[string]string{"sriov": "true"},
+			ResourceName: name,
+			Priority:     priority,
+			NumVfs:       numVfs,
+			DeviceType:   "netdevice",
+			NicSelector: sriovv1.SriovNetworkNicSelector{
+				PfNames: pfNames,
+			},
+		},
+	}
+}
+
+var _ = Describe("NodeState", func() {
+	node := testNode("worker-0")
+
+	DescribeTable("should render the interfaces",
+		func(policies []sriovv1.SriovNetworkNodePolicy, expected sriovv1.Interfaces) {
+			state := testState("worker-0")
+			Expect(NodeState(&node, &state, policies)).To(Equal(expected))
+		},
+		Entry("with a single policy", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("res1", 99, 5, "ens1f0"),
+		}, sriovv1.Interfaces{
+			{
+				Name: "ens1f0", PciAddress: "0000:3b:00.0", NumVfs: 5,
+				VfGroups: []sriovv1.VfGroup{{ResourceName: "res1", DeviceType: "netdevice", VfRange: "0-4"}},
+			},
+		}),
+		Entry("with partitioned vfs", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("res1", 99, 5, "ens1f0#2-4"),
+			testPolicy("res2", 99, 5, "ens1f0#0-1"),
+		}, sriovv1.Interfaces{
+			{
+				Name: "ens1f0", PciAddress: "0000:3b:00.0", NumVfs: 5,
+				VfGroups: []sriovv1.VfGroup{
+					{ResourceName: "res1", DeviceType: "netdevice", VfRange: "2-4"},
+					{ResourceName: "res2", DeviceType: "netdevice", VfRange: "0-1"},
+				},
+			},
+		}),
+		Entry("with the higher priority policy applied last", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("res1", 10, 4, "ens1f0"),
+			testPolicy("res2", 99, 6, "ens1f0"),
+		}, sriovv1.Interfaces{
+			{
+				Name: "ens1f0", PciAddress: "0000:3b:00.0", NumVfs: 4,
+				VfGroups: []sriovv1.VfGroup{
+					{ResourceName: "res2", DeviceType: "netdevice", VfRange: "0-5"},
+					{ResourceName: "res1", DeviceType: "netdevice", VfRange: "0-3"},
+				},
+			},
+		}),
+		Entry("ignoring the default policy", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("default", 99, 5, "ens1f0"),
+		}, nil),
+		Entry("ignoring policies without vfs", []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("res1", 99, 0, "ens1f0"),
+		}, nil),
+	)
+
+	It("should not apply policies not selecting the node", func() {
+		state := testState("worker-0")
+		policy := testPolicy("res1", 99, 5, "ens1f0")
+		policy.Spec.NodeSelector = map[string]string{"kubernetes.io/hostname": "worker-1"}
+		Expect(NodeState(&node, &state, []sriovv1.SriovNetworkNodePolicy{policy})).To(BeEmpty())
+	})
+
+	It("should leave node states without interfaces untouched", func() {
+		state := testState("worker-0")
+		state.Status.Interfaces = nil
+		state.Spec.Interfaces = sriovv1.Interfaces{{Name: "ens1f0", PciAddress: "0000:3b:00.0", NumVfs: 3}}
+		Expect(NodeState(&node, &state, []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("res1", 99, 5, "ens1f0"),
+		})).To(Equal(state.Spec.Interfaces))
+	})
+
+	It("should not mutate the given state and policies", func() {
+		state := testState("worker-0")
+		policies := []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("res2", 99, 5, "ens1f0"),
+			testPolicy("res1", 10, 5, "ens1f0"),
+		}
+		NodeState(&node, &state, policies)
+		Expect(state.Spec.Interfaces).To(BeEmpty())
+		Expect(policies[0].Name).To(Equal("res2"))
+	})
+})
+
+var _ = Describe("NodeStates", func() {
+	It("should render only the nodes with a node state", func() {
+		nodes := []corev1.Node{testNode("worker-0"), testNode("worker-1")}
+		states := []sriovv1.SriovNetworkNodeState{testState("worker-0")}
+		res := NodeStates(nodes, states, []sriovv1.SriovNetworkNodePolicy{
+			testPolicy("res1", 99, 5, "ens1f1"),
+		})
+		Expect(res).To(HaveLen(1))
+		Expect(res).To(HaveKey("worker-0"))
+		Expect(res["worker-0"]).To(HaveLen(1))
+		Expect(res["worker-0"][0].Name).To(Equal("ens1f1"))
+	})
+})
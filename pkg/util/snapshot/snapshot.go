@@ -0,0 +1,331 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	netattdefv1 "github.com/openshift/sriov-network-operator/pkg/apis/k8s/v1"
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/retry"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+)
+
+// Snapshot is the SR-IOV configuration of the cluster at a given time.
+type Snapshot struct {
+	OperatorNamespace string `json:"operatorNamespace"`
+	// OperatorConfig is the "default" operator config, nil if it did not exist.
+	OperatorConfig               *sriovv1.SriovOperatorConfig              `json:"operatorConfig,omitempty"`
+	Policies                     []sriovv1.SriovNetworkNodePolicy          `json:"policies"`
+	Networks                     []sriovv1.SriovNetwork                    `json:"networks"`
+	NetworkAttachmentDefinitions []netattdefv1.NetworkAttachmentDefinition `json:"networkAttachmentDefinitions"`
+	// NodeLabels holds, for each node, the value of the tracked labels.
+	// A nil value means the label was not set.
+	NodeLabels map[string]map[string]*string `json:"nodeLabels"`
+}
+
+// Take captures the SR-IOV configuration of the cluster, together with the
+// given labels of all the nodes.
+func Take(clients *testclient.ClientSet, operatorNamespace string, nodeLabels ...string) (*Snapshot, error) {
+	res := &Snapshot{
+		OperatorNamespace: operatorNamespace,
+		NodeLabels:        map[string]map[string]*string{},
+	}
+
+	config := &sriovv1.SriovOperatorConfig{}
+	err := clients.Get(context.Background(), runtimeclient.ObjectKey{Name: "default", Namespace: operatorNamespace}, config)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("Failed to get the operator config %v", err)
+	}
+	if err == nil {
+		res.OperatorConfig = config
+	}
+
+	policies := sriovv1.SriovNetworkNodePolicyList{}
+	err = clients.List(context.Background(), &policies, runtimeclient.InNamespace(operatorNamespace))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list policies %v", err)
+	}
+	res.Policies = policies.Items
+
+	networks := sriovv1.SriovNetworkList{}
+	err = clients.List(context.Background(), &networks, runtimeclient.InNamespace(operatorNamespace))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list networks %v", err)
+	}
+	res.Networks = networks.Items
+
+	// only the definitions generated from the networks are captured
+	for _, n := range networks.Items {
+		netAttDef := netattdefv1.NetworkAttachmentDefinition{}
+		err := clients.Get(context.Background(), runtimeclient.ObjectKey{Name: n.Name, Namespace: networkNamespace(n)}, &netAttDef)
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get network attachment definition %s %v", n.Name, err)
+		}
+		res.NetworkAttachmentDefinitions = append(res.NetworkAttachmentDefinitions, netAttDef)
+	}
+
+	if len(nodeLabels) > 0 {
+		nodes, err := clients.Nodes().List(metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list nodes %v", err)
+		}
+		for _, n := range nodes.Items {
+			labels := map[string]*string{}
+			for _, l := range nodeLabels {
+				if value, ok := n.Labels[l]; ok {
+					labels[l] = &value
+				} else {
+					labels[l] = nil
+				}
+			}
+			res.NodeLabels[n.Name] = labels
+		}
+	}
+	return res, nil
+}
+
+// Restore brings the cluster back to the captured configuration: policies and
+// networks created after the snapshot are deleted, the ones removed or changed
+// are recreated or updated.
+func (s *Snapshot) Restore(clients *testclient.ClientSet) error {
+	err := s.restoreNodeLabels(clients)
+	if err != nil {
+		return err
+	}
+	err = s.restoreOperatorConfig(clients)
+	if err != nil {
+		return err
+	}
+	err = s.restoreNetworks(clients)
+	if err != nil {
+		return err
+	}
+	err = s.restoreNetAttDefs(clients)
+	if err != nil {
+		return err
+	}
+	return s.restorePolicies(clients)
+}
+
+// WriteFile writes the snapshot to the given path as yaml.
+func (s *Snapshot) WriteFile(path string) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// ReadFile loads a snapshot written by WriteFile.
+func ReadFile(path string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	res := &Snapshot{}
+	err = yaml.Unmarshal(data, res)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode snapshot %s %v", path, err)
+	}
+	return res, nil
+}
+
+func (s *Snapshot) restoreNodeLabels(clients *testclient.ClientSet) error {
+	for nodeName, labels := range s.NodeLabels {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			node, err := clients.Nodes().Get(nodeName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			changed := false
+			for key, value := range labels {
+				current, ok := node.Labels[key]
+				switch {
+				case value == nil && ok:
+					delete(node.Labels, key)
+					changed = true
+				case value != nil && (!ok || current != *value):
+					if node.Labels == nil {
+						node.Labels = map[string]string{}
+					}
+					node.Labels[key] = *value
+					changed = true
+				}
+			}
+			if !changed {
+				return nil
+			}
+			_, err = clients.Nodes().Update(node)
+			return err
+		})
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to restore the labels of node %s %v", nodeName, err)
+		}
+	}
+	return nil
+}
+
+func (s *Snapshot) restoreOperatorConfig(clients *testclient.ClientSet) error {
+	if s.OperatorConfig == nil {
+		return nil
+	}
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		config := &sriovv1.SriovOperatorConfig{}
+		err := clients.Get(context.Background(), runtimeclient.ObjectKey{Name: s.OperatorConfig.Name, Namespace: s.OperatorNamespace}, config)
+		if k8serrors.IsNotFound(err) {
+			return clients.Create(context.Background(), fresh(s.OperatorConfig.DeepCopy()))
+		}
+		if err != nil {
+			return err
+		}
+		if equality.Semantic.DeepEqual(config.Spec, s.OperatorConfig.Spec) {
+			return nil
+		}
+		config.Spec = *s.OperatorConfig.Spec.DeepCopy()
+		return clients.Update(context.Background(), config)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to restore the operator config %v", err)
+	}
+	return nil
+}
+
+func (s *Snapshot) restorePolicies(clients *testclient.ClientSet) error {
+	current := sriovv1.SriovNetworkNodePolicyList{}
+	err := clients.List(context.Background(), &current, runtimeclient.InNamespace(s.OperatorNamespace))
+	if err != nil {
+		return fmt.Errorf("Failed to list policies %v", err)
+	}
+
+	expected := map[string]*sriovv1.SriovNetworkNodePolicy{}
+	for i := range s.Policies {
+		expected[s.Policies[i].Name] = &s.Policies[i]
+	}
+	for i := range current.Items {
+		p := &current.Items[i]
+		want, ok := expected[p.Name]
+		if !ok {
+			err := clients.Delete(context.Background(), p)
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return fmt.Errorf("Failed to delete policy %s %v", p.Name, err)
+			}
+			continue
+		}
+		delete(expected, p.Name)
+		if equality.Semantic.DeepEqual(p.Spec, want.Spec) && equality.Semantic.DeepEqual(p.Labels, want.Labels) {
+			continue
+		}
+		p.Spec = *want.Spec.DeepCopy()
+		p.Labels = want.Labels
+		err := clients.Update(context.Background(), p)
+		if err != nil {
+			return fmt.Errorf("Failed to update policy %s %v", p.Name, err)
+		}
+	}
+	for _, p := range expected {
+		err := clients.Create(context.Background(), fresh(p.DeepCopy()))
+		if err != nil {
+			return fmt.Errorf("Failed to create policy %s %v", p.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *Snapshot) restoreNetworks(clients *testclient.ClientSet) error {
+	current := sriovv1.SriovNetworkList{}
+	err := clients.List(context.Background(), &current, runtimeclient.InNamespace(s.OperatorNamespace))
+	if err != nil {
+		return fmt.Errorf("Failed to list networks %v", err)
+	}
+
+	expected := map[string]*sriovv1.SriovNetwork{}
+	for i := range s.Networks {
+		expected[s.Networks[i].Name] = &s.Networks[i]
+	}
+	for i := range current.Items {
+		n := &current.Items[i]
+		want, ok := expected[n.Name]
+		if !ok {
+			err := clients.Delete(context.Background(), n)
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return fmt.Errorf("Failed to delete network %s %v", n.Name, err)
+			}
+			continue
+		}
+		delete(expected, n.Name)
+		if equality.Semantic.DeepEqual(n.Spec, want.Spec) && equality.Semantic.DeepEqual(n.Labels, want.Labels) {
+			continue
+		}
+		n.Spec = *want.Spec.DeepCopy()
+		n.Labels = want.Labels
+		err := clients.Update(context.Background(), n)
+		if err != nil {
+			return fmt.Errorf("Failed to update network %s %v", n.Name, err)
+		}
+	}
+	for _, n := range expected {
+		err := clients.Create(context.Background(), fresh(n.DeepCopy()))
+		if err != nil {
+			return fmt.Errorf("Failed to create network %s %v", n.Name, err)
+		}
+	}
+	return nil
+}
+
+// restoreNetAttDefs recreates the definitions removed by hand. The ones of the
+// restored networks are regenerated by the operator.
+func (s *Snapshot) restoreNetAttDefs(clients *testclient.ClientSet) error {
+	for i := range s.NetworkAttachmentDefinitions {
+		want := &s.NetworkAttachmentDefinitions[i]
+		current := &netattdefv1.NetworkAttachmentDefinition{}
+		err := clients.Get(context.Background(), runtimeclient.ObjectKey{Name: want.Name, Namespace: want.Namespace}, current)
+		if k8serrors.IsNotFound(err) {
+			err = clients.Create(context.Background(), fresh(want.DeepCopy()))
+			if err != nil && !k8serrors.IsAlreadyExists(err) {
+				return fmt.Errorf("Failed to create network attachment definition %s %v", want.Name, err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to get network attachment definition %s %v", want.Name, err)
+		}
+	}
+	return nil
+}
+
+func networkNamespace(n sriovv1.SriovNetwork) string {
+	if n.Spec.NetworkNamespace != "" {
+		return n.Spec.NetworkNamespace
+	}
+	return n.Namespace
+}
+
+// object is a kubernetes object the snapshot holds.
+type object interface {
+	runtime.Object
+	metav1.Object
+}
+
+// fresh clears the server populated fields, so the object can be created again.
+func fresh(obj object) runtime.Object {
+	obj.SetResourceVersion("")
+	obj.SetUID("")
+	obj.SetSelfLink("")
+	obj.SetCreationTimestamp(metav1.Time{})
+	obj.SetGeneration(0)
+	return obj
+}
@@ -0,0 +1,99 @@
+package snapshot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	netattdefv1 "github.com/openshift/sriov-network-operator/pkg/apis/k8s/v1"
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("Snapshot", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "snapshot")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("round trips through yaml", func() {
+		enabled := "true"
+		s := &Snapshot{
+			OperatorNamespace: "sriov-operator",
+			OperatorConfig: &sriovv1.SriovOperatorConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "sriov-operator"},
+				Spec: sriovv1.SriovOperatorConfigSpec{
+					EnableInjector:           pointer.BoolPtr(true),
+					ConfigDaemonNodeSelector: map[string]string{"sriovenabled": "true"},
+				},
+			},
+			Policies: []sriovv1.SriovNetworkNodePolicy{{
+				ObjectMeta: metav1.ObjectMeta{Name: "policy1", Namespace: "sriov-operator"},
+				Spec: sriovv1.SriovNetworkNodePolicySpec{
+					ResourceName: "resource1",
+					NumVfs:       5,
+					NicSelector:  sriovv1.SriovNetworkNicSelector{PfNames: []string{"ens1f0#0-4"}},
+				},
+			}},
+			Networks: []sriovv1.SriovNetwork{{
+				ObjectMeta: metav1.ObjectMeta{Name: "network1", Namespace: "sriov-operator"},
+				Spec:       sriovv1.SriovNetworkSpec{ResourceName: "resource1", NetworkNamespace: "test"},
+			}},
+			NetworkAttachmentDefinitions: []netattdefv1.NetworkAttachmentDefinition{{
+				ObjectMeta: metav1.ObjectMeta{Name: "network1", Namespace: "test"},
+				Spec:       netattdefv1.NetworkAttachmentDefinitionSpec{Config: `{"type":"sriov"}`},
+			}},
+			NodeLabels: map[string]map[string]*string{
+				"worker-0": {"sriovenabled": &enabled},
+				"worker-1": {"sriovenabled": nil},
+			},
+		}
+
+		path := filepath.Join(dir, "snapshot.yaml")
+		Expect(s.WriteFile(path)).To(Succeed())
+		loaded, err := ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(loaded).To(Equal(s))
+	})
+
+	It("fails to read an invalid file", func() {
+		path := filepath.Join(dir, "snapshot.yaml")
+		Expect(ioutil.WriteFile(path, []byte("policies: 3"), 0644)).To(Succeed())
+		_, err := ReadFile(path)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("clears the server populated fields", func() {
+		policy := &sriovv1.SriovNetworkNodePolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "policy1",
+				ResourceVersion: "1234",
+				UID:             "uid",
+				Generation:      3,
+			},
+		}
+		fresh(policy)
+		Expect(policy.Name).To(Equal("policy1"))
+		Expect(policy.ResourceVersion).To(BeEmpty())
+		Expect(policy.UID).To(BeEmpty())
+		Expect(policy.Generation).To(BeZero())
+	})
+
+	It("uses the target namespace of the networks", func() {
+		n := sriovv1.SriovNetwork{ObjectMeta: metav1.ObjectMeta{Namespace: "sriov-operator"}}
+		Expect(networkNamespace(n)).To(Equal("sriov-operator"))
+		n.Spec.NetworkNamespace = "test"
+		Expect(networkNamespace(n)).To(Equal("test"))
+	})
+})
@@ -0,0 +1,181 @@
+package traffic
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PingResult is the summary of a ping run.
+type PingResult struct {
+	Transmitted int
+	Received    int
+	// Loss is the percentage of lost packets.
+	Loss float64
+	// The round trip times are zero when no reply was received.
+	RTTMin  time.Duration
+	RTTAvg  time.Duration
+	RTTMax  time.Duration
+	RTTMdev time.Duration
+}
+
+var (
+	// 5 packets transmitted, 5 received, 0% packet loss, time 819ms
+	// 3 packets transmitted, 2 packets received, 33% packet loss
+	pingSummary = regexp.MustCompile(`(\d+) packets transmitted, (\d+)(?: packets)? received,.* ([\d.]+)% packet loss`)
+	// rtt min/avg/max/mdev = 0.082/0.097/0.143/0.023 ms
+	// round-trip min/avg/max = 0.101/0.155/0.210 ms
+	pingRTT = regexp.MustCompile(`(?:rtt|round-trip) min/avg/max(?:/mdev)? = ([\d./]+) ms`)
+)
+
+// ParsePing parses the output of iputils or busybox ping.
+func ParsePing(out string) (*PingResult, error) {
+	m := pingSummary.FindStringSubmatch(out)
+	if m == nil {
+		return nil, fmt.Errorf("No ping statistics found in %q", out)
+	}
+	res := &PingResult{}
+	res.Transmitted, _ = strconv.Atoi(m[1])
+	res.Received, _ = strconv.Atoi(m[2])
+	res.Loss, _ = strconv.ParseFloat(m[3], 64)
+
+	m = pingRTT.FindStringSubmatch(out)
+	if m == nil {
+		return res, nil
+	}
+	rtts := []*time.Duration{&res.RTTMin, &res.RTTAvg, &res.RTTMax, &res.RTTMdev}
+	for i, value := range strings.Split(m[1], "/") {
+		if i >= len(rtts) {
+			break
+		}
+		ms, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid round trip time %q %v", value, err)
+		}
+		*rtts[i] = time.Duration(ms * float64(time.Millisecond))
+	}
+	return res, nil
+}
+
+// ArpReply is a reply received by arping.
+type ArpReply struct {
+	IP  string
+	MAC string
+	RTT time.Duration
+}
+
+// ArpingResult is the summary of an arping run.
+type ArpingResult struct {
+	Sent     int
+	Received int
+	Replies  []ArpReply
+}
+
+var (
+	// Unicast reply from 10.10.10.172 [20:04:0F:F1:88:02]  0.705ms
+	arpingReply = regexp.MustCompile(`reply from ([\d.]+) \[([0-9A-Fa-f:]+)\]\s+([\d.]+)ms`)
+	arpingSent  = regexp.MustCompile(`Sent (\d+) probes`)
+	// Received 2 response(s)
+	arpingReceived = regexp.MustCompile(`Received (\d+) response`)
+)
+
+// ParseArping parses the output of iputils arping. The macs are lower cased.
+func ParseArping(out string) (*ArpingResult, error) {
+	sent := arpingSent.FindStringSubmatch(out)
+	received := arpingReceived.FindStringSubmatch(out)
+	if sent == nil || received == nil {
+		return nil, fmt.Errorf("No arping statistics found in %q", out)
+	}
+	res := &ArpingResult{Replies: []ArpReply{}}
+	res.Sent, _ = strconv.Atoi(sent[1])
+	res.Received, _ = strconv.Atoi(received[1])
+	for _, m := range arpingReply.FindAllStringSubmatch(out, -1) {
+		ms, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid arping time %q %v", m[3], err)
+		}
+		res.Replies = append(res.Replies, ArpReply{
+			IP:  m[1],
+			MAC: strings.ToLower(m[2]),
+			RTT: time.Duration(ms * float64(time.Millisecond)),
+		})
+	}
+	return res, nil
+}
+
+// Throughput is a rate in bits per second.
+type Throughput float64
+
+func (t Throughput) String() string {
+	units := []string{"bits/sec", "Kbits/sec", "Mbits/sec", "Gbits/sec"}
+	value := float64(t)
+	unit := 0
+	for value >= 1000 && unit < len(units)-1 {
+		value /= 1000
+		unit++
+	}
+	return fmt.Sprintf("%.2f %s", value, units[unit])
+}
+
+// IperfResult is the summary of an iperf3 client run. The loss and jitter are
+// reported for udp only, the retransmits for tcp only.
+type IperfResult struct {
+	Protocol    string
+	Sent        Throughput
+	Received    Throughput
+	Retransmits int
+	JitterMs    float64
+	LostPercent float64
+}
+
+type iperfSum struct {
+	BitsPerSecond float64 `json:"bits_per_second"`
+	Retransmits   int     `json:"retransmits"`
+	JitterMs      float64 `json:"jitter_ms"`
+	LostPercent   float64 `json:"lost_percent"`
+}
+
+type iperfOutput struct {
+	Start struct {
+		TestStart struct {
+			Protocol string `json:"protocol"`
+		} `json:"test_start"`
+	} `json:"start"`
+	End struct {
+		Sum         *iperfSum `json:"sum"`
+		SumSent     *iperfSum `json:"sum_sent"`
+		SumReceived *iperfSum `json:"sum_received"`
+	} `json:"end"`
+	Error string `json:"error"`
+}
+
+// ParseIperf parses the json output of iperf3, the -J flag. The errors reported
+// by iperf3 are returned as errors.
+func ParseIperf(out string) (*IperfResult, error) {
+	output := iperfOutput{}
+	if err := json.Unmarshal([]byte(out), &output); err != nil {
+		return nil, fmt.Errorf("Failed to parse the iperf3 output %v", err)
+	}
+	if output.Error != "" {
+		return nil, fmt.Errorf("iperf3 failed: %s", output.Error)
+	}
+	res := &IperfResult{Protocol: output.Start.TestStart.Protocol}
+	end := output.End
+	switch {
+	case end.SumSent != nil && end.SumReceived != nil:
+		res.Sent = Throughput(end.SumSent.BitsPerSecond)
+		res.Received = Throughput(end.SumReceived.BitsPerSecond)
+		res.Retransmits = end.SumSent.Retransmits
+	case end.Sum != nil:
+		res.Sent = Throughput(end.Sum.BitsPerSecond)
+		res.Received = Throughput(end.Sum.BitsPerSecond * (100 - end.Sum.LostPercent) / 100)
+		res.JitterMs = end.Sum.JitterMs
+		res.LostPercent = end.Sum.LostPercent
+	default:
+		return nil, fmt.Errorf("No iperf3 summary found")
+	}
+	return res, nil
+}
@@ -0,0 +1,125 @@
+package traffic
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func fixture(name string) string {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	Expect(err).ToNot(HaveOccurred())
+	return string(data)
+}
+
+func ms(value float64) time.Duration {
+	return time.Duration(value * float64(time.Millisecond))
+}
+
+var _ = Describe("ParsePing", func() {
+	It("parses the statistics of iputils ping", func() {
+		res, err := ParsePing(fixture("ping.txt"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*res).To(Equal(PingResult{
+			Transmitted: 5,
+			Received:    5,
+			RTTMin:      ms(0.082),
+			RTTAvg:      ms(0.097),
+			RTTMax:      ms(0.143),
+			RTTMdev:     ms(0.023),
+		}))
+	})
+
+	It("reports the loss of an unreachable destination", func() {
+		res, err := ParsePing(fixture("ping_unreachable.txt"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*res).To(Equal(PingResult{Transmitted: 3, Loss: 100}))
+	})
+
+	It("parses the statistics of busybox ping", func() {
+		res, err := ParsePing(fixture("ping_busybox.txt"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*res).To(Equal(PingResult{
+			Transmitted: 3,
+			Received:    2,
+			Loss:        33,
+			RTTMin:      ms(0.101),
+			RTTAvg:      ms(0.155),
+			RTTMax:      ms(0.210),
+		}))
+	})
+
+	It("fails without statistics", func() {
+		_, err := ParsePing("ping: unknown iface net2")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ParseArping", func() {
+	It("parses the replies", func() {
+		res, err := ParseArping(fixture("arping.txt"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*res).To(Equal(ArpingResult{
+			Sent:     3,
+			Received: 2,
+			Replies: []ArpReply{
+				{IP: "10.10.10.172", MAC: "20:04:0f:f1:88:02", RTT: ms(0.705)},
+				{IP: "10.10.10.172", MAC: "20:04:0f:f1:88:02", RTT: ms(0.612)},
+			},
+		}))
+	})
+
+	It("fails without statistics", func() {
+		_, err := ParseArping("arping: unknown iface net2")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("ParseIperf", func() {
+	It("parses a tcp run", func() {
+		res, err := ParseIperf(fixture("iperf3_tcp.json"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Protocol).To(Equal("TCP"))
+		Expect(float64(res.Sent)).To(BeNumerically("~", 9408303058.5))
+		Expect(float64(res.Received)).To(BeNumerically("~", 9332334336.2))
+		Expect(res.Retransmits).To(Equal(12))
+		Expect(res.Received.String()).To(Equal("9.33 Gbits/sec"))
+	})
+
+	It("parses a udp run", func() {
+		res, err := ParseIperf(fixture("iperf3_udp.json"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Protocol).To(Equal("UDP"))
+		Expect(res.JitterMs).To(BeNumerically("~", 0.0124))
+		Expect(res.LostPercent).To(BeNumerically("~", 0.0197))
+		Expect(res.Sent.String()).To(Equal("999.98 Mbits/sec"))
+		Expect(float64(res.Received)).To(BeNumerically("<", float64(res.Sent)))
+	})
+
+	It("returns the iperf3 errors", func() {
+		_, err := ParseIperf(fixture("iperf3_error.json"))
+		Expect(err).To(MatchError("iperf3 failed: unable to connect to server: Connection refused"))
+		_, err = ParseIperf("iperf3: error")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Throughput", func() {
+	It("formats the rate", func() {
+		Expect(Throughput(512).String()).To(Equal("512.00 bits/sec"))
+		Expect(Throughput(1.5e6).String()).To(Equal("1.50 Mbits/sec"))
+		Expect(Throughput(4e13).String()).To(Equal("40000.00 Gbits/sec"))
+	})
+})
+
+var _ = Describe("isConnectionRefused", func() {
+	It("tells the server not listening yet apart from the other errors", func() {
+		_, err := ParseIperf(fixture("iperf3_error.json"))
+		Expect(isConnectionRefused(err)).To(BeTrue())
+		_, err = ParseIperf(`{"error": "the server is busy running a test. try again later"}`)
+		Expect(isConnectionRefused(err)).To(BeFalse())
+	})
+})
@@ -0,0 +1,233 @@
+// Package traffic checks that traffic flows, or does not, between pods attached
+// to sriov networks, through ping, arping and iperf3 run in the pods.
+package traffic
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/sriov-tests/pkg/util/cleanup"
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+	"github.com/openshift/sriov-tests/pkg/util/pod"
+	"github.com/openshift/sriov-tests/pkg/util/wait"
+)
+
+const (
+	// DefaultInterface is the interface the endpoints are attached with, unless
+	// the selection element requests another.
+	DefaultInterface = "net1"
+
+	startTimeout = 3 * time.Minute
+	// serverStartTimeout bounds the wait for the iperf3 server to listen.
+	serverStartTimeout = 10 * time.Second
+	// commandSlack is added to the expected duration of the commands to bound them.
+	commandSlack = 30 * time.Second
+)
+
+// Endpoint is a pod attached to an sriov network.
+type Endpoint struct {
+	Pod       *corev1.Pod
+	Interface string
+	// IP and MAC are the addresses of the attachment, as reported by multus.
+	IP  string
+	MAC string
+}
+
+// StartEndpoint creates a pod attached to the network of the element, with
+// NET_ADMIN so its interface can be changed, and waits for it to run. Extra
+// options, such as pinning the pod to a node, are applied to the pod. The pod
+// is labelled with the run id of the registry, and tracked by it once created.
+func StartEndpoint(cs *testclient.ClientSet, registry *cleanup.Registry, element pod.NetworkSelectionElement, opts ...pod.Option) (*Endpoint, error) {
+	if element.InterfaceRequest == "" {
+		element.InterfaceRequest = DefaultInterface
+	}
+	opts = append([]pod.Option{pod.WithNetworks(element), pod.WithNetAdmin()}, opts...)
+	definition := pod.Define(append(opts, pod.WithLabels(map[string]string{cleanup.RunIDLabel: registry.RunID}))...)
+	created, err := cs.Pods(definition.Namespace).Create(definition)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create the endpoint pod %v", err)
+	}
+	if err := registry.Track(created); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+	running, err := wait.ForPodRunning(ctx, cs, definition.Namespace, definition.Name)
+	if err != nil {
+		return nil, err
+	}
+	return endpointOf(running, element)
+}
+
+func endpointOf(p *corev1.Pod, element pod.NetworkSelectionElement) (*Endpoint, error) {
+	statuses, err := pod.NetworkStatuses(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range statuses {
+		if s.IsNetwork(element.NetworkName(p.Namespace), p.Namespace) && s.Interface == element.InterfaceRequest {
+			if len(s.IPs) == 0 {
+				return nil, fmt.Errorf("Attachment %s of pod %s/%s has no ip", s.Interface, p.Namespace, p.Name)
+			}
+			return &Endpoint{Pod: p, Interface: s.Interface, IP: s.IPs[0], MAC: s.Mac}, nil
+		}
+	}
+	return nil, fmt.Errorf("Attachment %s to %s not found in pod %s/%s", element.InterfaceRequest, element.Name, p.Namespace, p.Name)
+}
+
+// Ping sends count echo requests from the endpoint to the destination. Lost
+// packets are reported through the result, not as an error.
+func Ping(cs *testclient.ClientSet, from *Endpoint, to string, count int) (*PingResult, error) {
+	out, err := run(cs, from, time.Duration(count)*time.Second,
+		"ping", "-I", from.Interface, "-c", strconv.Itoa(count), "-i", "0.2", "-W", "1", to)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePing(out)
+}
+
+// Arping sends count arp requests from the endpoint to the destination.
+func Arping(cs *testclient.ClientSet, from *Endpoint, to string, count int) (*ArpingResult, error) {
+	out, err := run(cs, from, time.Duration(count)*time.Second,
+		"arping", "-I", from.Interface, "-c", strconv.Itoa(count), "-w", strconv.Itoa(count+1), to)
+	if err != nil {
+		return nil, err
+	}
+	return ParseArping(out)
+}
+
+// IperfOptions tunes an iperf3 run.
+type IperfOptions struct {
+	// UDP runs the test over udp instead of tcp.
+	UDP bool
+	// Bandwidth is the target rate, i.e. 1G. iperf3 defaults to 1M for udp.
+	Bandwidth string
+	// Duration defaults to 5 seconds.
+	Duration time.Duration
+}
+
+// Iperf runs an iperf3 client on the client endpoint against a one off server
+// started on the server endpoint.
+func Iperf(cs *testclient.ClientSet, server, client *Endpoint, opts IperfOptions) (*IperfResult, error) {
+	if opts.Duration == 0 {
+		opts.Duration = 5 * time.Second
+	}
+	if _, err := runChecked(cs, server, 0, "iperf3", "-s", "-1", "-D", "-B", server.IP); err != nil {
+		return nil, err
+	}
+
+	args := []string{"iperf3", "-J", "-c", server.IP, "-B", client.IP, "-t", strconv.Itoa(int(opts.Duration.Seconds()))}
+	if opts.UDP {
+		args = append(args, "-u")
+	}
+	if opts.Bandwidth != "" {
+		args = append(args, "-b", opts.Bandwidth)
+	}
+	// the daemonized server may not listen yet when the client starts, so the
+	// client is retried while the connection is refused
+	deadline := time.Now().Add(serverStartTimeout)
+	for {
+		// iperf3 exits with an error status when the test fails, the reason is in the json
+		out, err := run(cs, client, opts.Duration, args...)
+		if err != nil {
+			return nil, err
+		}
+		res, err := ParseIperf(out)
+		if err == nil || !isConnectionRefused(err) || time.Now().After(deadline) {
+			return res, err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func isConnectionRefused(err error) bool {
+	return strings.Contains(err.Error(), "Connection refused")
+}
+
+// Reachable tells if the destination replies to the echo requests of the endpoint.
+func Reachable(cs *testclient.ClientSet, from *Endpoint, to string) (bool, error) {
+	res, err := Ping(cs, from, to, 3)
+	if err != nil {
+		return false, err
+	}
+	return res.Received > 0, nil
+}
+
+// MACRejectedError is returned by ForgeMAC when the vf refuses the mac change,
+// as the i40e and ixgbe vfs do once the pf set their mac.
+type MACRejectedError struct {
+	Interface string
+	Reason    string
+}
+
+func (e *MACRejectedError) Error() string {
+	return fmt.Sprintf("The mac change of %s was rejected: %s", e.Interface, e.Reason)
+}
+
+// IsMACRejected tells if the error is a MACRejectedError.
+func IsMACRejected(err error) bool {
+	_, ok := err.(*MACRejectedError)
+	return ok
+}
+
+// ForgeMAC changes the mac of the endpoint interface inside the pod, so its
+// frames carry a source mac the vf was not assigned. The peers keep sending to
+// the former mac until their neighbour entry is flushed.
+func ForgeMAC(cs *testclient.ClientSet, e *Endpoint, mac string) error {
+	res, err := execIn(cs, e, 0, []string{"ip", "link", "set", "dev", e.Interface, "address", mac})
+	if err != nil {
+		return err
+	}
+	if !res.Success() {
+		return &MACRejectedError{Interface: e.Interface, Reason: strings.TrimSpace(res.Stderr)}
+	}
+	return nil
+}
+
+// FlushNeighbors flushes the neighbour entries of the endpoint interface, so
+// the macs of the peers are resolved again.
+func FlushNeighbors(cs *testclient.ClientSet, e *Endpoint) error {
+	_, err := runChecked(cs, e, 0, "ip", "neigh", "flush", "dev", e.Interface)
+	return err
+}
+
+// run runs the command in the endpoint pod and returns its stdout, whatever its
+// exit status, as ping and iperf3 report their failures there.
+func run(cs *testclient.ClientSet, e *Endpoint, duration time.Duration, command ...string) (string, error) {
+	res, err := execIn(cs, e, duration, command)
+	if err != nil {
+		return "", err
+	}
+	if res.Stdout == "" && !res.Success() {
+		return "", fmt.Errorf("%s exited with status %d: %s", command[0], res.ExitCode, strings.TrimSpace(res.Stderr))
+	}
+	return res.Stdout, nil
+}
+
+// runChecked is run, failing on a non zero exit status.
+func runChecked(cs *testclient.ClientSet, e *Endpoint, duration time.Duration, command ...string) (string, error) {
+	res, err := execIn(cs, e, duration, command)
+	if err != nil {
+		return "", err
+	}
+	if !res.Success() {
+		return "", fmt.Errorf("%v exited with status %d: %s", command, res.ExitCode, strings.TrimSpace(res.Stderr))
+	}
+	return res.Stdout, nil
+}
+
+func execIn(cs *testclient.ClientSet, e *Endpoint, duration time.Duration, command []string) (*pod.ExecResult, error) {
+	res, err := pod.Exec(context.Background(), cs, e.Pod, pod.ExecOptions{
+		Command: command,
+		Timeout: duration + commandSlack,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to run %s in %s/%s %v", command[0], e.Pod.Namespace, e.Pod.Name, err)
+	}
+	return res, nil
+}
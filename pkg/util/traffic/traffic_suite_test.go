@@ -0,0 +1,13 @@
+package traffic
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestTraffic(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Traffic Suite")
+}
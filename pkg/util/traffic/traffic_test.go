@@ -0,0 +1,54 @@
+package traffic
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/sriov-tests/pkg/util/pod"
+)
+
+var _ = Describe("endpointOf", func() {
+	withStatus := func(status string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:        "endpoint",
+			Namespace:   "sriov-conformance-testing",
+			Annotations: map[string]string{pod.NetworkStatusAnnotation: status},
+		}}
+	}
+
+	It("returns the addresses of the requested attachment", func() {
+		p := withStatus(`[
+			{"name": "openshift-sdn", "interface": "eth0", "ips": ["10.128.2.14"], "default": true},
+			{"name": "sriov-conformance-testing/sriov1", "interface": "net1", "ips": ["10.10.10.11"], "mac": "ca:fe:c0:ff:ee:01"},
+			{"name": "sriov-conformance-testing/sriov1", "interface": "net2", "ips": ["10.10.10.12"], "mac": "ca:fe:c0:ff:ee:02"}
+		]`)
+		e, err := endpointOf(p, pod.NetworkSelectionElement{Name: "sriov1", InterfaceRequest: "net2"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(e.Pod).To(Equal(p))
+		Expect(e.Interface).To(Equal("net2"))
+		Expect(e.IP).To(Equal("10.10.10.12"))
+		Expect(e.MAC).To(Equal("ca:fe:c0:ff:ee:02"))
+	})
+
+	It("fails when the attachment is missing or has no ip", func() {
+		p := withStatus(`[{"name": "sriov-conformance-testing/sriov1", "interface": "net1", "mac": "ca:fe:c0:ff:ee:01"}]`)
+		_, err := endpointOf(p, pod.NetworkSelectionElement{Name: "sriov1", InterfaceRequest: "net1"})
+		Expect(err).To(MatchError(ContainSubstring("has no ip")))
+		_, err = endpointOf(p, pod.NetworkSelectionElement{Name: "sriov2", InterfaceRequest: "net1"})
+		Expect(err).To(MatchError(ContainSubstring("not found")))
+	})
+})
+
+var _ = Describe("IsMACRejected", func() {
+	It("tells the mac rejections apart", func() {
+		err := error(&MACRejectedError{Interface: "net1", Reason: "RTNETLINK answers: Permission denied"})
+		Expect(IsMACRejected(err)).To(BeTrue())
+		Expect(err).To(MatchError("The mac change of net1 was rejected: RTNETLINK answers: Permission denied"))
+		Expect(IsMACRejected(fmt.Errorf("Failed to run ip"))).To(BeFalse())
+	})
+})
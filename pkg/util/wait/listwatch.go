@@ -0,0 +1,119 @@
+package wait
+
+import (
+	netattdefv1 "github.com/openshift/sriov-network-operator/pkg/apis/k8s/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+)
+
+// NodeStates lists and watches the SriovNetworkNodeStates of the namespace.
+func NodeStates(clients *testclient.ClientSet, namespace string) ListWatch {
+	return ListWatch{
+		Kind: "SriovNetworkNodeState",
+		List: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return clients.SriovNetworkNodeStates(namespace).List(opts)
+		},
+		Watch: clients.SriovNetworkNodeStates(namespace).Watch,
+	}
+}
+
+// DaemonSets lists and watches the DaemonSets of the namespace.
+func DaemonSets(clients *testclient.ClientSet, namespace string) ListWatch {
+	return ListWatch{
+		Kind: "DaemonSet",
+		List: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return clients.DaemonSets(namespace).List(opts)
+		},
+		Watch: clients.DaemonSets(namespace).Watch,
+	}
+}
+
+// ConfigMaps lists and watches the ConfigMaps of the namespace.
+func ConfigMaps(clients *testclient.ClientSet, namespace string) ListWatch {
+	return ListWatch{
+		Kind: "ConfigMap",
+		List: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return clients.ConfigMaps(namespace).List(opts)
+		},
+		Watch: clients.ConfigMaps(namespace).Watch,
+	}
+}
+
+// Pods lists and watches the Pods of the namespace.
+func Pods(clients *testclient.ClientSet, namespace string) ListWatch {
+	return ListWatch{
+		Kind: "Pod",
+		List: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return clients.Pods(namespace).List(opts)
+		},
+		Watch: clients.Pods(namespace).Watch,
+	}
+}
+
+// Nodes lists and watches the Nodes.
+func Nodes(clients *testclient.ClientSet) ListWatch {
+	return ListWatch{
+		Kind: "Node",
+		List: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return clients.Nodes().List(opts)
+		},
+		Watch: clients.Nodes().Watch,
+	}
+}
+
+// NetworkAttachmentDefinitions lists and watches the NetworkAttachmentDefinitions
+// of the namespace. There is no typed client for them, so the dynamic one is used
+// and the objects are converted.
+func NetworkAttachmentDefinitions(clients *testclient.ClientSet, namespace string) ListWatch {
+	resource := netattdefv1.SchemeGroupVersion.WithResource("network-attachment-definitions")
+	client := func() (dynamic.ResourceInterface, error) {
+		dynClient, err := dynamic.NewForConfig(clients.Config)
+		if err != nil {
+			return nil, err
+		}
+		return dynClient.Resource(resource).Namespace(namespace), nil
+	}
+	return ListWatch{
+		Kind: "NetworkAttachmentDefinition",
+		List: func(opts metav1.ListOptions) (runtime.Object, error) {
+			c, err := client()
+			if err != nil {
+				return nil, err
+			}
+			list, err := c.List(opts)
+			if err != nil {
+				return nil, err
+			}
+			res := &netattdefv1.NetworkAttachmentDefinitionList{}
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(list.UnstructuredContent(), res)
+			return res, err
+		},
+		Watch: func(opts metav1.ListOptions) (watch.Interface, error) {
+			c, err := client()
+			if err != nil {
+				return nil, err
+			}
+			w, err := c.Watch(opts)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(ev watch.Event) (watch.Event, bool) {
+				u, ok := ev.Object.(*unstructured.Unstructured)
+				if !ok {
+					return ev, true
+				}
+				nad := &netattdefv1.NetworkAttachmentDefinition{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), nad); err != nil {
+					return watch.Event{Type: watch.Error, Object: ev.Object}, true
+				}
+				ev.Object = nad
+				return ev, true
+			}), nil
+		},
+	}
+}
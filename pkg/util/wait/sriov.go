@@ -0,0 +1,165 @@
+package wait
+
+import (
+	"context"
+	"fmt"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+)
+
+// NodeStateSucceeded is met when the node state has a spec of at least the given
+// generation and the daemon applied it: the status reports the interfaces as
+// the spec configures them, and the sync succeeded. The status carries no
+// observed generation, and a Succeeded status left over from the previous spec
+// is only told apart by the interfaces not matching the new one.
+func NodeStateSucceeded(generation int64) Condition {
+	return func(obj runtime.Object) (bool, string) {
+		state, ok := obj.(*sriovv1.SriovNetworkNodeState)
+		if !ok {
+			return false, "not found"
+		}
+		if state.Generation < generation {
+			return false, fmt.Sprintf("generation %d not observed yet, current is %d", generation, state.Generation)
+		}
+		if state.Status.SyncStatus != "Succeeded" {
+			return false, fmt.Sprintf("sync status is %q, last sync error %q", state.Status.SyncStatus, state.Status.LastSyncError)
+		}
+		if reason := interfacesNotApplied(state); reason != "" {
+			return false, reason
+		}
+		return true, ""
+	}
+}
+
+// interfacesNotApplied tells which interface of the spec the status does not
+// report as configured, empty when all are.
+func interfacesNotApplied(state *sriovv1.SriovNetworkNodeState) string {
+	for _, spec := range state.Spec.Interfaces {
+		var status *sriovv1.InterfaceExt
+		for i := range state.Status.Interfaces {
+			if state.Status.Interfaces[i].PciAddress == spec.PciAddress {
+				status = &state.Status.Interfaces[i]
+				break
+			}
+		}
+		switch {
+		case status == nil:
+			return fmt.Sprintf("interface %s not reported in the status", spec.PciAddress)
+		case status.NumVfs != spec.NumVfs:
+			return fmt.Sprintf("interface %s has %d vfs, expected %d", spec.PciAddress, status.NumVfs, spec.NumVfs)
+		case spec.Mtu != 0 && status.Mtu != spec.Mtu:
+			return fmt.Sprintf("interface %s has mtu %d, expected %d", spec.PciAddress, status.Mtu, spec.Mtu)
+		}
+	}
+	return ""
+}
+
+// DaemonSetRolledOut is met when the latest template of the daemonset runs and
+// is available on all the scheduled nodes.
+func DaemonSetRolledOut(obj runtime.Object) (bool, string) {
+	ds, ok := obj.(*appsv1.DaemonSet)
+	if !ok {
+		return false, "not found"
+	}
+	s := ds.Status
+	if s.ObservedGeneration < ds.Generation {
+		return false, fmt.Sprintf("generation %d not observed yet, observed is %d", ds.Generation, s.ObservedGeneration)
+	}
+	if s.UpdatedNumberScheduled != s.DesiredNumberScheduled || s.NumberAvailable != s.DesiredNumberScheduled {
+		return false, fmt.Sprintf("%d desired, %d updated, %d available", s.DesiredNumberScheduled, s.UpdatedNumberScheduled, s.NumberAvailable)
+	}
+	return true, ""
+}
+
+// NodeCapacity is met when the node reports the given capacity for the resource.
+func NodeCapacity(resource corev1.ResourceName, count int64) Condition {
+	return func(obj runtime.Object) (bool, string) {
+		node, ok := obj.(*corev1.Node)
+		if !ok {
+			return false, "not found"
+		}
+		quantity, ok := node.Status.Capacity[resource]
+		if !ok {
+			if count == 0 {
+				return true, ""
+			}
+			return false, fmt.Sprintf("no %s capacity", resource)
+		}
+		if quantity.Value() != count {
+			return false, fmt.Sprintf("%s capacity is %d, expected %d", resource, quantity.Value(), count)
+		}
+		return true, ""
+	}
+}
+
+// PodRunning is met when the pod is running. A failed or succeeded pod never
+// will, so the reason tells its phase.
+func PodRunning(obj runtime.Object) (bool, string) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return false, "not found"
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return false, fmt.Sprintf("phase is %s", pod.Status.Phase)
+	}
+	return true, ""
+}
+
+// ForNodeStateSucceeded waits for the state of the node to be in sync with a spec
+// of at least the given generation, as NodeStateSucceeded checks it.
+func ForNodeStateSucceeded(ctx context.Context, clients *testclient.ClientSet, namespace, node string, generation int64) (*sriovv1.SriovNetworkNodeState, error) {
+	obj, err := For(ctx, NodeStates(clients, namespace), node, NodeStateSucceeded(generation))
+	if err != nil {
+		return nil, withEvents(err, clients, namespace, node)
+	}
+	return obj.(*sriovv1.SriovNetworkNodeState), nil
+}
+
+// ForDaemonSetRolledOut waits for the daemonset to be rolled out.
+func ForDaemonSetRolledOut(ctx context.Context, clients *testclient.ClientSet, namespace, name string) (*appsv1.DaemonSet, error) {
+	obj, err := For(ctx, DaemonSets(clients, namespace), name, DaemonSetRolledOut)
+	if err != nil {
+		return nil, withEvents(err, clients, namespace, name)
+	}
+	return obj.(*appsv1.DaemonSet), nil
+}
+
+// ForNodeCapacity waits for the node to report the given capacity for the resource.
+func ForNodeCapacity(ctx context.Context, clients *testclient.ClientSet, node string, resource corev1.ResourceName, count int64) (*corev1.Node, error) {
+	obj, err := For(ctx, Nodes(clients), node, NodeCapacity(resource, count))
+	if err != nil {
+		return nil, withEvents(err, clients, metav1.NamespaceDefault, node)
+	}
+	return obj.(*corev1.Node), nil
+}
+
+// ForPodRunning waits for the pod to be running.
+func ForPodRunning(ctx context.Context, clients *testclient.ClientSet, namespace, name string) (*corev1.Pod, error) {
+	obj, err := For(ctx, Pods(clients, namespace), name, PodRunning)
+	if err != nil {
+		return nil, withEvents(err, clients, namespace, name)
+	}
+	return obj.(*corev1.Pod), nil
+}
+
+// withEvents adds the events involving the object to the timeout errors.
+func withEvents(err error, clients *testclient.ClientSet, namespace, name string) error {
+	timeoutErr, ok := err.(*TimeoutError)
+	if !ok {
+		return err
+	}
+	events, listErr := clients.Events(namespace).List(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.name", name).String(),
+	})
+	if listErr == nil {
+		timeoutErr.Events = events.Items
+	}
+	return timeoutErr
+}
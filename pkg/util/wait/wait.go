@@ -0,0 +1,194 @@
+// Package wait provides context aware waiters built on top of list and watch,
+// reacting to the changes of the objects instead of polling them.
+package wait
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/yaml"
+)
+
+// retryInterval is the time waited before listing again after a failure.
+var retryInterval = time.Second
+
+// ListWatch lists and watches the objects of a kind.
+type ListWatch struct {
+	Kind  string
+	List  func(opts metav1.ListOptions) (runtime.Object, error)
+	Watch func(opts metav1.ListOptions) (watch.Interface, error)
+}
+
+// Condition tells if the observed object, nil when it does not exist, is the
+// one waited for. When it is not, it returns the reason reported on timeout.
+type Condition func(obj runtime.Object) (done bool, reason string)
+
+// TimeoutError is returned when the context expires before the condition is met.
+type TimeoutError struct {
+	Kind   string
+	Name   string
+	Reason string
+	// Last is the last observed object, nil if it was not found.
+	Last   runtime.Object
+	Events []corev1.Event
+}
+
+func (e *TimeoutError) Error() string {
+	res := &strings.Builder{}
+	fmt.Fprintf(res, "Timed out waiting for %s %s: %s", e.Kind, e.Name, e.Reason)
+	if e.Last != nil {
+		if data, err := yaml.Marshal(e.Last); err == nil {
+			fmt.Fprintf(res, "\nlast observed:\n%s", indent(string(data)))
+		}
+	}
+	if len(e.Events) > 0 {
+		res.WriteString("\nevents:")
+		for _, ev := range e.Events {
+			fmt.Fprintf(res, "\n  %s %s %s: %s", ev.LastTimestamp.Format(time.RFC3339), ev.Type, ev.Reason, ev.Message)
+		}
+	}
+	return res.String()
+}
+
+// IsTimeout tells if the error is a TimeoutError.
+func IsTimeout(err error) bool {
+	_, ok := err.(*TimeoutError)
+	return ok
+}
+
+// For waits until the condition holds for the object with the given name.
+// It returns the object satisfying the condition, nil if the condition is met by
+// a missing object, or a TimeoutError once the context is done.
+func For(ctx context.Context, lw ListWatch, name string, cond Condition) (runtime.Object, error) {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	timeoutErr := &TimeoutError{Kind: lw.Kind, Name: name, Reason: "not observed yet"}
+	check := func(obj runtime.Object) bool {
+		timeoutErr.Last = obj
+		done, reason := cond(obj)
+		timeoutErr.Reason = reason
+		return done
+	}
+
+	for {
+		resourceVersion, err := listOnce(lw, selector, name, check)
+		if err == errDone {
+			return timeoutErr.Last, nil
+		}
+		if err != nil {
+			timeoutErr.Reason = err.Error()
+			select {
+			case <-ctx.Done():
+				return nil, timeoutErr
+			case <-time.After(retryInterval):
+				continue
+			}
+		}
+
+		w, err := lw.Watch(metav1.ListOptions{FieldSelector: selector, ResourceVersion: resourceVersion})
+		if err != nil {
+			timeoutErr.Reason = fmt.Sprintf("Failed to watch %v", err)
+			select {
+			case <-ctx.Done():
+				return nil, timeoutErr
+			case <-time.After(retryInterval):
+				continue
+			}
+		}
+		if watchUntil(ctx, w, check) {
+			return timeoutErr.Last, nil
+		}
+		if ctx.Err() != nil {
+			return nil, timeoutErr
+		}
+	}
+}
+
+// ForObject waits until the object exists.
+func ForObject(ctx context.Context, lw ListWatch, name string) (runtime.Object, error) {
+	return For(ctx, lw, name, func(obj runtime.Object) (bool, string) {
+		return obj != nil, "not found"
+	})
+}
+
+// ForDeletion waits until the object does not exist anymore.
+func ForDeletion(ctx context.Context, lw ListWatch, name string) error {
+	_, err := For(ctx, lw, name, func(obj runtime.Object) (bool, string) {
+		return obj == nil, "still exists"
+	})
+	return err
+}
+
+var errDone = fmt.Errorf("done")
+
+// listOnce checks the condition against the current state of the object and
+// returns the resource version to watch from.
+func listOnce(lw ListWatch, selector, name string, check func(runtime.Object) bool) (string, error) {
+	list, err := lw.List(metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return "", fmt.Errorf("Failed to list %v", err)
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return "", fmt.Errorf("Failed to extract the list items %v", err)
+	}
+	var obj runtime.Object
+	for _, item := range items {
+		accessor, err := meta.Accessor(item)
+		if err == nil && accessor.GetName() == name {
+			obj = item
+		}
+	}
+	if check(obj) {
+		return "", errDone
+	}
+	listAccessor, err := meta.ListAccessor(list)
+	if err != nil {
+		return "", fmt.Errorf("Failed to access the list metadata %v", err)
+	}
+	return listAccessor.GetResourceVersion(), nil
+}
+
+// watchUntil consumes the events until the condition is met. It returns false
+// when the context is done or the watch must be started again.
+func watchUntil(ctx context.Context, w watch.Interface, check func(runtime.Object) bool) bool {
+	defer w.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case ev, ok := <-w.ResultChan():
+			if !ok {
+				return false
+			}
+			switch ev.Type {
+			case watch.Added, watch.Modified:
+				if check(ev.Object) {
+					return true
+				}
+			case watch.Deleted:
+				if check(nil) {
+					return true
+				}
+			case watch.Error:
+				// i.e. the resource version is too old, list again
+				return false
+			}
+		}
+	}
+}
+
+func indent(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i := range lines {
+		lines[i] = "  " + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,190 @@
+package wait
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	sriovv1 "github.com/openshift/sriov-network-operator/pkg/apis/sriovnetwork/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+)
+
+func nodeState(generation int64, status string) *sriovv1.SriovNetworkNodeState {
+	return &sriovv1.SriovNetworkNodeState{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Generation: generation},
+		Status:     sriovv1.SriovNetworkNodeStateStatus{SyncStatus: status, LastSyncError: "boom"},
+	}
+}
+
+// fakeListWatch returns the given states on list and a fake watcher per watch call.
+func fakeListWatch(states ...sriovv1.SriovNetworkNodeState) (ListWatch, chan *watch.FakeWatcher) {
+	watchers := make(chan *watch.FakeWatcher, 10)
+	return ListWatch{
+		Kind: "SriovNetworkNodeState",
+		List: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return &sriovv1.SriovNetworkNodeStateList{Items: states}, nil
+		},
+		Watch: func(opts metav1.ListOptions) (watch.Interface, error) {
+			w := watch.NewFake()
+			watchers <- w
+			return w, nil
+		},
+	}, watchers
+}
+
+var _ = Describe("For", func() {
+	It("returns right away when the listed object matches", func() {
+		lw, _ := fakeListWatch(*nodeState(2, "Succeeded"))
+		obj, err := For(context.Background(), lw, "worker-0", NodeStateSucceeded(2))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(obj.(*sriovv1.SriovNetworkNodeState).Generation).To(BeEquivalentTo(2))
+	})
+
+	It("waits for the watched object to match", func() {
+		lw, watchers := fakeListWatch(*nodeState(1, "Succeeded"))
+		go func() {
+			defer GinkgoRecover()
+			w := <-watchers
+			w.Modify(nodeState(2, "InProgress"))
+			w.Modify(nodeState(2, "Succeeded"))
+		}()
+		obj, err := For(context.Background(), lw, "worker-0", NodeStateSucceeded(2))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(obj.(*sriovv1.SriovNetworkNodeState).Status.SyncStatus).To(Equal("Succeeded"))
+	})
+
+	It("lists again when the watch is closed", func() {
+		lw, watchers := fakeListWatch()
+		go func() {
+			defer GinkgoRecover()
+			(<-watchers).Stop()
+			(<-watchers).Add(nodeState(1, ""))
+		}()
+		_, err := ForObject(context.Background(), lw, "worker-0")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("waits for the deletion", func() {
+		lw, watchers := fakeListWatch(*nodeState(1, ""))
+		go func() {
+			defer GinkgoRecover()
+			(<-watchers).Delete(nodeState(1, ""))
+		}()
+		Expect(ForDeletion(context.Background(), lw, "worker-0")).To(Succeed())
+	})
+
+	It("reports the last observed object on timeout", func() {
+		lw, watchers := fakeListWatch(*nodeState(2, "Failed"))
+		go func() {
+			<-watchers
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		_, err := For(ctx, lw, "worker-0", NodeStateSucceeded(2))
+		Expect(IsTimeout(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring(`SriovNetworkNodeState worker-0: sync status is "Failed", last sync error "boom"`))
+		Expect(err.Error()).To(ContainSubstring("last observed:\n  metadata:"))
+	})
+})
+
+var _ = Describe("Conditions", func() {
+	It("checks the daemonset roll out", func() {
+		ds := &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Generation: 2},
+			Status: appsv1.DaemonSetStatus{
+				ObservedGeneration:     1,
+				DesiredNumberScheduled: 2,
+				UpdatedNumberScheduled: 2,
+				NumberAvailable:        2,
+			},
+		}
+		done, reason := DaemonSetRolledOut(ds)
+		Expect(done).To(BeFalse())
+		Expect(reason).To(ContainSubstring("generation 2 not observed"))
+
+		ds.Status.ObservedGeneration = 2
+		ds.Status.NumberAvailable = 1
+		done, reason = DaemonSetRolledOut(ds)
+		Expect(done).To(BeFalse())
+		Expect(reason).To(Equal("2 desired, 2 updated, 1 available"))
+
+		ds.Status.NumberAvailable = 2
+		done, _ = DaemonSetRolledOut(ds)
+		Expect(done).To(BeTrue())
+	})
+
+	It("checks the node state interfaces were applied", func() {
+		state := nodeState(2, "Succeeded")
+		state.Spec.Interfaces = sriovv1.Interfaces{{PciAddress: "0000:3b:00.0", NumVfs: 5, Mtu: 9000}}
+		state.Status.Interfaces = sriovv1.InterfaceExts{{InterfaceProperty: sriovv1.InterfaceProperty{PciAddress: "0000:3b:00.0", Mtu: 1500}}}
+
+		// the status left over from the previous spec
+		done, reason := NodeStateSucceeded(2)(state)
+		Expect(done).To(BeFalse())
+		Expect(reason).To(Equal("interface 0000:3b:00.0 has 0 vfs, expected 5"))
+
+		state.Status.Interfaces[0].NumVfs = 5
+		done, reason = NodeStateSucceeded(2)(state)
+		Expect(done).To(BeFalse())
+		Expect(reason).To(Equal("interface 0000:3b:00.0 has mtu 1500, expected 9000"))
+
+		state.Status.Interfaces[0].Mtu = 9000
+		done, _ = NodeStateSucceeded(2)(state)
+		Expect(done).To(BeTrue())
+
+		state.Status.Interfaces = nil
+		done, reason = NodeStateSucceeded(2)(state)
+		Expect(done).To(BeFalse())
+		Expect(reason).To(Equal("interface 0000:3b:00.0 not reported in the status"))
+	})
+
+	It("checks the node capacity", func() {
+		node := &corev1.Node{Status: corev1.NodeStatus{Capacity: corev1.ResourceList{
+			"openshift.io/testresource": resource.MustParse("5"),
+		}}}
+		done, _ := NodeCapacity("openshift.io/testresource", 5)(node)
+		Expect(done).To(BeTrue())
+		done, reason := NodeCapacity("openshift.io/testresource", 0)(node)
+		Expect(done).To(BeFalse())
+		Expect(reason).To(Equal("openshift.io/testresource capacity is 5, expected 0"))
+		done, _ = NodeCapacity("openshift.io/other", 0)(node)
+		Expect(done).To(BeTrue())
+		done, _ = NodeCapacity("openshift.io/other", 0)(nil)
+		Expect(done).To(BeFalse())
+	})
+})
+
+var _ = Describe("ForNodeStateSucceeded", func() {
+	It("waits for the state to be synced through the api", func() {
+		state := nodeState(0, "InProgress")
+		state.Namespace = "sriov-operator"
+		clients, stop, err := testclient.NewFake(state)
+		Expect(err).ToNot(HaveOccurred())
+		defer stop()
+
+		go func() {
+			defer GinkgoRecover()
+			time.Sleep(100 * time.Millisecond)
+			current, err := clients.SriovNetworkNodeStates("sriov-operator").Get("worker-0", metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			current.Status.SyncStatus = "Succeeded"
+			_, err = clients.SriovNetworkNodeStates("sriov-operator").UpdateStatus(current)
+			Expect(err).ToNot(HaveOccurred())
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		res, err := ForNodeStateSucceeded(ctx, clients, "sriov-operator", "worker-0", 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Status.SyncStatus).To(Equal("Succeeded"))
+	})
+})
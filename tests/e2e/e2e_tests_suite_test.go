@@ -1,19 +1,19 @@
 package e2e
 
 import (
-	// goctx "context"
-	// "encoding/json"
-	// "fmt"
+	goctx "context"
+	"fmt"
 	// "reflect"
+	"flag"
+	"os"
 	// "strings"
 	"testing"
-	// "time"
+	"time"
 
-	// dptypes "github.com/intel/sriov-network-device-plugin/pkg/types"
 	framework "github.com/operator-framework/operator-sdk/pkg/test"
 	// "github.com/operator-framework/operator-sdk/pkg/test/e2eutil"
 	appsv1 "k8s.io/api/apps/v1"
-	// corev1 "k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
 	// "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -27,15 +27,57 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/reporters"
 	. "github.com/onsi/gomega"
 
+	"github.com/openshift/sriov-tests/pkg/simulator"
 	. "github.com/openshift/sriov-tests/pkg/util"
-	"github.com/openshift/sriov-tests/pkg/util/cluster"
 	testclient "github.com/openshift/sriov-tests/pkg/util/client"
+	"github.com/openshift/sriov-tests/pkg/util/cluster"
+	"github.com/openshift/sriov-tests/pkg/util/deviceplugin"
+	"github.com/openshift/sriov-tests/pkg/util/wait"
 )
 
 var namespace = "openshift-sriov-network-operator"
 var oprctx framework.TestCtx
+var junitPath *string
+
+func init() {
+	junitPath = flag.String("junit", "", "the path for the junit format report")
+	if ns := os.Getenv("OPERATOR_NAMESPACE"); ns != "" {
+		namespace = ns
+	}
+}
+
+// stopSimulator stops the simulated config daemon, when running.
+var stopSimulator goctx.CancelFunc = func() {}
+
+// simulatorDone gets the error the simulator stopped with, it stays empty
+// when the simulator is not used or still runs.
+var simulatorDone = make(chan error, 1)
+
+// simulatorErr keeps why the simulator stopped once seen, so all the
+// following specs fail too.
+var simulatorErr error
+
+// checkSimulator fails when the simulator stopped before the end of the suite,
+// as the node states are not synced anymore.
+func checkSimulator() {
+	if simulatorErr == nil {
+		select {
+		case err := <-simulatorDone:
+			simulatorErr = fmt.Errorf("the simulator stopped %v", err)
+		default:
+		}
+	}
+	Expect(simulatorErr).ToNot(HaveOccurred())
+}
+
+var _ = BeforeEach(checkSimulator)
+
+// the specs waiting on the node states time out when the simulator stops
+// during the spec, the reason is reported once done
+var _ = AfterEach(checkSimulator)
 
 func TestSriovTests(t *testing.T) {
 	snetList := &sriovnetworkv1.SriovNetworkList{
@@ -51,9 +93,15 @@ func TestSriovTests(t *testing.T) {
 
 	config.GinkgoConfig.ParallelTotal = 1
 	RegisterFailHandler(Fail)
-	RunSpecs(t, "OperatorTests Suite")
+
+	rr := []Reporter{}
+	if *junitPath != "" {
+		rr = append(rr, reporters.NewJUnitReporter(*junitPath))
+	}
+	RunSpecsWithDefaultAndCustomReporters(t, "OperatorTests Suite", rr)
 }
 
+var clients *testclient.ClientSet
 var sriovInfos *cluster.EnabledNodes
 var sriovIface *sriovnetworkv1.InterfaceExt
 
@@ -64,17 +112,68 @@ var _ = BeforeSuite(func() {
 	deploy := &appsv1.Deployment{}
 	err := WaitForNamespacedObject(deploy, f.Client, namespace, "sriov-network-operator", RetryInterval, Timeout)
 	Expect(err).NotTo(HaveOccurred())
-	clients := testclient.New("", func(scheme *runtime.Scheme) {
-		sriovnetworkv1.AddToScheme(scheme)
+	ctx, cancel := goctx.WithTimeout(goctx.Background(), time.Minute)
+	defer cancel()
+	clients, err = testclient.NewWithOptions(ctx, testclient.Options{
+		AddToScheme:           sriovnetworkv1.AddToScheme,
+		AllowMissingOpenShift: true,
 	})
-	sriovInfos, err = cluster.DiscoverSriov(clients, namespace)
 	Expect(err).ToNot(HaveOccurred())
+
+	if os.Getenv("SRIOV_SIMULATOR") == "true" {
+		By("starting the simulated config daemon on the worker nodes")
+		workers, err := clients.Nodes().List(metav1.ListOptions{LabelSelector: "node-role.kubernetes.io/worker"})
+		Expect(err).ToNot(HaveOccurred())
+		nodes := map[string][]simulator.PF{}
+		for _, n := range workers.Items {
+			nodes[n.Name] = simulator.DefaultPFs()
+		}
+		var ctx goctx.Context
+		ctx, stopSimulator = goctx.WithCancel(goctx.Background())
+		go func() {
+			simulatorDone <- simulator.New(clients, namespace, nodes).Run(ctx)
+		}()
+	}
+
+	Eventually(func() error {
+		checkSimulator()
+		sriovInfos, err = cluster.DiscoverSriov(clients, namespace)
+		return err
+	}, 2*time.Minute, time.Second).ShouldNot(HaveOccurred())
 	Expect(len(sriovInfos.Nodes)).Should(BeNumerically(">=", 1))
 	sriovIface, err = sriovInfos.FindOneSriovDevice(sriovInfos.Nodes[0])
 	Expect(err).ToNot(HaveOccurred())
 	Expect(sriovIface).ToNot(BeNil())
 })
 
+// devicePluginConfigured is met when the device plugin config map holds the
+// resources of all the given policies.
+func devicePluginConfigured(policies []*sriovnetworkv1.SriovNetworkNodePolicy) wait.Condition {
+	return func(obj runtime.Object) (bool, string) {
+		cm, ok := obj.(*corev1.ConfigMap)
+		if !ok {
+			return false, "not found"
+		}
+		configs, err := deviceplugin.ParseConfigMap(cm)
+		if err != nil {
+			return false, err.Error()
+		}
+		for _, p := range policies {
+			found := false
+			for _, config := range configs {
+				if config.Resource(p.Spec.ResourceName) != nil {
+					found = true
+				}
+			}
+			if !found {
+				return false, fmt.Sprintf("resource %s not configured", p.Spec.ResourceName)
+			}
+		}
+		return true, ""
+	}
+}
+
 var _ = AfterSuite(func() {
 	oprctx.Cleanup()
+	stopSimulator()
 })
@@ -6,13 +6,12 @@ import (
 	// "strings"
 	// "testing"
 	"strconv"
-	"time"
 
 	// dptypes "github.com/intel/sriov-network-device-plugin/pkg/types"
 	framework "github.com/operator-framework/operator-sdk/pkg/test"
 	// "github.com/operator-framework/operator-sdk/pkg/test/e2eutil"
 	// admv1beta1 "k8s.io/api/admissionregistration/v1beta1"
-	appsv1 "k8s.io/api/apps/v1"
+	// appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	// "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,6 +29,8 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/openshift/sriov-tests/pkg/util"
+	"github.com/openshift/sriov-tests/pkg/util/deviceplugin"
+	"github.com/openshift/sriov-tests/pkg/util/wait"
 )
 
 var _ = Describe("Operator", func() {
@@ -87,19 +88,19 @@ var _ = Describe("Operator", func() {
 				policy.Spec.NicSelector.PfNames = []string{sriovIface.Name}
 				// get global framework variables
 				f := framework.Global
-				var err error
 				By("wait for the node state ready")
 				nodeList := &corev1.NodeList{}
 				lo := &dynclient.MatchingLabels{
 					"feature.node.kubernetes.io/network-sriov.capable": "true",
 				}
-				err = f.Client.List(goctx.TODO(), nodeList, lo)
+				err := f.Client.List(goctx.TODO(), nodeList, lo)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(len(nodeList.Items)).To(Equal(1))
 
 				name := nodeList.Items[0].GetName()
-				nodeState := &sriovnetworkv1.SriovNetworkNodeState{}
-				err = WaitForSriovNetworkNodeStateReady(nodeState, f.Client, namespace, name, RetryInterval, Timeout*5)
+				ctx, cancel := goctx.WithTimeout(goctx.Background(), Timeout*5)
+				defer cancel()
+				nodeState, err := wait.ForNodeStateSucceeded(ctx, clients, namespace, name, 0)
 				Expect(err).NotTo(HaveOccurred())
 
 				By("apply node policy CR")
@@ -107,23 +108,22 @@ var _ = Describe("Operator", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				By("generate the config for device plugin")
-				time.Sleep(3 * time.Second)
-				config := &corev1.ConfigMap{}
-				err = WaitForNamespacedObject(config, f.Client, namespace, "device-plugin-config", RetryInterval, Timeout)
+				obj, err := wait.For(ctx, wait.ConfigMaps(clients, namespace), deviceplugin.ConfigMapName, devicePluginConfigured([]*sriovnetworkv1.SriovNetworkNodePolicy{policy}))
 				Expect(err).NotTo(HaveOccurred())
-				err = ValidateDevicePluginConfig([]*sriovnetworkv1.SriovNetworkNodePolicy{policy}, config.Data["config.json"])
+				config := obj.(*corev1.ConfigMap)
+				diff, err := deviceplugin.ValidateConfigMap([]*sriovnetworkv1.SriovNetworkNodePolicy{policy}, config, nodeList.Items)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(diff).To(BeEmpty())
 
 				By("wait for the node state ready")
-				err = WaitForSriovNetworkNodeStateReady(nodeState, f.Client, namespace, name, RetryInterval, Timeout*5)
+				nodeState, err = wait.ForNodeStateSucceeded(ctx, clients, namespace, name, nodeState.Generation+1)
 				Expect(err).NotTo(HaveOccurred())
 
 				By("provision the cni and device plugin daemonsets")
-				cniDaemonSet := &appsv1.DaemonSet{}
-				err = WaitForDaemonSetReady(cniDaemonSet, f.Client, namespace, "sriov-cni", RetryInterval, Timeout)
+				_, err = wait.ForDaemonSetRolledOut(ctx, clients, namespace, "sriov-cni")
 				Expect(err).NotTo(HaveOccurred())
 
-				dpDaemonSet := &appsv1.DaemonSet{}
-				err = WaitForDaemonSetReady(dpDaemonSet, f.Client, namespace, "sriov-device-plugin", RetryInterval, Timeout)
+				_, err = wait.ForDaemonSetRolledOut(ctx, clients, namespace, "sriov-device-plugin")
 				Expect(err).NotTo(HaveOccurred())
 
 				By("update the spec of SriovNetworkNodeState CR")
@@ -227,19 +227,19 @@ var _ = Describe("Operator", func() {
 				policy.Spec.NicSelector.PfNames[0] = sriovIface.Name + policy.Spec.NicSelector.PfNames[0]
 				// get global framework variables
 				f := framework.Global
-				var err error
 				By("wait for the node state ready")
 				nodeList := &corev1.NodeList{}
 				lo := &dynclient.MatchingLabels{
 					"feature.node.kubernetes.io/network-sriov.capable": "true",
 				}
-				err = f.Client.List(goctx.TODO(), nodeList, lo)
+				err := f.Client.List(goctx.TODO(), nodeList, lo)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(len(nodeList.Items)).To(Equal(1))
 
 				name := nodeList.Items[0].GetName()
-				nodeState := &sriovnetworkv1.SriovNetworkNodeState{}
-				err = WaitForSriovNetworkNodeStateReady(nodeState, f.Client, namespace, name, RetryInterval, Timeout*5)
+				ctx, cancel := goctx.WithTimeout(goctx.Background(), Timeout*5)
+				defer cancel()
+				nodeState, err := wait.ForNodeStateSucceeded(ctx, clients, namespace, name, 0)
 				Expect(err).NotTo(HaveOccurred())
 
 				By("apply node policy CR")
@@ -247,23 +247,22 @@ var _ = Describe("Operator", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				By("generate the config for device plugin")
-				time.Sleep(3 * time.Second)
-				config := &corev1.ConfigMap{}
-				err = WaitForNamespacedObject(config, f.Client, namespace, "device-plugin-config", RetryInterval, Timeout)
+				obj, err := wait.For(ctx, wait.ConfigMaps(clients, namespace), deviceplugin.ConfigMapName, devicePluginConfigured([]*sriovnetworkv1.SriovNetworkNodePolicy{policy}))
+				Expect(err).NotTo(HaveOccurred())
+				config := obj.(*corev1.ConfigMap)
+				diff, err := deviceplugin.ValidateConfigMap([]*sriovnetworkv1.SriovNetworkNodePolicy{policy}, config, nodeList.Items)
 				Expect(err).NotTo(HaveOccurred())
-				err = ValidateDevicePluginConfig([]*sriovnetworkv1.SriovNetworkNodePolicy{policy}, config.Data["config.json"])
+				Expect(diff).To(BeEmpty())
 
 				By("wait for the node state ready")
-				err = WaitForSriovNetworkNodeStateReady(nodeState, f.Client, namespace, name, RetryInterval, Timeout*5)
+				nodeState, err = wait.ForNodeStateSucceeded(ctx, clients, namespace, name, nodeState.Generation+1)
 				Expect(err).NotTo(HaveOccurred())
 
 				By("provision the cni and device plugin daemonsets")
-				cniDaemonSet := &appsv1.DaemonSet{}
-				err = WaitForDaemonSetReady(cniDaemonSet, f.Client, namespace, "sriov-cni", RetryInterval, Timeout)
+				_, err = wait.ForDaemonSetRolledOut(ctx, clients, namespace, "sriov-cni")
 				Expect(err).NotTo(HaveOccurred())
 
-				dpDaemonSet := &appsv1.DaemonSet{}
-				err = WaitForDaemonSetReady(dpDaemonSet, f.Client, namespace, "sriov-device-plugin", RetryInterval, Timeout)
+				_, err = wait.ForDaemonSetRolledOut(ctx, clients, namespace, "sriov-device-plugin")
 				Expect(err).NotTo(HaveOccurred())
 
 				By("update the spec of SriovNetworkNodeState CR")
@@ -370,20 +369,20 @@ var _ = Describe("Operator", func() {
 				policy2.Spec.NicSelector.PfNames[0] = sriovIface.Name + policy2.Spec.NicSelector.PfNames[0]
 				// get global framework variables
 				f := framework.Global
-				var err error
 				policies := []*sriovnetworkv1.SriovNetworkNodePolicy{policy1, policy2}
 				By("wait for the node state ready")
 				nodeList := &corev1.NodeList{}
 				lo := &dynclient.MatchingLabels{
 					"feature.node.kubernetes.io/network-sriov.capable": "true",
 				}
-				err = f.Client.List(goctx.TODO(), nodeList, lo)
+				err := f.Client.List(goctx.TODO(), nodeList, lo)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(len(nodeList.Items)).To(Equal(1))
 
 				name := nodeList.Items[0].GetName()
-				nodeState := &sriovnetworkv1.SriovNetworkNodeState{}
-				err = WaitForSriovNetworkNodeStateReady(nodeState, f.Client, namespace, name, RetryInterval, Timeout*5)
+				ctx, cancel := goctx.WithTimeout(goctx.Background(), Timeout*5)
+				defer cancel()
+				nodeState, err := wait.ForNodeStateSucceeded(ctx, clients, namespace, name, 0)
 				Expect(err).NotTo(HaveOccurred())
 
 				By("apply node policy CRs")
@@ -393,23 +392,22 @@ var _ = Describe("Operator", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				By("generate the config for device plugin")
-				time.Sleep(3 * time.Second)
-				config := &corev1.ConfigMap{}
-				err = WaitForNamespacedObject(config, f.Client, namespace, "device-plugin-config", RetryInterval, Timeout)
+				obj, err := wait.For(ctx, wait.ConfigMaps(clients, namespace), deviceplugin.ConfigMapName, devicePluginConfigured(policies))
+				Expect(err).NotTo(HaveOccurred())
+				config := obj.(*corev1.ConfigMap)
+				diff, err := deviceplugin.ValidateConfigMap(policies, config, nodeList.Items)
 				Expect(err).NotTo(HaveOccurred())
-				err = ValidateDevicePluginConfig(policies, config.Data["config.json"])
+				Expect(diff).To(BeEmpty())
 
 				By("wait for the node state ready")
-				err = WaitForSriovNetworkNodeStateReady(nodeState, f.Client, namespace, name, RetryInterval, Timeout*5)
+				nodeState, err = wait.ForNodeStateSucceeded(ctx, clients, namespace, name, nodeState.Generation+1)
 				Expect(err).NotTo(HaveOccurred())
 
 				By("provision the cni and device plugin daemonsets")
-				cniDaemonSet := &appsv1.DaemonSet{}
-				err = WaitForDaemonSetReady(cniDaemonSet, f.Client, namespace, "sriov-cni", RetryInterval, Timeout)
+				_, err = wait.ForDaemonSetRolledOut(ctx, clients, namespace, "sriov-cni")
 				Expect(err).NotTo(HaveOccurred())
 
-				dpDaemonSet := &appsv1.DaemonSet{}
-				err = WaitForDaemonSetReady(dpDaemonSet, f.Client, namespace, "sriov-device-plugin", RetryInterval, Timeout)
+				_, err = wait.ForDaemonSetRolledOut(ctx, clients, namespace, "sriov-device-plugin")
 				Expect(err).NotTo(HaveOccurred())
 
 				By("update the spec of SriovNetworkNodeState CR")
@@ -2,12 +2,31 @@ package operator
 
 import (
 	"fmt"
+	"os"
 	"testing"
 
 	f "github.com/operator-framework/operator-sdk/pkg/test"
+
+	"github.com/openshift/sriov-tests/pkg/util/envtest"
 )
 
+// testEnv is the local apiserver the suite runs against when USE_ENVTEST is set.
+// No operator is started against it: the specs needing one are skipped.
+var testEnv *envtest.Environment
+
 func TestMain(m *testing.M) {
 	fmt.Printf("Start Operator TestMain\n")
+	if os.Getenv("USE_ENVTEST") == "true" {
+		testEnv = &envtest.Environment{Namespaces: []string{namespace}}
+		err := testEnv.Start()
+		if err != nil {
+			fmt.Printf("Failed to start the test environment: %v\n", err)
+			os.Exit(1)
+		}
+		// The framework loads the kubeconfig from the environment when
+		// the kubeconfig flag is not set.
+		os.Setenv("KUBECONFIG", testEnv.KubeconfigPath)
+		fmt.Printf("Test environment running, kubeconfig at %s\n", testEnv.KubeconfigPath)
+	}
 	f.MainEntry(m)
 }
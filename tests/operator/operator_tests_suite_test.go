@@ -1,13 +1,15 @@
 package operator
 
 import (
-	// goctx "context"
+	goctx "context"
 	// "encoding/json"
 	// "fmt"
 	// "reflect"
 	// "strings"
+	"flag"
+	"os"
 	"testing"
-	// "time"
+	"time"
 
 	// dptypes "github.com/intel/sriov-network-device-plugin/pkg/types"
 	framework "github.com/operator-framework/operator-sdk/pkg/test"
@@ -27,13 +29,24 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/reporters"
 	. "github.com/onsi/gomega"
 
 	. "github.com/openshift/sriov-tests/pkg/util"
+	testclient "github.com/openshift/sriov-tests/pkg/util/client"
 )
 
 var namespace = "openshift-sriov-network-operator"
 var oprctx framework.TestCtx
+var clients *testclient.ClientSet
+var junitPath *string
+
+func init() {
+	junitPath = flag.String("junit", "", "the path for the junit format report")
+	if ns := os.Getenv("OPERATOR_NAMESPACE"); ns != "" {
+		namespace = ns
+	}
+}
 
 func TestSriovTests(t *testing.T) {
 	snetList := &sriovnetworkv1.SriovNetworkList{
@@ -49,18 +62,47 @@ func TestSriovTests(t *testing.T) {
 
 	config.GinkgoConfig.ParallelTotal = 1
 	RegisterFailHandler(Fail)
-	RunSpecs(t, "OperatorTests Suite")
+
+	rr := []Reporter{}
+	if *junitPath != "" {
+		rr = append(rr, reporters.NewJUnitReporter(*junitPath))
+	}
+	RunSpecsWithDefaultAndCustomReporters(t, "OperatorTests Suite", rr)
 }
 
 var _ = BeforeSuite(func() {
 	// get global framework variables
 	f := framework.Global
-	// wait for sriov-network-operator to be ready
-	deploy := &appsv1.Deployment{}
-	err := WaitForNamespacedObject(deploy, f.Client, namespace, "sriov-network-operator", RetryInterval, Timeout)
-	Expect(err).NotTo(HaveOccurred())
+	// wait for sriov-network-operator to be ready, no operator runs against envtest
+	if testEnv == nil {
+		deploy := &appsv1.Deployment{}
+		err := WaitForNamespacedObject(deploy, f.Client, namespace, "sriov-network-operator", RetryInterval, Timeout)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	var err error
+	ctx, cancel := goctx.WithTimeout(goctx.Background(), time.Minute)
+	defer cancel()
+	clients, err = testclient.NewWithOptions(ctx, testclient.Options{
+		AddToScheme:           sriovnetworkv1.AddToScheme,
+		AllowMissingOpenShift: true,
+	})
+	Expect(err).ToNot(HaveOccurred())
 })
 
+// skipWithoutOperator skips the specs relying on the operator reconciling the
+// resources when the suite runs against envtest, where only the apiserver runs.
+func skipWithoutOperator() {
+	if testEnv != nil {
+		Skip("the operator does not run against envtest")
+	}
+}
+
 var _ = AfterSuite(func() {
 	oprctx.Cleanup()
+	// MainEntry exits the process, so the environment is stopped here
+	if testEnv != nil {
+		err := testEnv.Stop()
+		Expect(err).NotTo(HaveOccurred())
+	}
 })
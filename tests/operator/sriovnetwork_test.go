@@ -8,7 +8,6 @@ import (
 	// "reflect"
 	"strings"
 	// "testing"
-	"time"
 
 	// dptypes "github.com/intel/sriov-network-device-plugin/pkg/types"
 	framework "github.com/operator-framework/operator-sdk/pkg/test"
@@ -17,7 +16,7 @@ import (
 	// corev1 "k8s.io/api/core/v1"
 	// "k8s.io/apimachinery/pkg/api/errors"
 	// metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	// "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	// "k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/retry"
@@ -32,11 +31,17 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/openshift/sriov-tests/pkg/util"
+	"github.com/openshift/sriov-tests/pkg/util/cni"
+	"github.com/openshift/sriov-tests/pkg/util/wait"
 )
 
 var _ = Describe("Operator", func() {
 
 	Context("with SriovNetwork", func() {
+		BeforeEach(func() {
+			skipWithoutOperator()
+		})
+
 		specs := map[string]sriovnetworkv1.SriovNetworkSpec{
 			"test-0": {
 				ResourceName: "resource_1",
@@ -67,24 +72,24 @@ var _ = Describe("Operator", func() {
 		DescribeTable("should be possible to create/delete net-att-def",
 			func(cr sriovnetworkv1.SriovNetwork) {
 				var err error
-				expect := GenerateExpectedNetConfig(&cr)
+				expect, err := cni.FromSriovNetwork(&cr)
+				Expect(err).NotTo(HaveOccurred())
 
 				By("Create the SriovNetwork Custom Resource")
 				// get global framework variables
 				f := framework.Global
 				err = f.Client.Create(goctx.TODO(), &cr, &framework.CleanupOptions{TestContext: &oprctx, Timeout: ApiTimeout, RetryInterval: RetryInterval})
 				Expect(err).NotTo(HaveOccurred())
-				ns := namespace
-				if cr.Spec.NetworkNamespace != "" {
-					ns = cr.Spec.NetworkNamespace
-				}
+				ns := cni.Namespace(&cr)
 				netAttDef := &netattdefv1.NetworkAttachmentDefinition{}
 				err = WaitForNamespacedObject(netAttDef, f.Client, ns, cr.GetName(), RetryInterval, Timeout)
 				Expect(err).NotTo(HaveOccurred())
 				anno := netAttDef.GetAnnotations()
 
 				Expect(anno["k8s.v1.cni.cncf.io/resourceName"]).To(Equal("openshift.io/" + cr.Spec.ResourceName))
-				Expect(strings.TrimSpace(netAttDef.Spec.Config)).To(Equal(expect))
+				actual, err := cni.Parse(netAttDef)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cni.Diff(expect, actual)).To(BeEmpty())
 
 				By("Delete the SriovNetwork Custom Resource")
 				found := &sriovnetworkv1.SriovNetwork{}
@@ -133,7 +138,8 @@ var _ = Describe("Operator", func() {
 				err := f.Client.Create(goctx.TODO(), &old, &framework.CleanupOptions{TestContext: &oprctx, Timeout: ApiTimeout, RetryInterval: RetryInterval})
 				Expect(err).NotTo(HaveOccurred())
 				found := &sriovnetworkv1.SriovNetwork{}
-				expect := GenerateExpectedNetConfig(&new)
+				expect, err := cni.FromSriovNetwork(&new)
+				Expect(err).NotTo(HaveOccurred())
 
 				retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 					// Retrieve the latest version of SriovNetwork before attempting update
@@ -154,19 +160,32 @@ var _ = Describe("Operator", func() {
 					Fail(fmt.Sprintf("Update failed: %v", retryErr))
 				}
 
-				ns := namespace
-				if new.Spec.NetworkNamespace != "" {
-					ns = new.Spec.NetworkNamespace
-				}
+				ns := cni.Namespace(&new)
 
-				time.Sleep(time.Second * 2)
-				netAttDef := &netattdefv1.NetworkAttachmentDefinition{}
-				err = WaitForNamespacedObject(netAttDef, f.Client, ns, old.GetName(), RetryInterval, Timeout)
+				ctx, cancel := goctx.WithTimeout(goctx.Background(), Timeout)
+				defer cancel()
+				obj, err := wait.For(ctx, wait.NetworkAttachmentDefinitions(clients, ns), old.GetName(), func(obj runtime.Object) (bool, string) {
+					nad, ok := obj.(*netattdefv1.NetworkAttachmentDefinition)
+					if !ok {
+						return false, "not found"
+					}
+					actual, err := cni.Parse(nad)
+					if err != nil {
+						return false, err.Error()
+					}
+					if diff := cni.Diff(expect, actual); len(diff) > 0 {
+						return false, strings.Join(diff, "; ")
+					}
+					return true, ""
+				})
 				Expect(err).NotTo(HaveOccurred())
+				netAttDef := obj.(*netattdefv1.NetworkAttachmentDefinition)
 				anno := netAttDef.GetAnnotations()
 
 				Expect(anno["k8s.v1.cni.cncf.io/resourceName"]).To(Equal("openshift.io/" + new.Spec.ResourceName))
-				Expect(strings.TrimSpace(netAttDef.Spec.Config)).To(Equal(expect))
+				actual, err := cni.Parse(netAttDef)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cni.Diff(expect, actual)).To(BeEmpty())
 			},
 			Entry("with vlan flag and ipam updated", sriovnets["test-4"], newsriovnets["new-0"]),
 			Entry("with networkNamespace flag", sriovnets["test-4"], newsriovnets["new-1"]),
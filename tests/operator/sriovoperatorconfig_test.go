@@ -4,10 +4,9 @@ import (
 	goctx "context"
 	// "encoding/json"
 	// "fmt"
-	// "reflect"
+	"reflect"
 	// "strings"
 	// "testing"
-	"time"
 
 	// dptypes "github.com/intel/sriov-network-device-plugin/pkg/types"
 	framework "github.com/operator-framework/operator-sdk/pkg/test"
@@ -17,7 +16,7 @@ import (
 	// corev1 "k8s.io/api/core/v1"
 	// "k8s.io/apimachinery/pkg/api/errors"
 	// metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	// "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime"
 	// "k8s.io/apimachinery/pkg/types"
 	// "k8s.io/apimachinery/pkg/util/wait"
 	// dynclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -31,23 +30,29 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/openshift/sriov-tests/pkg/util"
+	"github.com/openshift/sriov-tests/pkg/util/snapshot"
+	"github.com/openshift/sriov-tests/pkg/util/wait"
 )
 
 var _ = Describe("Operator", func() {
 
-	AfterEach(func() {
+	var state *snapshot.Snapshot
+
+	BeforeEach(func() {
+		skipWithoutOperator()
 		// get global framework variables
 		f := framework.Global
-		// wait for sriov-network-operator to be ready
+		// wait for the operator to create the default config
 		config := &sriovnetworkv1.SriovOperatorConfig{}
 		err := WaitForNamespacedObject(config, f.Client, namespace, "default", RetryInterval, Timeout)
 		Expect(err).NotTo(HaveOccurred())
 
-		*config.Spec.EnableOperatorWebhook = true
-		*config.Spec.EnableInjector = true
-		config.Spec.ConfigDaemonNodeSelector = nil
+		state, err = snapshot.Take(clients, namespace)
+		Expect(err).NotTo(HaveOccurred())
+	})
 
-		err = f.Client.Update(goctx.TODO(), config)
+	AfterEach(func() {
+		err := state.Restore(clients)
 		Expect(err).NotTo(HaveOccurred())
 	})
 
@@ -180,10 +185,17 @@ var _ = Describe("Operator", func() {
 			err = f.Client.Update(goctx.TODO(), config)
 			Expect(err).NotTo(HaveOccurred())
 
-			time.Sleep(3 * time.Second)
-			daemonSet := &appsv1.DaemonSet{}
-			err = WaitForDaemonSetReady(daemonSet, f.Client, namespace, "sriov-network-config-daemon", RetryInterval, Timeout)
+			ctx, cancel := goctx.WithTimeout(goctx.Background(), Timeout)
+			defer cancel()
+			obj, err := wait.For(ctx, wait.DaemonSets(clients, namespace), "sriov-network-config-daemon", func(obj runtime.Object) (bool, string) {
+				ds, ok := obj.(*appsv1.DaemonSet)
+				if !ok || !reflect.DeepEqual(ds.Spec.Template.Spec.NodeSelector, config.Spec.ConfigDaemonNodeSelector) {
+					return false, "node selector not updated yet"
+				}
+				return wait.DaemonSetRolledOut(obj)
+			})
 			Expect(err).NotTo(HaveOccurred())
+			daemonSet := obj.(*appsv1.DaemonSet)
 			Expect(daemonSet.Spec.Template.Spec.NodeSelector).To(Equal(config.Spec.ConfigDaemonNodeSelector))
 		})
 	})